@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/malvex/vibediff/internal/apidiff"
+	"github.com/malvex/vibediff/internal/git"
+	"github.com/malvex/vibediff/internal/stats"
+)
+
+// runAPICommand prints the exported Go API changes of the diff and returns
+// the exit code, 2 when a change is likely breaking
+func runAPICommand(service *git.Service, format string) int {
+	diff, err := service.GetDiff(git.DiffTypeAll)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get diff: %v\n", err)
+		return 1
+	}
+
+	report, err := apidiff.Compare(service, diff)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to compare API: %v\n", err)
+		return 1
+	}
+
+	if format == "json" {
+		output, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error marshaling report: %v\n", err)
+			return 1
+		}
+		fmt.Println(string(output))
+	} else {
+		printAPIReport(report)
+	}
+
+	if report.Breaking {
+		return 2
+	}
+	return 0
+}
+
+func printAPIReport(report *apidiff.Report) {
+	if len(report.Changes) == 0 {
+		fmt.Println("No exported API changes.")
+	}
+
+	var pkg string
+	for _, change := range report.Changes {
+		if change.Package != pkg {
+			pkg = change.Package
+			fmt.Printf("\n%s\n", pkg)
+		}
+
+		marker := ""
+		if change.Breaking {
+			marker = " [BREAKING]"
+		}
+		switch change.Kind {
+		case apidiff.ChangeAdded:
+			fmt.Printf("  + %s%s\n", change.New, marker)
+		case apidiff.ChangeRemoved:
+			fmt.Printf("  - %s%s\n", change.Old, marker)
+		default:
+			fmt.Printf("  ~ %s%s\n      was: %s\n", change.New, marker, change.Old)
+		}
+		fmt.Printf("      %s:%d\n", change.File, change.Line)
+	}
+
+	if len(report.Unresolved) > 0 {
+		fmt.Println("\nTypes that couldn't be resolved, changes to these may be missing:")
+		for _, name := range report.Unresolved {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+}
+
+// runStatsCommand prints statistics of the diff and returns the exit code
+func runStatsCommand(service *git.Service, format string) int {
+	diff, err := service.GetDiff(git.DiffTypeAll)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get diff: %v\n", err)
+		return 1
+	}
+
+	result := stats.Compute(diff, service.GoModulePath(service.DiffRevisions(diff)), stats.DefaultTop)
+
+	if format == "json" {
+		output, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error marshaling stats: %v\n", err)
+			return 1
+		}
+		fmt.Println(string(output))
+		return 0
+	}
+
+	printStats(result)
+	return 0
+}
+
+func printStats(result *stats.Stats) {
+	fmt.Printf("%d files changed, +%d -%d\n", result.Files, result.Additions, result.Deletions)
+	if result.Files == 0 {
+		return
+	}
+
+	sections := []struct {
+		title   string
+		buckets []stats.Bucket
+	}{
+		{"By status", result.ByStatus},
+		{"By language", result.ByLanguage},
+		{"By Go package", result.ByPackage},
+		{"By directory", result.ByDirectory},
+	}
+	for _, section := range sections {
+		if len(section.buckets) == 0 {
+			continue
+		}
+		fmt.Printf("\n%s\n", section.title)
+		width := 0
+		for _, bucket := range section.buckets {
+			width = max(width, len(bucket.Name))
+		}
+		for _, bucket := range section.buckets {
+			fmt.Printf("  %-*s  %3d files  +%-5d -%d\n", width, bucket.Name, bucket.Files, bucket.Additions, bucket.Deletions)
+		}
+	}
+
+	fmt.Printf("\nLargest files\n")
+	for _, file := range result.Largest {
+		fmt.Printf("  %5d  %s (+%d -%d)\n", file.Churn, file.Path, file.Additions, file.Deletions)
+	}
+}
+
+// runRangeDiffCommand prints how a patch series changed between two versions
+func runRangeDiffCommand(service *git.Service, oldRange, newRange, format string) int {
+	rangeDiff, err := service.GetRangeDiff(oldRange, newRange)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to compare series: %v\n", err)
+		return 1
+	}
+
+	if format == "json" {
+		output, err := json.MarshalIndent(rangeDiff, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error marshaling range-diff: %v\n", err)
+			return 1
+		}
+		fmt.Println(string(output))
+		return 0
+	}
+
+	printRangeDiff(rangeDiff)
+	return 0
+}
+
+func printRangeDiff(rangeDiff *git.RangeDiff) {
+	for _, pair := range rangeDiff.Pairs {
+		fmt.Printf("%-8s %s\n", pair.Status, pair.Subject)
+		for _, hunk := range pair.Hunks {
+			fmt.Printf("    %s\n", hunk.Header)
+			for _, line := range hunk.Lines {
+				prefix := " "
+				switch line.Type {
+				case git.LineTypeAdded:
+					prefix = "+"
+				case git.LineTypeDeleted:
+					prefix = "-"
+				}
+				fmt.Printf("    %s%s\n", prefix, line.Content)
+			}
+		}
+	}
+}
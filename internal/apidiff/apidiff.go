@@ -0,0 +1,351 @@
+package apidiff
+
+import (
+	"go/importer"
+	"go/token"
+	"go/types"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/malvex/vibediff/internal/git"
+)
+
+type ChangeKind string
+
+const (
+	ChangeAdded   ChangeKind = "added"
+	ChangeRemoved ChangeKind = "removed"
+	ChangeChanged ChangeKind = "changed"
+)
+
+// Change is a single difference in the exported API of a package
+type Change struct {
+	Package  string     `json:"package"`
+	Name     string     `json:"name"`
+	Kind     ChangeKind `json:"kind"`
+	Old      string     `json:"old,omitempty"`
+	New      string     `json:"new,omitempty"`
+	Breaking bool       `json:"breaking"`
+	// File, Line and Hunk locate the change in the diff, Line and Hunk refer
+	// to the old side for removed identifiers
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+	Hunk *int   `json:"hunk,omitempty"`
+}
+
+type Report struct {
+	Changes  []Change `json:"changes"`
+	Breaking bool     `json:"breaking"`
+	// Unresolved lists identifiers whose types couldn't be worked out on one
+	// of the sides, e.g. "example.com/m/pkg.Name", changes to them may be missing
+	Unresolved []string `json:"unresolved,omitempty"`
+}
+
+// Compare loads every Go package touched by a diff on both sides and reports
+// how its exported API changed
+func Compare(service *git.Service, diff *git.DiffResult) (*Report, error) {
+	if oldDir, _ := service.CompareDirs(); service.ReadOnly() || oldDir != "" {
+		return nil, git.ErrReadOnly
+	}
+	revs := service.DiffRevisions(diff)
+	modulePath := service.GoModulePath(revs)
+
+	// Each side loads the module's packages as they are on that side, the
+	// standard library is the same for both
+	fset := token.NewFileSet()
+	std := importer.ForCompiler(fset, "source", nil)
+	oldSide := newLoader(service, revs, git.SideOld, modulePath, fset, std)
+	newSide := newLoader(service, revs, git.SideNew, modulePath, fset, std)
+
+	report := &Report{Changes: []Change{}}
+	unresolved := make(map[string]bool)
+	for _, dir := range changedPackages(diff) {
+		pkgPath := dir
+		if modulePath != "" {
+			pkgPath = path.Join(modulePath, dir)
+		}
+
+		var apis [2]map[string]apiEntry
+		for i, side := range []*loader{oldSide, newSide} {
+			pkg, err := side.load(dir, pkgPath)
+			if err != nil {
+				return nil, err
+			}
+			apis[i] = collectAPI(pkg, fset)
+			for name, entry := range apis[i] {
+				if entry.unresolved {
+					unresolved[pkgPath+"."+name] = true
+				}
+			}
+		}
+
+		for _, change := range compareAPI(apis[0], apis[1]) {
+			change.Package = pkgPath
+			linkHunk(&change, diff)
+			report.Changes = append(report.Changes, change)
+			if change.Breaking {
+				report.Breaking = true
+			}
+		}
+	}
+
+	for name := range unresolved {
+		report.Unresolved = append(report.Unresolved, name)
+	}
+	sort.Strings(report.Unresolved)
+	return report, nil
+}
+
+// changedPackages returns the directories containing changed non-test Go files
+func changedPackages(diff *git.DiffResult) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, file := range diff.Files {
+		for _, p := range []string{file.Path, file.OldPath} {
+			if !isPackageFile(p) || seen[path.Dir(p)] {
+				continue
+			}
+			seen[path.Dir(p)] = true
+			dirs = append(dirs, path.Dir(p))
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// apiEntry is an exported identifier, a method or a field of an exported type
+type apiEntry struct {
+	decl string
+	// shape describes the parts of a type that aren't listed as separate
+	// entries, so a struct changing into an interface is still caught
+	shape string
+	file  string
+	line  int
+	// isConst marks constants, whose value may change without breaking callers
+	isConst bool
+	// ifaceMethod marks interface methods, adding one breaks implementations
+	ifaceMethod bool
+	// unresolved marks declarations with types that couldn't be worked out
+	unresolved bool
+}
+
+// collectAPI lists the exported API of a package, which is empty for a
+// package that doesn't exist
+func collectAPI(pkg *types.Package, fset *token.FileSet) map[string]apiEntry {
+	api := make(map[string]apiEntry)
+	if pkg == nil {
+		return api
+	}
+
+	qualifier := func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+		return other.Name()
+	}
+	entry := func(obj types.Object, decl string) apiEntry {
+		pos := fset.Position(obj.Pos())
+		// go/types renders types it couldn't resolve as "invalid type"
+		return apiEntry{decl: decl, file: pos.Filename, line: pos.Line, unresolved: strings.Contains(decl, "invalid type")}
+	}
+
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+
+		switch obj := obj.(type) {
+		case *types.Const:
+			e := entry(obj, types.ObjectString(obj, qualifier)+" = "+obj.Val().ExactString())
+			e.isConst = true
+			api[name] = e
+		case *types.Var, *types.Func:
+			api[name] = entry(obj, types.ObjectString(obj, qualifier))
+		case *types.TypeName:
+			e := entry(obj, typeDecl(obj, qualifier))
+			e.shape = typeShape(obj.Type().Underlying(), qualifier)
+			api[name] = e
+
+			switch u := obj.Type().Underlying().(type) {
+			case *types.Struct:
+				for i := 0; i < u.NumFields(); i++ {
+					field := u.Field(i)
+					if field.Exported() {
+						api[name+"."+field.Name()] = entry(field, types.ObjectString(field, qualifier))
+					}
+				}
+			case *types.Interface:
+				for i := 0; i < u.NumMethods(); i++ {
+					method := u.Method(i)
+					if method.Exported() {
+						e := entry(method, types.ObjectString(method, qualifier))
+						e.ifaceMethod = true
+						api[name+"."+method.Name()] = e
+					}
+				}
+			}
+
+			if _, isInterface := obj.Type().Underlying().(*types.Interface); !isInterface && !obj.IsAlias() {
+				methods := types.NewMethodSet(types.NewPointer(obj.Type()))
+				for i := 0; i < methods.Len(); i++ {
+					method := methods.At(i).Obj()
+					// Promoted methods belong to the embedded type's own API
+					if method.Exported() && len(methods.At(i).Index()) == 1 {
+						api[name+"."+method.Name()] = entry(method, types.ObjectString(method, qualifier))
+					}
+				}
+			}
+		}
+	}
+
+	return api
+}
+
+// typeDecl renders a type declaration header, e.g. "type List[T any] struct"
+func typeDecl(obj *types.TypeName, qualifier types.Qualifier) string {
+	var b strings.Builder
+	b.WriteString("type ")
+	b.WriteString(obj.Name())
+	if named, ok := obj.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+		params := make([]string, named.TypeParams().Len())
+		for i := range params {
+			param := named.TypeParams().At(i)
+			params[i] = param.Obj().Name() + " " + types.TypeString(param.Constraint(), qualifier)
+		}
+		b.WriteString("[" + strings.Join(params, ", ") + "]")
+	}
+	if obj.IsAlias() {
+		b.WriteString(" = " + types.TypeString(obj.Type(), qualifier))
+		return b.String()
+	}
+
+	switch obj.Type().Underlying().(type) {
+	case *types.Struct:
+		b.WriteString(" struct")
+	case *types.Interface:
+		b.WriteString(" interface")
+	default:
+		b.WriteString(" " + types.TypeString(obj.Type().Underlying(), qualifier))
+	}
+	return b.String()
+}
+
+// typeShape describes the unexported parts of a type that still matter to
+// users: embedded fields and an interface's type set
+func typeShape(t types.Type, qualifier types.Qualifier) string {
+	switch u := t.(type) {
+	case *types.Struct:
+		var embedded []string
+		for i := 0; i < u.NumFields(); i++ {
+			if u.Field(i).Embedded() {
+				embedded = append(embedded, types.TypeString(u.Field(i).Type(), qualifier))
+			}
+		}
+		return strings.Join(embedded, "; ")
+	case *types.Interface:
+		var unexported []string
+		for i := 0; i < u.NumMethods(); i++ {
+			if !u.Method(i).Exported() {
+				unexported = append(unexported, u.Method(i).Name())
+			}
+		}
+		return strings.Join(unexported, "; ")
+	}
+	return ""
+}
+
+func compareAPI(oldAPI, newAPI map[string]apiEntry) []Change {
+	var changes []Change
+
+	for name, old := range oldAPI {
+		cur, exists := newAPI[name]
+		switch {
+		case !exists && !hasParent(name, newAPI):
+			// Members of a removed type are covered by the type itself
+			continue
+		case !exists:
+			changes = append(changes, Change{
+				Name:     name,
+				Kind:     ChangeRemoved,
+				Old:      old.decl,
+				Breaking: true,
+				File:     old.file,
+				Line:     old.line,
+			})
+		case old.decl != cur.decl || old.shape != cur.shape:
+			changes = append(changes, Change{
+				Name: name,
+				Kind: ChangeChanged,
+				Old:  old.decl,
+				New:  cur.decl,
+				// A constant keeping its type but changing its value still compiles
+				Breaking: !(old.isConst && constType(old.decl) == constType(cur.decl)),
+				File:     cur.file,
+				Line:     cur.line,
+			})
+		}
+	}
+
+	for name, cur := range newAPI {
+		if _, existed := oldAPI[name]; existed || !hasParent(name, oldAPI) {
+			continue
+		}
+		changes = append(changes, Change{
+			Name: name,
+			Kind: ChangeAdded,
+			New:  cur.decl,
+			// New interface methods must be implemented by every existing type
+			Breaking: cur.ifaceMethod,
+			File:     cur.file,
+			Line:     cur.line,
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+// hasParent reports whether the type owning a member entry such as
+// "Type.Method" exists in api, top-level entries always have a parent
+func hasParent(name string, api map[string]apiEntry) bool {
+	parent, _, isMember := strings.Cut(name, ".")
+	if !isMember {
+		return true
+	}
+	_, exists := api[parent]
+	return exists
+}
+
+// constType strips the value from a constant declaration
+func constType(decl string) string {
+	if i := strings.Index(decl, " = "); i >= 0 {
+		return decl[:i]
+	}
+	return decl
+}
+
+// linkHunk points a change at the hunk of its file that covers its line
+func linkHunk(change *Change, diff *git.DiffResult) {
+	for _, file := range diff.Files {
+		oldSide := change.Kind == ChangeRemoved
+		if (oldSide && file.OldPath != change.File && file.Path != change.File) || (!oldSide && file.Path != change.File) {
+			continue
+		}
+		for i, hunk := range file.Hunks {
+			start, count := hunk.NewStart, hunk.NewLines
+			if oldSide {
+				start, count = hunk.OldStart, hunk.OldLines
+			}
+			if change.Line >= start && change.Line < start+count {
+				index := i
+				change.Hunk = &index
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package apidiff
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/malvex/vibediff/internal/git"
+)
+
+// checkAPI type-checks a single file package and collects its API
+func checkAPI(t *testing.T, fset *token.FileSet, src string) map[string]apiEntry {
+	t.Helper()
+	if src == "" {
+		return collectAPI(nil, fset)
+	}
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check("example.com/p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return collectAPI(pkg, fset)
+}
+
+// describe formats changes as "kind name" with "!" for breaking ones
+func describe(changes []Change) []string {
+	var described []string
+	for _, change := range changes {
+		breaking := ""
+		if change.Breaking {
+			breaking = "!"
+		}
+		described = append(described, fmt.Sprintf("%s %s%s", change.Kind, change.Name, breaking))
+	}
+	return described
+}
+
+func TestCompareAPI(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []string
+	}{
+		{
+			name: "unexported changes are ignored",
+			old:  "package p\nfunc helper() {}\nfunc F() {}\n",
+			new:  "package p\nfunc helper(int) {}\nfunc F() {}\n",
+			want: nil,
+		},
+		{
+			name: "function added and removed",
+			old:  "package p\nfunc Old() {}\n",
+			new:  "package p\nfunc New() {}\n",
+			want: []string{"added New", "removed Old!"},
+		},
+		{
+			name: "signature changed",
+			old:  "package p\nfunc F(a int) {}\n",
+			new:  "package p\nfunc F(a int, b string) {}\n",
+			want: []string{"changed F!"},
+		},
+		{
+			name: "parameter renamed",
+			old:  "package p\nfunc F(a int) {}\n",
+			new:  "package p\nfunc F(b int) {}\n",
+			want: []string{"changed F!"},
+		},
+		{
+			name: "constant value changed",
+			old:  "package p\nconst Limit = 10\n",
+			new:  "package p\nconst Limit = 20\n",
+			want: []string{"changed Limit"},
+		},
+		{
+			name: "constant type changed",
+			old:  "package p\nconst Limit = 10\n",
+			new:  "package p\nconst Limit int64 = 10\n",
+			want: []string{"changed Limit!"},
+		},
+		{
+			name: "struct field added and removed",
+			old:  "package p\ntype T struct{ A int }\n",
+			new:  "package p\ntype T struct{ B int }\n",
+			want: []string{"removed T.A!", "added T.B"},
+		},
+		{
+			name: "interface method added",
+			old:  "package p\ntype I interface{ A() }\n",
+			new:  "package p\ntype I interface{ A(); B() }\n",
+			want: []string{"added I.B!"},
+		},
+		{
+			name: "method added and changed",
+			old:  "package p\ntype T struct{}\nfunc (T) A() {}\n",
+			new:  "package p\ntype T struct{}\nfunc (*T) A() error { return nil }\nfunc (T) B() {}\n",
+			want: []string{"changed T.A!", "added T.B"},
+		},
+		{
+			name: "struct became interface",
+			old:  "package p\ntype T struct{}\n",
+			new:  "package p\ntype T interface{}\n",
+			want: []string{"changed T!"},
+		},
+		{
+			name: "embedded field changed",
+			old:  "package p\nimport \"io\"\ntype T struct{ io.Reader }\n",
+			new:  "package p\nimport \"io\"\ntype T struct{ io.Writer }\n",
+			want: []string{"changed T!", "removed T.Reader!", "added T.Writer"},
+		},
+		{
+			name: "members of a removed type",
+			old:  "package p\ntype T struct{ A int }\nfunc (T) M() {}\n",
+			new:  "package p\n",
+			want: []string{"removed T!"},
+		},
+		{
+			name: "package added",
+			old:  "",
+			new:  "package p\nvar V int\n",
+			want: []string{"added V"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			changes := compareAPI(checkAPI(t, fset, tt.old), checkAPI(t, fset, tt.new))
+			got := describe(changes)
+			if !sameElements(got, tt.want) {
+				t.Errorf("changes\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+// sameElements compares two lists ignoring order, changes are sorted by name
+// and fields and methods of one type can't be told apart by that
+func sameElements(a, b []string) bool {
+	count := make(map[string]int)
+	for _, s := range a {
+		count[s]++
+	}
+	for _, s := range b {
+		count[s]--
+	}
+	for _, n := range count {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestImportName(t *testing.T) {
+	tests := map[string]string{
+		"github.com/x/dep":              "dep",
+		"gopkg.in/yaml.v3":              "yaml",
+		"github.com/mattn/go-sqlite3":   "sqlite3",
+		"github.com/x/client-go/v2":     "client",
+		"github.com/gorilla/websocket":  "websocket",
+		"example.com/some-thing/pkg-go": "pkg",
+	}
+	for importPath, want := range tests {
+		if got := importName(importPath); got != want {
+			t.Errorf("importName(%q) = %q, want %q", importPath, got, want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	dir := t.TempDir()
+	runGit := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@example.com",
+			"GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@example.com", "GIT_CONFIG_GLOBAL="+os.DevNull)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+		}
+	}
+	write := func(files map[string]string) {
+		t.Helper()
+		for name, content := range files {
+			p := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	write(map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.22\n",
+		"b/b.go": "package b\n\ntype Old int\n",
+		"a/a.go": "package a\n\nimport (\n\t\"example.com/m/b\"\n\t\"github.com/x/dep\"\n)\n\n" +
+			"func F() b.Old { return 0 }\n\nfunc G(c dep.Client) {}\n\nvar V = dep.New()\n",
+		"c/c.go": "package c\n\nfunc Unchanged() {}\n",
+	})
+	runGit("init", "-q")
+	runGit("add", "-A")
+	runGit("commit", "-q", "-m", "initial")
+
+	// b's type is renamed, a only follows it and changes a parameter
+	write(map[string]string{
+		"b/b.go": "package b\n\ntype New int\n",
+		"a/a.go": "package a\n\nimport (\n\t\"example.com/m/b\"\n\t\"github.com/x/dep\"\n)\n\n" +
+			"func F() b.New { return 0 }\n\nfunc G(c *dep.Client) {}\n\nvar V = dep.New()\n",
+	})
+
+	service := git.NewService()
+	if err := service.SetWorkDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	diff, err := service.GetDiff(git.DiffTypeAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := Compare(service, diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type located struct {
+		Package, Name string
+		Kind          ChangeKind
+		Old, New      string
+		Breaking      bool
+		File          string
+		Line          int
+		Hunk          int
+	}
+	var got []located
+	for _, change := range report.Changes {
+		hunk := -1
+		if change.Hunk != nil {
+			hunk = *change.Hunk
+		}
+		got = append(got, located{change.Package, change.Name, change.Kind, change.Old, change.New, change.Breaking, change.File, change.Line, hunk})
+	}
+	want := []located{
+		// The old side sees b.Old as it was, not as the working tree has it
+		{"example.com/m/a", "F", ChangeChanged, "func F() b.Old", "func F() b.New", true, "a/a.go", 8, 0},
+		// Types of dependencies that aren't loaded still compare by name
+		{"example.com/m/a", "G", ChangeChanged, "func G(c dep.Client)", "func G(c *dep.Client)", true, "a/a.go", 10, 0},
+		{"example.com/m/b", "New", ChangeAdded, "", "type New int", false, "b/b.go", 3, 0},
+		{"example.com/m/b", "Old", ChangeRemoved, "type Old int", "", true, "b/b.go", 3, 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changes\n%+v\nwant\n%+v", got, want)
+	}
+	if !report.Breaking {
+		t.Error("report isn't breaking")
+	}
+	if want := []string{"example.com/m/a.V"}; !reflect.DeepEqual(report.Unresolved, want) {
+		t.Errorf("unresolved %q, want %q", report.Unresolved, want)
+	}
+}
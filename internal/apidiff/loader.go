@@ -0,0 +1,259 @@
+package apidiff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/malvex/vibediff/internal/git"
+)
+
+// loader type-checks the packages of one side of a diff. Packages of the
+// module are read from that side too, so a type changed in another package
+// of the module shows up as it was at that revision. The standard library
+// comes from GOROOT. Other dependencies aren't loaded, their identifiers
+// become opaque stand-in types so signatures still compare by name, and
+// whatever can't be typed that way ends up unresolved in the report.
+type loader struct {
+	service    *git.Service
+	revs       git.Revisions
+	side       git.Side
+	modulePath string
+	fset       *token.FileSet
+	// std imports the standard library, it's shared by both sides
+	std types.Importer
+
+	packages map[string]*types.Package
+	loading  map[string]bool
+	// used lists the identifiers each import path is used for, which the
+	// stand-ins of external packages declare
+	used map[string]map[string]bool
+	// standIns marks the import paths that got a stand-in package
+	standIns map[string]bool
+}
+
+func newLoader(service *git.Service, revs git.Revisions, side git.Side, modulePath string, fset *token.FileSet, std types.Importer) *loader {
+	return &loader{
+		service:    service,
+		revs:       revs,
+		side:       side,
+		modulePath: modulePath,
+		fset:       fset,
+		std:        std,
+		packages:   make(map[string]*types.Package),
+		loading:    make(map[string]bool),
+		used:       make(map[string]map[string]bool),
+		standIns:   make(map[string]bool),
+	}
+}
+
+// Import implements types.Importer
+func (l *loader) Import(importPath string) (*types.Package, error) {
+	if pkg, ok := l.packages[importPath]; ok {
+		if l.standIns[importPath] {
+			// Later packages may use more of it
+			l.declareUsed(pkg)
+		}
+		return pkg, nil
+	}
+
+	var pkg *types.Package
+	if dir, ok := l.moduleDir(importPath); ok {
+		if l.loading[importPath] {
+			return nil, fmt.Errorf("import cycle through %s", importPath)
+		}
+		loaded, err := l.load(dir, importPath)
+		if err != nil {
+			return nil, err
+		}
+		pkg = loaded
+	} else if isStdPath(importPath) {
+		if loaded, err := l.std.Import(importPath); err == nil {
+			pkg = loaded
+		}
+	}
+	if pkg == nil {
+		pkg = types.NewPackage(importPath, importName(importPath))
+		// go/types treats incomplete packages as failed imports
+		pkg.MarkComplete()
+		l.standIns[importPath] = true
+		l.declareUsed(pkg)
+	}
+	l.packages[importPath] = pkg
+	return pkg, nil
+}
+
+// declareUsed adds a stand-in type to an external package for every
+// identifier it is used for
+func (l *loader) declareUsed(pkg *types.Package) {
+	for name := range l.used[pkg.Path()] {
+		if pkg.Scope().Lookup(name) != nil {
+			continue
+		}
+		obj := types.NewTypeName(token.NoPos, pkg, name, nil)
+		types.NewNamed(obj, types.NewStruct(nil, nil), nil)
+		pkg.Scope().Insert(obj)
+	}
+}
+
+// moduleDir returns the directory of a package of the module, "." for its root
+func (l *loader) moduleDir(importPath string) (string, bool) {
+	switch {
+	case l.modulePath == "":
+		return "", false
+	case importPath == l.modulePath:
+		return ".", true
+	case strings.HasPrefix(importPath, l.modulePath+"/"):
+		return strings.TrimPrefix(importPath, l.modulePath+"/"), true
+	}
+	return "", false
+}
+
+// load type-checks the package in dir, returning nil if it has no Go files.
+// Type errors are ignored, they only degrade the affected declarations.
+func (l *loader) load(dir, pkgPath string) (*types.Package, error) {
+	if pkg, ok := l.packages[pkgPath]; ok {
+		if l.standIns[pkgPath] {
+			// Imported but missing on this side
+			return nil, nil
+		}
+		return pkg, nil
+	}
+	l.loading[pkgPath] = true
+	defer delete(l.loading, pkgPath)
+
+	paths, err := l.service.ListDir(l.revs, l.side, dir)
+	if errors.Is(err, git.ErrReadOnly) {
+		return nil, err
+	}
+	if err != nil {
+		// The package doesn't exist on this side
+		return nil, nil
+	}
+
+	var files []*ast.File
+	names := make(map[string]int)
+	for _, p := range paths {
+		if !isPackageFile(p) {
+			continue
+		}
+		src, err := l.service.ReadFile(l.revs, l.side, p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		// Leave out files the go command wouldn't build here, such as other
+		// platforms' files and "//go:build ignore" helpers
+		if !matchFile(p, src) {
+			continue
+		}
+		f, err := parser.ParseFile(l.fset, p, src, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", p, err)
+		}
+		files = append(files, f)
+		names[f.Name.Name]++
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	// Files that still disagree on the package name are left out, keeping
+	// the most common name
+	name := files[0].Name.Name
+	for n, count := range names {
+		if count > names[name] || (count == names[name] && n == path.Base(dir)) {
+			name = n
+		}
+	}
+	kept := files[:0]
+	for _, f := range files {
+		if f.Name.Name == name {
+			kept = append(kept, f)
+			l.recordUsed(f)
+		}
+	}
+
+	conf := types.Config{
+		Importer: l,
+		Error:    func(error) {},
+	}
+	pkg, _ := conf.Check(pkgPath, l.fset, kept, nil)
+	l.packages[pkgPath] = pkg
+	return pkg, nil
+}
+
+// recordUsed notes which identifiers a file uses from each of its imports
+func (l *loader) recordUsed(f *ast.File) {
+	imports := make(map[string]string)
+	for _, spec := range f.Imports {
+		importPath := strings.Trim(spec.Path.Value, `"`)
+		name := importName(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = importPath
+	}
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); ok {
+			if importPath, ok := imports[x.Name]; ok {
+				if l.used[importPath] == nil {
+					l.used[importPath] = make(map[string]bool)
+				}
+				l.used[importPath][sel.Sel.Name] = true
+			}
+		}
+		return true
+	})
+}
+
+// isStdPath reports whether an import path belongs to the standard library,
+// whose first element has no dot
+func isStdPath(importPath string) bool {
+	first, _, _ := strings.Cut(importPath, "/")
+	return !strings.Contains(first, ".")
+}
+
+var majorVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// importName guesses the name of a package that isn't loaded from its import
+// path, e.g. "yaml" for "gopkg.in/yaml.v3" and "sqlite3" for
+// "github.com/mattn/go-sqlite3/v2"
+func importName(importPath string) string {
+	elems := strings.Split(importPath, "/")
+	name := elems[len(elems)-1]
+	if majorVersion.MatchString(name) && len(elems) > 1 {
+		name = elems[len(elems)-2]
+	}
+	name, _, _ = strings.Cut(name, ".")
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "go-"), "-go")
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+func isPackageFile(p string) bool {
+	return strings.HasSuffix(p, ".go") && !strings.HasSuffix(p, "_test.go")
+}
+
+// matchFile reports whether the build constraints and file name of a Go
+// file select it for the current platform
+func matchFile(p string, src []byte) bool {
+	ctxt := build.Default
+	ctxt.OpenFile = func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(src)), nil
+	}
+	match, err := ctxt.MatchFile(path.Dir(p), path.Base(p))
+	return err == nil && match
+}
@@ -0,0 +1,150 @@
+package git
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ignoreFile lists paths to hide from reviews, using .gitignore syntax
+const ignoreFile = ".vibediffignore"
+
+// classifyFiles marks generated, vendored and ignored files using
+// .gitattributes (linguist-generated, linguist-vendored, -diff) and .vibediffignore
+func (s *Service) classifyFiles(files []FileDiff) {
+	if len(files) == 0 {
+		return
+	}
+
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.Path
+	}
+
+	attrs, err := s.checkAttributes(paths, "linguist-generated", "linguist-vendored", "diff")
+	if err != nil {
+		attrs = map[string]map[string]string{}
+	}
+	ignored := loadIgnorePatterns(s.workTreePath(ignoreFile))
+
+	for i := range files {
+		file := &files[i]
+		fileAttrs := attrs[file.Path]
+		file.Generated = attrIsSet(fileAttrs["linguist-generated"])
+		file.Vendored = attrIsSet(fileAttrs["linguist-vendored"])
+		file.Ignored = fileAttrs["diff"] == "unset" || ignored.match(file.Path)
+	}
+}
+
+// checkAttributes looks up git attributes for the given paths, returning
+// the value of each attribute keyed by path and attribute name
+func (s *Service) checkAttributes(paths []string, names ...string) (map[string]map[string]string, error) {
+	cmd := s.gitCommand(append([]string{"check-attr", "-z", "--stdin"}, names...)...)
+	cmd.Stdin = strings.NewReader(strings.Join(paths, "\x00") + "\x00")
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	// Output is a sequence of NUL terminated "path, attribute, value" triples
+	fields := strings.Split(string(output), "\x00")
+	attrs := make(map[string]map[string]string)
+	for i := 0; i+2 < len(fields); i += 3 {
+		path, name, value := fields[i], fields[i+1], fields[i+2]
+		if attrs[path] == nil {
+			attrs[path] = make(map[string]string)
+		}
+		attrs[path][name] = value
+	}
+
+	return attrs, nil
+}
+
+func attrIsSet(value string) bool {
+	return value == "set" || value == "true"
+}
+
+type ignorePattern struct {
+	re     *regexp.Regexp
+	negate bool
+}
+
+type ignorePatterns []ignorePattern
+
+// loadIgnorePatterns reads a .gitignore style file, a missing file ignores nothing
+func loadIgnorePatterns(path string) ignorePatterns {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var patterns ignorePatterns
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern := ignorePattern{}
+		if strings.HasPrefix(line, "!") {
+			pattern.negate = true
+			line = line[1:]
+		}
+		if re, err := compileIgnorePattern(line); err == nil {
+			pattern.re = re
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	return patterns
+}
+
+// match reports whether a path is ignored, the last matching pattern wins
+func (p ignorePatterns) match(path string) bool {
+	ignored := false
+	for _, pattern := range p {
+		if pattern.re.MatchString(path) {
+			ignored = !pattern.negate
+		}
+	}
+	return ignored
+}
+
+// compileIgnorePattern turns a .gitignore pattern into a regular expression.
+// Patterns containing a slash are anchored to the repository root, others
+// match at any depth. A match on a directory covers everything below it.
+func compileIgnorePattern(pattern string) (*regexp.Regexp, error) {
+	pattern = strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var b strings.Builder
+	if anchored {
+		b.WriteString("^")
+	} else {
+		b.WriteString("(^|/)")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("(/.*)?$")
+
+	return regexp.Compile(b.String())
+}
@@ -0,0 +1,126 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompileIgnorePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.lock", "yarn.lock", true},
+		{"*.lock", "web/yarn.lock", true},
+		{"*.lock", "yarn.lock.bak", false},
+		{"dist", "dist/app.js", true},
+		{"dist", "web/dist/app.js", true},
+		{"dist/", "web/dist/app.js", true},
+		{"dist", "distance.go", false},
+		{"/dist", "web/dist/app.js", false},
+		{"web/dist", "web/dist/app.js", true},
+		{"web/dist", "other/web/dist/app.js", false},
+		{"web/*.js", "web/app.js", true},
+		{"web/*.js", "web/sub/app.js", false},
+		{"web/**/*.js", "web/app.js", true},
+		{"web/**/*.js", "web/sub/deep/app.js", true},
+		{"**/gen", "a/b/gen/x.go", true},
+		{"file?.txt", "file1.txt", true},
+		{"file?.txt", "file10.txt", false},
+		{"a.b", "axb", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			re, err := compileIgnorePattern(tt.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := re.MatchString(tt.path); got != tt.want {
+				t.Errorf("match = %v, want %v (%s)", got, tt.want, re)
+			}
+		})
+	}
+}
+
+func TestLoadIgnorePatterns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ignoreFile)
+	content := "# generated output\n\n*.pb.go\n!keep.pb.go\ndocs/\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	patterns := loadIgnorePatterns(path)
+
+	tests := map[string]bool{
+		"api/x.pb.go":     true,
+		"api/keep.pb.go":  false,
+		"docs/index.md":   true,
+		"main.go":         false,
+		"generated/notes": false,
+	}
+	for path, want := range tests {
+		if got := patterns.match(path); got != want {
+			t.Errorf("match(%q) = %v, want %v", path, got, want)
+		}
+	}
+
+	if patterns := loadIgnorePatterns(filepath.Join(t.TempDir(), "missing")); patterns.match("any") {
+		t.Error("missing ignore file ignores paths")
+	}
+}
+
+func TestClassifyFiles(t *testing.T) {
+	files := map[string]string{
+		".gitattributes": "gen/** linguist-generated\nthird_party/** linguist-vendored\n*.bin -diff\n" +
+			"gen/manual.go -linguist-generated\n",
+		".vibediffignore": "*.lock\n",
+		"main.go":         "package main\n",
+		"gen/api.go":      "package gen\n",
+		"gen/manual.go":   "package gen\n",
+		"third_party/x.c": "int x;\n",
+		"data.bin":        "data\n",
+		"yarn.lock":       "lock\n",
+	}
+	dir := testRepo(t, files)
+	changed := make(map[string]string)
+	for path, content := range files {
+		if path[0] != '.' {
+			changed[path] = content + "// changed\n"
+		}
+	}
+	writeFiles(t, dir, changed)
+
+	diff, err := testService(t, dir).GetDiff(DiffTypeAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string][]string)
+	for _, file := range diff.Files {
+		var flags []string
+		if file.Generated {
+			flags = append(flags, "generated")
+		}
+		if file.Vendored {
+			flags = append(flags, "vendored")
+		}
+		if file.Ignored {
+			flags = append(flags, "ignored")
+		}
+		got[file.Path] = flags
+	}
+
+	want := map[string][]string{
+		"main.go":         nil,
+		"gen/api.go":      {"generated"},
+		"gen/manual.go":   nil,
+		"third_party/x.c": {"vendored"},
+		"data.bin":        {"ignored"},
+		"yarn.lock":       {"ignored"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("classified\n%v\nwant\n%v", got, want)
+	}
+}
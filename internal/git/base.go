@@ -0,0 +1,142 @@
+package git
+
+import (
+	"errors"
+	"strings"
+)
+
+// BaseMode selects what the working tree is compared with when no target is given
+type BaseMode string
+
+const (
+	// BaseModeHead compares with HEAD
+	BaseModeHead BaseMode = ""
+	// BaseModePR compares with the merge-base of HEAD and the upstream or
+	// default branch, like the diff of a pull request
+	BaseModePR BaseMode = "pr"
+	// BaseModeAuto uses BaseModePR on branches that diverged from their
+	// base and BaseModeHead otherwise
+	BaseModeAuto BaseMode = "auto"
+)
+
+// ErrNoPRBase is returned in PR mode when neither an upstream nor a default branch exists
+var ErrNoPRBase = errors.New("no upstream or default branch to compare against")
+
+// defaultBranches are tried in order when there is no usable upstream
+var defaultBranches = []string{"main", "master", "origin/main", "origin/master"}
+
+// DiffBase describes the commit a diff was taken against
+type DiffBase struct {
+	// Ref is the target or branch the base was resolved from, e.g. "origin/main"
+	Ref string `json:"ref"`
+	// Commit is the commit compared with, the merge-base in PR mode
+	Commit string `json:"commit,omitempty"`
+	// MergeBase is set when Commit is the merge-base of HEAD and Ref
+	MergeBase bool `json:"mergeBase,omitempty"`
+}
+
+// rev returns the revision to pass to git diff
+func (b *DiffBase) rev() string {
+	if b.Commit != "" {
+		return b.Commit
+	}
+	return b.Ref
+}
+
+// SetBaseMode chooses what to compare with when no diff target is set
+func (s *Service) SetBaseMode(mode BaseMode) {
+	s.baseMode = mode
+}
+
+// DiffBase resolves the base a diff of the given type is taken against. It
+// returns nil when comparing with HEAD, the index or a review snapshot.
+func (s *Service) DiffBase(diffType DiffType) (*DiffBase, error) {
+	if s.patch != nil || s.oldDir != "" || diffType == DiffTypeSinceReview {
+		return nil, nil
+	}
+
+	if s.diffTarget != "" {
+		base := &DiffBase{Ref: s.diffTarget}
+		if commit, err := s.runGitCommand("rev-parse", "--verify", "--quiet", s.diffTarget+"^{commit}"); err == nil {
+			base.Commit = strings.TrimSpace(commit)
+		}
+		return base, nil
+	}
+
+	switch s.baseMode {
+	case BaseModePR:
+		return s.prBase()
+	case BaseModeAuto:
+		base, err := s.prBase()
+		if err != nil {
+			return nil, nil
+		}
+		// Nothing to review beyond HEAD when the branch hasn't diverged
+		head, err := s.runGitCommand("rev-parse", "HEAD")
+		if err != nil || strings.TrimSpace(head) == base.Commit {
+			return nil, nil
+		}
+		return base, nil
+	}
+
+	return nil, nil
+}
+
+// prBase finds the branch the current one will be merged into and returns
+// its merge-base with HEAD
+func (s *Service) prBase() (*DiffBase, error) {
+	ref := s.upstreamBase()
+	if ref == "" {
+		ref = s.defaultBranch()
+	}
+	if ref == "" {
+		return nil, ErrNoPRBase
+	}
+
+	mergeBase, err := s.runGitCommand("merge-base", "HEAD", ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DiffBase{
+		Ref:       ref,
+		Commit:    strings.TrimSpace(mergeBase),
+		MergeBase: true,
+	}, nil
+}
+
+// upstreamBase returns the tracking branch of the current branch, unless it
+// is just the same branch on a remote, which says nothing about the base
+func (s *Service) upstreamBase() string {
+	upstream, err := s.runGitCommand("rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+	if err != nil {
+		return ""
+	}
+	upstream = strings.TrimSpace(upstream)
+
+	branch, err := s.runGitCommand("rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return ""
+	}
+	branch = strings.TrimSpace(branch)
+
+	if _, name, ok := strings.Cut(upstream, "/"); upstream == branch || (ok && name == branch) {
+		return ""
+	}
+	return upstream
+}
+
+// defaultBranch returns the remote's default branch or the first of
+// defaultBranches that exists
+func (s *Service) defaultBranch() string {
+	if ref, err := s.runGitCommand("symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD"); err == nil {
+		return strings.TrimSpace(ref)
+	}
+
+	for _, name := range defaultBranches {
+		if _, err := s.runGitCommand("rev-parse", "--verify", "--quiet", name+"^{commit}"); err == nil {
+			return name
+		}
+	}
+	return ""
+}
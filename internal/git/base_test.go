@@ -0,0 +1,193 @@
+package git
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// commitFile commits a change to a file on the current branch
+func commitFile(t *testing.T, dir, path, content string) {
+	t.Helper()
+	writeFiles(t, dir, map[string]string{path: content})
+	gitIn(t, dir, "add", path)
+	gitIn(t, dir, "commit", "-q", "-m", "change "+path)
+}
+
+// setRemote makes origin a remote whose branches are the local ones as of now
+func setRemote(t *testing.T, dir string) {
+	t.Helper()
+	gitIn(t, dir, "remote", "add", "origin", dir)
+	gitIn(t, dir, "fetch", "-q", "origin")
+}
+
+func TestDiffBase(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(t *testing.T, dir string)
+		mode     BaseMode
+		target   string
+		diffType DiffType
+		// want is the ref of the base, "" for none, and mergeBase whether its
+		// commit is the merge-base with HEAD rather than the ref itself
+		want      string
+		mergeBase bool
+		wantErr   error
+	}{
+		{
+			name: "HEAD by default",
+			setup: func(t *testing.T, dir string) {
+				gitIn(t, dir, "checkout", "-q", "-b", "feature")
+				commitFile(t, dir, "f", "feature\n")
+			},
+			want: "",
+		},
+		{
+			name: "PR mode uses the default branch",
+			setup: func(t *testing.T, dir string) {
+				gitIn(t, dir, "checkout", "-q", "-b", "feature")
+				commitFile(t, dir, "f", "feature\n")
+				gitIn(t, dir, "checkout", "-q", "main")
+				commitFile(t, dir, "m", "main moved on\n")
+				gitIn(t, dir, "checkout", "-q", "feature")
+			},
+			mode:      BaseModePR,
+			want:      "main",
+			mergeBase: true,
+		},
+		{
+			name: "PR mode prefers another branch as upstream",
+			setup: func(t *testing.T, dir string) {
+				gitIn(t, dir, "checkout", "-q", "-b", "develop")
+				commitFile(t, dir, "d", "develop\n")
+				gitIn(t, dir, "checkout", "-q", "-b", "feature")
+				gitIn(t, dir, "branch", "-q", "--set-upstream-to", "develop")
+				commitFile(t, dir, "f", "feature\n")
+			},
+			mode:      BaseModePR,
+			want:      "develop",
+			mergeBase: true,
+		},
+		{
+			name: "PR mode ignores the branch's own remote copy",
+			setup: func(t *testing.T, dir string) {
+				gitIn(t, dir, "checkout", "-q", "-b", "feature")
+				commitFile(t, dir, "f", "feature\n")
+				setRemote(t, dir)
+				gitIn(t, dir, "branch", "-q", "--set-upstream-to", "origin/feature")
+				gitIn(t, dir, "branch", "-q", "-D", "main")
+			},
+			mode:      BaseModePR,
+			want:      "origin/main",
+			mergeBase: true,
+		},
+		{
+			name: "PR mode follows the remote's default branch",
+			setup: func(t *testing.T, dir string) {
+				gitIn(t, dir, "branch", "-q", "trunk")
+				setRemote(t, dir)
+				gitIn(t, dir, "symbolic-ref", "refs/remotes/origin/HEAD", "refs/remotes/origin/trunk")
+				gitIn(t, dir, "checkout", "-q", "-b", "feature")
+				commitFile(t, dir, "f", "feature\n")
+			},
+			mode:      BaseModePR,
+			want:      "origin/trunk",
+			mergeBase: true,
+		},
+		{
+			name: "PR mode without a base",
+			setup: func(t *testing.T, dir string) {
+				gitIn(t, dir, "branch", "-q", "-m", "work")
+			},
+			mode:    BaseModePR,
+			wantErr: ErrNoPRBase,
+		},
+		{
+			name: "auto mode on a diverged branch",
+			setup: func(t *testing.T, dir string) {
+				gitIn(t, dir, "checkout", "-q", "-b", "feature")
+				commitFile(t, dir, "f", "feature\n")
+			},
+			mode:      BaseModeAuto,
+			want:      "main",
+			mergeBase: true,
+		},
+		{
+			name:  "auto mode on the default branch",
+			setup: func(t *testing.T, dir string) {},
+			mode:  BaseModeAuto,
+			want:  "",
+		},
+		{
+			name: "auto mode without a base",
+			setup: func(t *testing.T, dir string) {
+				gitIn(t, dir, "branch", "-q", "-m", "work")
+			},
+			mode: BaseModeAuto,
+			want: "",
+		},
+		{
+			name: "a target wins over PR mode",
+			setup: func(t *testing.T, dir string) {
+				gitIn(t, dir, "tag", "v1")
+				commitFile(t, dir, "f", "after the tag\n")
+			},
+			mode:   BaseModePR,
+			target: "v1",
+			want:   "v1",
+		},
+		{
+			name: "since review has no base",
+			setup: func(t *testing.T, dir string) {
+				gitIn(t, dir, "checkout", "-q", "-b", "feature")
+				commitFile(t, dir, "f", "feature\n")
+			},
+			mode:     BaseModePR,
+			diffType: DiffTypeSinceReview,
+			want:     "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := testRepo(t, map[string]string{"a": "a\n"})
+			tt.setup(t, dir)
+			s := testService(t, dir)
+			s.SetBaseMode(tt.mode)
+			s.SetDiffTarget(tt.target)
+			diffType := tt.diffType
+			if diffType == "" {
+				diffType = DiffTypeAll
+			}
+
+			base, err := s.DiffBase(diffType)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("returned %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if base != nil {
+					t.Errorf("base %+v, want none", base)
+				}
+				return
+			}
+			if base == nil {
+				t.Fatalf("no base, want %s", tt.want)
+			}
+
+			commit := strings.TrimSpace(gitIn(t, dir, "rev-parse", tt.want+"^{commit}"))
+			if tt.mergeBase {
+				commit = strings.TrimSpace(gitIn(t, dir, "merge-base", "HEAD", tt.want))
+			}
+			want := DiffBase{Ref: tt.want, Commit: commit, MergeBase: tt.mergeBase}
+			if *base != want {
+				t.Errorf("base %+v, want %+v", *base, want)
+			}
+		})
+	}
+}
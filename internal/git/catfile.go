@@ -0,0 +1,99 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// fileRef names a file at a revision, see readFileAt
+type fileRef struct {
+	rev, path string
+}
+
+// readFiles reads many files at once, like readFileAt but with a single
+// "git cat-file" process for all of them. Files that don't exist at their
+// revision are nil.
+func (s *Service) readFiles(refs []fileRef) ([][]byte, error) {
+	contents := make([][]byte, len(refs))
+
+	var input strings.Builder
+	var batched []int
+	for i, ref := range refs {
+		if ref.rev == revWorkTree {
+			if content, err := os.ReadFile(s.workTreePath(ref.path)); err == nil {
+				contents[i] = content
+			}
+			continue
+		}
+		// cat-file reads one object name per line
+		if strings.ContainsAny(ref.path, "\n\r") {
+			continue
+		}
+		fmt.Fprintf(&input, "%s:%s\n", ref.rev, ref.path)
+		batched = append(batched, i)
+	}
+	if len(batched) == 0 {
+		return contents, nil
+	}
+
+	cmd := s.gitCommand("cat-file", "--batch")
+	cmd.Stdin = strings.NewReader(input.String())
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("git command failed: %w", err)
+	}
+
+	out := bufio.NewReader(stdout)
+	for _, i := range batched {
+		content, err := readBatchObject(out)
+		if err != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return nil, fmt.Errorf("failed to read %s:%s: %w", refs[i].rev, refs[i].path, err)
+		}
+		contents[i] = content
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("git command failed: %s", stderr.String())
+	}
+	return contents, nil
+}
+
+// readBatchObject reads one "git cat-file --batch" response, returning nil
+// for objects that don't exist
+func readBatchObject(out *bufio.Reader) ([]byte, error) {
+	header, err := out.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	// "<oid> <type> <size>", or "<name> missing" and the like for objects
+	// that can't be read, where the name may contain spaces
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, nil
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, nil
+	}
+	content := make([]byte, size+1)
+	if _, err := io.ReadFull(out, content); err != nil {
+		return nil, err
+	}
+	if fields[1] != "blob" {
+		return nil, nil
+	}
+	return content[:size], nil
+}
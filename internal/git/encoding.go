@@ -0,0 +1,272 @@
+package git
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingLatin1      = "iso-8859-1"
+	EncodingWindows1252 = "windows-1252"
+)
+
+// utf8BOM is the byte order mark some editors put at the start of UTF-8 files
+const utf8BOM = "\ufeff"
+
+// windows1252 maps the 0x80-0x9F range, where Windows-1252 differs from
+// ISO-8859-1, to Unicode. Unassigned bytes map to themselves.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// fileSources holds the contents of both sides of a file, a side is nil
+// when the file doesn't exist there or couldn't be read
+type fileSources struct {
+	old, new []byte
+}
+
+// readSources reads both sides of the text files in a diff, so their formats
+// are detected from the whole files. It returns nil for patches, which only
+// carry their hunks.
+func (s *Service) readSources(files []FileDiff, revs Revisions) []fileSources {
+	if s.patch != nil {
+		return nil
+	}
+	sources := make([]fileSources, len(files))
+
+	if s.oldDir != "" {
+		read := func(dir, path string) []byte {
+			content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+			if err != nil {
+				return nil
+			}
+			return content
+		}
+		for i, file := range files {
+			if !file.IsBinary {
+				sources[i] = fileSources{read(s.oldDir, file.oldPath()), read(s.newDir, file.Path)}
+			}
+		}
+		return sources
+	}
+
+	var refs []fileRef
+	var sides []*[]byte
+	for i := range files {
+		file := &files[i]
+		if file.IsBinary {
+			continue
+		}
+		if file.Status != FileStatusAdded {
+			refs = append(refs, fileRef{revs.old, file.oldPath()})
+			sides = append(sides, &sources[i].old)
+		}
+		if file.Status != FileStatusDeleted {
+			refs = append(refs, fileRef{revs.new, file.Path})
+			sides = append(sides, &sources[i].new)
+		}
+	}
+	contents, err := s.readFiles(refs)
+	if err != nil {
+		// Detection falls back to the hunks
+		return nil
+	}
+	for i, content := range contents {
+		*sides[i] = content
+	}
+	return sources
+}
+
+// detectTextFormats works out the line endings, byte order mark and encoding
+// of both sides of every text file, and transcodes lines that aren't valid
+// UTF-8 so they display correctly. Sides are inspected as a whole where
+// sources has their contents, otherwise only their lines in the hunks are.
+func detectTextFormats(files []FileDiff, sources []fileSources) {
+	for i := range files {
+		file := &files[i]
+		var src fileSources
+		if sources != nil {
+			src = sources[i]
+		}
+		if file.IsBinary || (len(file.Hunks) == 0 && src.old == nil && src.new == nil) {
+			continue
+		}
+
+		oldSide := &formatScanner{}
+		newSide := &formatScanner{}
+		if src.old != nil {
+			oldSide.scanContent(src.old)
+		}
+		if src.new != nil {
+			newSide.scanContent(src.new)
+		}
+		for _, hunk := range file.Hunks {
+			for _, line := range hunk.Lines {
+				if line.Type != LineTypeAdded && src.old == nil {
+					oldSide.scan(line.Content, line.OldNumber != nil && *line.OldNumber == 1)
+				}
+				if line.Type != LineTypeDeleted && src.new == nil {
+					newSide.scan(line.Content, line.NewNumber != nil && *line.NewNumber == 1)
+				}
+			}
+		}
+
+		file.OldFormat = oldSide.format()
+		file.NewFormat = newSide.format()
+
+		for hi := range file.Hunks {
+			for li := range file.Hunks[hi].Lines {
+				line := &file.Hunks[hi].Lines[li]
+				format := file.NewFormat
+				if line.Type == LineTypeDeleted {
+					format = file.OldFormat
+				}
+				if format != nil && format.Encoding != EncodingUTF8 && !utf8.ValidString(line.Content) {
+					line.raw = line.Content
+					line.Content = decodeLegacy(line.Content, format.Encoding)
+				}
+			}
+		}
+
+		if src.old != nil && src.new != nil {
+			file.EOLOnly = eolOnlyContent(src.old, src.new)
+		} else {
+			file.EOLOnly = eolOnly(file)
+		}
+	}
+}
+
+// formatScanner collects the line ending, BOM and encoding hints of one side of a file
+type formatScanner struct {
+	lines, crlf int
+	bom         bool
+	invalid, c1 bool
+}
+
+// scan inspects a line without its newline
+func (s *formatScanner) scan(line string, first bool) {
+	s.lines++
+	if strings.HasSuffix(line, "\r") {
+		s.crlf++
+	}
+	if first {
+		s.bom = strings.HasPrefix(line, utf8BOM)
+	}
+	if !utf8.ValidString(line) {
+		s.invalid = true
+		for i := 0; i < len(line); i++ {
+			if c := line[i]; c >= 0x80 && c <= 0x9F {
+				s.c1 = true
+			}
+		}
+	}
+}
+
+// scanContent inspects every line of a file
+func (s *formatScanner) scanContent(content []byte) {
+	text := strings.TrimSuffix(string(content), "\n")
+	if text == "" {
+		return
+	}
+	for i, line := range strings.Split(text, "\n") {
+		s.scan(line, i == 0)
+	}
+}
+
+func (s *formatScanner) format() *TextFormat {
+	if s.lines == 0 {
+		return nil
+	}
+
+	format := &TextFormat{
+		Encoding: EncodingUTF8,
+		BOM:      s.bom,
+	}
+	switch {
+	case s.crlf == s.lines:
+		format.EOL = EOLCRLF
+	case s.crlf > 0:
+		format.EOL = EOLMixed
+	default:
+		format.EOL = EOLLF
+	}
+	// Bytes 0x80-0x9F are control codes in ISO-8859-1, so text using them is
+	// almost certainly Windows-1252
+	if s.invalid {
+		format.Encoding = EncodingLatin1
+		if s.c1 {
+			format.Encoding = EncodingWindows1252
+		}
+	}
+	return format
+}
+
+// decodeLegacy converts ISO-8859-1 or Windows-1252 text to UTF-8
+func decodeLegacy(s, encoding string) string {
+	var b strings.Builder
+	b.Grow(len(s) * 2)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if encoding == EncodingWindows1252 && c >= 0x80 && c <= 0x9F {
+			b.WriteRune(windows1252[c-0x80])
+		} else {
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
+
+// eolOnlyContent reports whether two versions of a file differ in nothing
+// but line endings and byte order marks
+func eolOnlyContent(old, new []byte) bool {
+	normalize := func(content []byte) string {
+		text := strings.ReplaceAll(strings.TrimPrefix(string(content), utf8BOM), "\r\n", "\n")
+		return strings.TrimSuffix(text, "\r")
+	}
+	return !bytes.Equal(old, new) && normalize(old) == normalize(new)
+}
+
+// eolOnly reports whether every change in a file disappears once line
+// endings and byte order marks are ignored. Adding or removing the final
+// newline is still a change.
+func eolOnly(file *FileDiff) bool {
+	if file.Additions == 0 || file.Additions != file.Deletions {
+		return false
+	}
+
+	type normalized struct {
+		content   string
+		noNewline bool
+	}
+	normalize := func(line Line) normalized {
+		return normalized{
+			content:   strings.TrimSuffix(strings.TrimPrefix(line.Content, utf8BOM), "\r"),
+			noNewline: line.NoNewline,
+		}
+	}
+	for _, hunk := range file.Hunks {
+		var deleted, added []normalized
+		for _, line := range hunk.Lines {
+			switch line.Type {
+			case LineTypeDeleted:
+				deleted = append(deleted, normalize(line))
+			case LineTypeAdded:
+				added = append(added, normalize(line))
+			}
+		}
+		if len(deleted) != len(added) {
+			return false
+		}
+		for i := range deleted {
+			if deleted[i] != added[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,239 @@
+package git
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// formatString describes a side's format as "encoding eol", with " bom" when set
+func formatString(format *TextFormat) string {
+	if format == nil {
+		return ""
+	}
+	described := fmt.Sprintf("%s %s", format.Encoding, format.EOL)
+	if format.BOM {
+		described += " bom"
+	}
+	return described
+}
+
+// numbered returns n lines "line 1\n" to "line n\n"
+func numbered(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	return b.String()
+}
+
+func TestDetectTextFormats(t *testing.T) {
+	long := numbered(20)
+	tests := []struct {
+		name     string
+		old, new string
+		ignore   bool
+		// oldFormat and newFormat are formatted by formatString
+		oldFormat, newFormat string
+		eolOnly              bool
+		// changed lists the content of the added lines
+		changed []string
+	}{
+		{
+			name:      "LF to CRLF",
+			old:       "a\nb\n",
+			new:       "a\r\nb\r\n",
+			oldFormat: "utf-8 lf",
+			newFormat: "utf-8 crlf",
+			eolOnly:   true,
+			changed:   []string{"a\r", "b\r"},
+		},
+		{
+			name:      "CRLF to LF",
+			old:       "a\r\nb\r\n",
+			new:       "a\nb\n",
+			oldFormat: "utf-8 crlf",
+			newFormat: "utf-8 lf",
+			eolOnly:   true,
+			changed:   []string{"a", "b"},
+		},
+		{
+			name:      "CRLF line outside the hunks",
+			old:       "first\r\n" + long,
+			new:       "first\r\n" + strings.Replace(long, "line 20", "last", 1),
+			oldFormat: "utf-8 mixed",
+			newFormat: "utf-8 mixed",
+			changed:   []string{"last"},
+		},
+		{
+			name:      "final newline isn't a line ending change",
+			old:       "a\r\nb",
+			new:       "a\nb\n",
+			oldFormat: "utf-8 mixed",
+			newFormat: "utf-8 lf",
+			changed:   []string{"a", "b"},
+		},
+		{
+			name:      "BOM added",
+			old:       "a\nb\n",
+			new:       utf8BOM + "a\nb\n",
+			oldFormat: "utf-8 lf",
+			newFormat: "utf-8 lf bom",
+			eolOnly:   true,
+			changed:   []string{utf8BOM + "a"},
+		},
+		{
+			name:      "BOM removed",
+			old:       utf8BOM + "a\nb\n",
+			new:       "a\nb\n",
+			oldFormat: "utf-8 lf bom",
+			newFormat: "utf-8 lf",
+			eolOnly:   true,
+			changed:   []string{"a"},
+		},
+		{
+			name:      "BOM outside the hunks",
+			old:       utf8BOM + long,
+			new:       utf8BOM + strings.Replace(long, "line 20", "last", 1),
+			oldFormat: "utf-8 lf bom",
+			newFormat: "utf-8 lf bom",
+			changed:   []string{"last"},
+		},
+		{
+			name:      "Latin-1 is transcoded",
+			old:       "caf\xe9\n" + long,
+			new:       "caf\xe9\n" + strings.Replace(long, "line 20", "na\xefve", 1),
+			oldFormat: "iso-8859-1 lf",
+			newFormat: "iso-8859-1 lf",
+			changed:   []string{"naïve"},
+		},
+		{
+			name:      "Windows-1252 is transcoded",
+			old:       "plain\n",
+			new:       "\x93quoted\x94 \x80\n",
+			oldFormat: "utf-8 lf",
+			newFormat: "windows-1252 lf",
+			changed:   []string{"“quoted” €"},
+		},
+		{
+			name:      "converted to UTF-8",
+			old:       "caf\xe9\n",
+			new:       "café\n",
+			oldFormat: "iso-8859-1 lf",
+			newFormat: "utf-8 lf",
+			changed:   []string{"café"},
+		},
+		{
+			name:      "ignoring line endings keeps other changes",
+			old:       "a\r\nb\r\n",
+			new:       "a\nc\n",
+			ignore:    true,
+			oldFormat: "utf-8 crlf",
+			newFormat: "utf-8 lf",
+			changed:   []string{"c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := testRepo(t, map[string]string{"f.txt": tt.old})
+			writeFiles(t, dir, map[string]string{"f.txt": tt.new})
+
+			diff, err := testService(t, dir).GetDiffWithOptions(DiffTypeAll, DiffOptions{Context: 3, IgnoreEOL: tt.ignore})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(diff.Files) != 1 {
+				t.Fatalf("got %d files, want 1", len(diff.Files))
+			}
+			file := diff.Files[0]
+
+			if got := formatString(file.OldFormat); got != tt.oldFormat {
+				t.Errorf("old format %q, want %q", got, tt.oldFormat)
+			}
+			if got := formatString(file.NewFormat); got != tt.newFormat {
+				t.Errorf("new format %q, want %q", got, tt.newFormat)
+			}
+			if file.EOLOnly != tt.eolOnly {
+				t.Errorf("eolOnly = %v, want %v", file.EOLOnly, tt.eolOnly)
+			}
+			var changed []string
+			for _, hunk := range file.Hunks {
+				for _, line := range hunk.Lines {
+					if line.Type == LineTypeAdded {
+						changed = append(changed, line.Content)
+					}
+				}
+			}
+			if !reflect.DeepEqual(changed, tt.changed) {
+				t.Errorf("added lines %q, want %q", changed, tt.changed)
+			}
+		})
+	}
+}
+
+func TestIgnoreEOLView(t *testing.T) {
+	dir := testRepo(t, map[string]string{
+		"crlf.txt": "a\nb\n",
+		"bom.txt":  "a\n",
+		"real.txt": "a\r\nb\r\n",
+	})
+	writeFiles(t, dir, map[string]string{
+		"crlf.txt": "a\r\nb\r\n",
+		"bom.txt":  utf8BOM + "a\n",
+		"real.txt": "a\nc\n",
+	})
+	s := testService(t, dir)
+
+	all, err := s.GetDiffWithOptions(DiffTypeAll, DiffOptions{Context: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, file := range all.Files {
+		paths = append(paths, file.Path)
+	}
+	if want := []string{"bom.txt", "crlf.txt", "real.txt"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("files %q, want %q", paths, want)
+	}
+
+	ignored, err := s.GetDiffWithOptions(DiffTypeAll, DiffOptions{Context: 3, IgnoreEOL: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths = nil
+	for _, file := range ignored.Files {
+		paths = append(paths, file.Path)
+	}
+	if want := []string{"real.txt"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("files ignoring line endings %q, want %q", paths, want)
+	}
+}
+
+// Patches only carry their hunks, so formats come from the lines in them
+func TestDetectTextFormatsFromPatch(t *testing.T) {
+	patch := "diff --git a/f.txt b/f.txt\n--- a/f.txt\n+++ b/f.txt\n" +
+		"@@ -1,2 +1,2 @@\n-a\r\n-caf\xe9\r\n+a\n+caf\xe9\n"
+	s := NewService()
+	if err := s.LoadPatch(strings.NewReader(patch)); err != nil {
+		t.Fatal(err)
+	}
+	diff, err := s.GetDiff(DiffTypeAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := diff.Files[0]
+	if got, want := formatString(file.OldFormat), "iso-8859-1 crlf"; got != want {
+		t.Errorf("old format %q, want %q", got, want)
+	}
+	if got, want := formatString(file.NewFormat), "iso-8859-1 lf"; got != want {
+		t.Errorf("new format %q, want %q", got, want)
+	}
+	if !file.EOLOnly {
+		t.Error("line ending change isn't eolOnly")
+	}
+	if got, want := file.Hunks[0].Lines[3].Content, "café"; got != want {
+		t.Errorf("transcoded %q, want %q", got, want)
+	}
+}
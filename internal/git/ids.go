@@ -0,0 +1,177 @@
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// lineIDContext is how many neighbouring lines on each side go into a line ID
+const lineIDContext = 2
+
+// assignIDs gives every hunk and line an ID derived from the file path and
+// content rather than position, so the ID survives unrelated edits above it.
+//
+// A line's ID covers the lines around it in its side's file, the old one
+// for deleted lines and the new one otherwise, so it's the same however
+// much context the diff was taken with. Lines whose surroundings repeat
+// are told apart by how often they occurred earlier in that file. Without
+// sources, as for patches, only the side's lines in the hunk are used.
+//
+// A hunk's ID covers its changed lines. Hunks close to each other merge as
+// context grows, so a hunk ID belongs to the context it was taken with.
+func assignIDs(files []FileDiff, sources []fileSources) {
+	for fi := range files {
+		file := &files[fi]
+		var src fileSources
+		if sources != nil {
+			src = sources[fi]
+		}
+		oldSide := newIDSide(file.Path, SideOld, src.old)
+		newSide := newIDSide(file.Path, SideNew, src.new)
+
+		seen := make(map[string]int)
+		for hi := range file.Hunks {
+			hunk := &file.Hunks[hi]
+
+			h := sha256.New()
+			h.Write([]byte(file.Path + "\x00hunk"))
+			for _, line := range hunk.Lines {
+				if line.Type != LineTypeContext {
+					h.Write([]byte("\x00" + string(line.Type) + "\x00" + line.Content))
+				}
+			}
+			id := hex.EncodeToString(h.Sum(nil))[:16]
+			seen[id]++
+			if n := seen[id]; n > 1 {
+				id += "-" + strconv.Itoa(n)
+			}
+			hunk.ID = id
+
+			for li := range hunk.Lines {
+				line := &hunk.Lines[li]
+				if line.Type == LineTypeDeleted {
+					line.ID = oldSide.lineID(hunk, li, line.OldNumber)
+				} else {
+					line.ID = newSide.lineID(hunk, li, line.NewNumber)
+				}
+			}
+		}
+	}
+}
+
+// idSide computes the line IDs of one side of a file
+type idSide struct {
+	path string
+	side Side
+	// lines is the side's file, nil when it isn't available
+	lines []string
+	// ordinals numbers each line by how many earlier lines share its
+	// surroundings, it's computed on first use
+	ordinals []int
+	// seen counts IDs made from hunk lines when lines is nil
+	seen map[string]int
+}
+
+func newIDSide(path string, side Side, content []byte) *idSide {
+	s := &idSide{path: path, side: side, seen: make(map[string]int)}
+	if content != nil {
+		s.lines = strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	}
+	return s
+}
+
+// lineID returns the ID of line li of a hunk, number is its line number on this side
+func (s *idSide) lineID(hunk *Hunk, li int, number *int) string {
+	if s.lines != nil && number != nil && *number >= 1 && *number <= len(s.lines) {
+		i := *number - 1
+		id := s.hash(s.lines, i)
+		if s.ordinals == nil {
+			s.countOrdinals()
+		}
+		if n := s.ordinals[i]; n > 1 {
+			id += "-" + strconv.Itoa(n)
+		}
+		return id
+	}
+
+	// Fall back to the lines of this side in the hunk
+	var lines []string
+	index := 0
+	for k, line := range hunk.Lines {
+		if (s.side == SideOld && line.Type == LineTypeAdded) || (s.side == SideNew && line.Type == LineTypeDeleted) {
+			continue
+		}
+		if k == li {
+			index = len(lines)
+		}
+		lines = append(lines, line.Content)
+	}
+	id := s.hash(lines, index)
+	s.seen[id]++
+	if n := s.seen[id]; n > 1 {
+		id += "-" + strconv.Itoa(n)
+	}
+	return id
+}
+
+// hash hashes line i of lines with its neighbours
+func (s *idSide) hash(lines []string, i int) string {
+	h := sha256.New()
+	h.Write([]byte(s.path + "\x00line\x00" + string(s.side)))
+	for k := max(0, i-lineIDContext); k <= min(len(lines)-1, i+lineIDContext); k++ {
+		h.Write([]byte("\x00" + strconv.Itoa(k-i) + "\x00" + lines[k]))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func (s *idSide) countOrdinals() {
+	s.ordinals = make([]int, len(s.lines))
+	counts := make(map[string]int)
+	for i := range s.lines {
+		id := s.hash(s.lines, i)
+		counts[id]++
+		s.ordinals[i] = counts[id]
+	}
+}
+
+// FindHunk looks up a hunk by ID
+func (d *DiffResult) FindHunk(id string) (*FileDiff, *Hunk) {
+	for fi := range d.Files {
+		file := &d.Files[fi]
+		for hi := range file.Hunks {
+			if file.Hunks[hi].ID == id {
+				return file, &file.Hunks[hi]
+			}
+		}
+	}
+	return nil, nil
+}
+
+// FindLine looks up a line by ID
+func (d *DiffResult) FindLine(id string) (*FileDiff, *Line) {
+	for fi := range d.Files {
+		file := &d.Files[fi]
+		for hi := range file.Hunks {
+			for li := range file.Hunks[hi].Lines {
+				if file.Hunks[hi].Lines[li].ID == id {
+					return file, &file.Hunks[hi].Lines[li]
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Position returns the side and line number a line is addressed by,
+// deleted lines by their old number and everything else by the new one
+func (l *Line) Position() (Side, int) {
+	if l.Type == LineTypeDeleted && l.OldNumber != nil {
+		return SideOld, *l.OldNumber
+	}
+	if l.NewNumber != nil {
+		return SideNew, *l.NewNumber
+	}
+	return SideNew, 0
+}
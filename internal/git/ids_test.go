@@ -0,0 +1,141 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// lineKey names a line of a diff by type and numbers, e.g. "+ :12"
+func lineKey(line Line) string {
+	oldNumber, newNumber := "", ""
+	if line.OldNumber != nil {
+		oldNumber = fmt.Sprint(*line.OldNumber)
+	}
+	if line.NewNumber != nil {
+		newNumber = fmt.Sprint(*line.NewNumber)
+	}
+	return fmt.Sprintf("%s %s:%s", line.Type, oldNumber, newNumber)
+}
+
+// lineIDs maps the lines of a file's diff to their IDs, by lineKey or by
+// content when byContent is set
+func lineIDs(t *testing.T, file *FileDiff, byContent bool) map[string]string {
+	t.Helper()
+	ids := make(map[string]string)
+	for _, hunk := range file.Hunks {
+		for _, line := range hunk.Lines {
+			key := lineKey(line)
+			if byContent {
+				key = string(line.Type) + " " + line.Content
+			}
+			if line.ID == "" {
+				t.Errorf("line %s has no ID", key)
+			}
+			ids[key] = line.ID
+		}
+	}
+	return ids
+}
+
+func fileDiff(t *testing.T, s *Service, path string, context int) *FileDiff {
+	t.Helper()
+	file, err := s.GetFileDiff(path, DiffTypeAll, context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLineIDsSurviveEditsAbove(t *testing.T) {
+	original := numbered(40)
+	dir := testRepo(t, map[string]string{"f.txt": original})
+	s := testService(t, dir)
+
+	writeFiles(t, dir, map[string]string{"f.txt": strings.Replace(original, "line 30\n", "changed\n", 1)})
+	before := lineIDs(t, fileDiff(t, s, "f.txt", 3), true)
+
+	// An unrelated edit far above shifts every line number of the hunk
+	edited := strings.Replace(original, "line 2\n", "new\nlines\n", 1)
+	writeFiles(t, dir, map[string]string{"f.txt": strings.Replace(edited, "line 30\n", "changed\n", 1)})
+	after := lineIDs(t, fileDiff(t, s, "f.txt", 3), true)
+
+	for key, id := range before {
+		if after[key] != id {
+			t.Errorf("%q has ID %s after the edit, was %s", key, after[key], id)
+		}
+	}
+}
+
+func TestLineIDsTellIdenticalLinesApart(t *testing.T) {
+	// The same block twice, so even a line's surroundings repeat
+	block := "{\n\tx\n}\n"
+	dir := testRepo(t, map[string]string{"f.txt": block + "a\n" + block})
+	writeFiles(t, dir, map[string]string{"f.txt": "{\n\tx\n}\n" + block + "b\n" + block + block})
+	s := testService(t, dir)
+
+	for _, context := range []int{0, 3, FullContext} {
+		seen := make(map[string]string)
+		for key, id := range lineIDs(t, fileDiff(t, s, "f.txt", context), false) {
+			if other, ok := seen[id]; ok {
+				t.Errorf("context %d: %s and %s share ID %s", context, key, other, id)
+			}
+			seen[id] = key
+		}
+	}
+}
+
+func TestLineIDsMatchAcrossViews(t *testing.T) {
+	// Repeated lines before the change are only in the full view, they
+	// mustn't renumber the lines the -U3 view shows
+	repeated := strings.Repeat("}\n", 8)
+	original := repeated + numbered(20) + repeated + numbered(10)
+	dir := testRepo(t, map[string]string{"f.txt": original, "gone.txt": "a\nb\n"})
+	writeFiles(t, dir, map[string]string{
+		"f.txt":    strings.Replace(original, "line 5\n", "five\n", 2),
+		"new.txt":  "new\n",
+		"gone.txt": "b\n",
+	})
+	s := testService(t, dir)
+
+	for _, path := range []string{"f.txt", "new.txt", "gone.txt"} {
+		t.Run(path, func(t *testing.T) {
+			short := lineIDs(t, fileDiff(t, s, path, 3), false)
+			full := lineIDs(t, fileDiff(t, s, path, FullContext), false)
+			for key, id := range short {
+				if full[key] != id {
+					t.Errorf("%s has ID %s in the full view, %s with 3 lines of context", key, full[key], id)
+				}
+			}
+		})
+	}
+}
+
+func TestLineIDsFromPatch(t *testing.T) {
+	patch := "diff --git a/f b/f\n--- a/f\n+++ b/f\n" +
+		"@@ -1,3 +1,3 @@\n x\n-a\n+b\n x\n" +
+		"@@ -11,3 +11,3 @@\n x\n-a\n+b\n x\n"
+	s := NewService()
+	if err := s.LoadPatch(strings.NewReader(patch)); err != nil {
+		t.Fatal(err)
+	}
+	diff, err := s.GetDiff(DiffTypeAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both hunks are the same, only their order tells them apart
+	seen := make(map[string]string)
+	for key, id := range lineIDs(t, &diff.Files[0], false) {
+		if other, ok := seen[id]; ok {
+			t.Errorf("%s and %s share ID %s", key, other, id)
+		}
+		seen[id] = key
+	}
+	if len(seen) != 8 {
+		t.Errorf("got %d line IDs, want 8", len(seen))
+	}
+	if diff.Files[0].Hunks[0].ID == diff.Files[0].Hunks[1].ID {
+		t.Error("identical hunks share an ID")
+	}
+}
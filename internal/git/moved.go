@@ -0,0 +1,307 @@
+package git
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// movedMinAlnum is the minimum number of alphanumeric characters a block
+	// needs to count as moved, the same threshold "git diff --color-moved" uses
+	movedMinAlnum = 20
+	// movedMaxCandidates caps how many deletions a single line is matched
+	// against, so lines like "return nil" don't make detection quadratic
+	movedMaxCandidates = 64
+	// movedMaxEditGap is how many changed lines may separate two moved
+	// blocks for them to be reported as one block moved with small edits
+	movedMaxEditGap = 3
+)
+
+// lineRef addresses a line in a list of FileDiffs
+type lineRef struct {
+	file, hunk, line int
+}
+
+// segment is a run of consecutive added or deleted lines within a hunk
+type segment struct {
+	file, hunk, start, end int
+}
+
+// movedBlock is a run of added lines and the deleted lines it was moved from
+type movedBlock struct {
+	added, deleted       lineRef
+	addedLen, deletedLen int
+	block                int
+}
+
+// moveDetector finds blocks of deleted lines that were added back elsewhere
+// in the diff, within one file or across files
+type moveDetector struct {
+	files  []FileDiff
+	blocks []movedBlock
+}
+
+// detectMoves marks moved lines across the whole diff, in the spirit of
+// "git diff --color-moved=zebra". Indentation is ignored when matching, so
+// code moved into a different nesting level is still detected.
+func detectMoves(files []FileDiff) {
+	d := &moveDetector{files: files}
+
+	// Index deletions by normalized content for the exact pass
+	deleted := make(map[string][]lineRef)
+	for _, seg := range d.segments(LineTypeDeleted) {
+		for i := seg.start; i < seg.end; i++ {
+			ref := lineRef{seg.file, seg.hunk, i}
+			key := d.key(ref)
+			if alnumCount(key) > 0 && len(deleted[key]) < movedMaxCandidates {
+				deleted[key] = append(deleted[key], ref)
+			}
+		}
+	}
+
+	for _, seg := range d.segments(LineTypeAdded) {
+		for i := seg.start; i < seg.end; {
+			added := lineRef{seg.file, seg.hunk, i}
+			best, bestLen := lineRef{}, 0
+			for _, candidate := range deleted[d.key(added)] {
+				if d.sameChange(candidate, added) {
+					continue
+				}
+				if n := d.matchLength(added, seg.end, candidate); n > bestLen {
+					best, bestLen = candidate, n
+				}
+			}
+
+			if bestLen > 0 && d.alnum(added, bestLen) >= movedMinAlnum {
+				d.markExact(added, best, bestLen)
+				i += bestLen
+				continue
+			}
+			i++
+		}
+	}
+
+	d.mergeEdited()
+	d.renumber()
+}
+
+// segments returns the runs of lines of the given type that aren't marked yet
+func (d *moveDetector) segments(lineType LineType) []segment {
+	var segments []segment
+	for fi := range d.files {
+		for hi, hunk := range d.files[fi].Hunks {
+			start := -1
+			for li := 0; li <= len(hunk.Lines); li++ {
+				inRun := li < len(hunk.Lines) && hunk.Lines[li].Type == lineType && hunk.Lines[li].Move == nil
+				switch {
+				case inRun && start < 0:
+					start = li
+				case !inRun && start >= 0:
+					segments = append(segments, segment{fi, hi, start, li})
+					start = -1
+				}
+			}
+		}
+	}
+	return segments
+}
+
+func (d *moveDetector) line(ref lineRef) *Line {
+	return &d.files[ref.file].Hunks[ref.hunk].Lines[ref.line]
+}
+
+func (d *moveDetector) key(ref lineRef) string {
+	return strings.TrimSpace(d.line(ref).Content)
+}
+
+// sameChange reports whether two lines belong to the same run of changes in
+// a hunk. Lines rewritten in place, for example only to change indentation
+// or line endings, are edits rather than moves.
+func (d *moveDetector) sameChange(a, b lineRef) bool {
+	if a.file != b.file || a.hunk != b.hunk {
+		return false
+	}
+	lines := d.files[a.file].Hunks[a.hunk].Lines
+	for i := min(a.line, b.line); i <= max(a.line, b.line); i++ {
+		if lines[i].Type == LineTypeContext {
+			return false
+		}
+	}
+	return true
+}
+
+// matchLength counts how many lines starting at added match the deletions
+// starting at candidate
+func (d *moveDetector) matchLength(added lineRef, addedEnd int, candidate lineRef) int {
+	lines := d.files[candidate.file].Hunks[candidate.hunk].Lines
+	n := 0
+	for added.line+n < addedEnd && candidate.line+n < len(lines) {
+		del := lineRef{candidate.file, candidate.hunk, candidate.line + n}
+		if d.line(del).Type != LineTypeDeleted || d.line(del).Move != nil {
+			break
+		}
+		if d.key(lineRef{added.file, added.hunk, added.line + n}) != d.key(del) {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+func (d *moveDetector) alnum(start lineRef, n int) int {
+	count := 0
+	for i := 0; i < n; i++ {
+		count += alnumCount(d.line(lineRef{start.file, start.hunk, start.line + i}).Content)
+	}
+	return count
+}
+
+func (d *moveDetector) markExact(added, deleted lineRef, n int) {
+	block := len(d.blocks) + 1
+	for i := 0; i < n; i++ {
+		d.link(lineRef{added.file, added.hunk, added.line + i}, lineRef{deleted.file, deleted.hunk, deleted.line + i}, MoveKindMoved, block)
+	}
+	d.blocks = append(d.blocks, movedBlock{
+		added:      added,
+		deleted:    deleted,
+		addedLen:   n,
+		deletedLen: n,
+		block:      block,
+	})
+}
+
+// link marks a pair of lines as each other's moved counterpart
+func (d *moveDetector) link(added, deleted lineRef, kind MoveKind, block int) {
+	addedLine, deletedLine := d.line(added), d.line(deleted)
+	addedLine.Move = &Move{
+		Kind:  kind,
+		Block: block,
+		Path:  d.files[deleted.file].oldPath(),
+		Line:  *deletedLine.OldNumber,
+	}
+	deletedLine.Move = &Move{
+		Kind:  kind,
+		Block: block,
+		Path:  d.files[added.file].Path,
+		Line:  *addedLine.NewNumber,
+	}
+}
+
+// mergeEdited joins exact blocks that were moved between the same two places
+// and are separated by only a few changed lines into one block moved with edits
+func (d *moveDetector) mergeEdited() {
+	for i := 0; i+1 < len(d.blocks); i++ {
+		prev, next := d.blocks[i], d.blocks[i+1]
+		if prev.added.file != next.added.file || prev.added.hunk != next.added.hunk ||
+			prev.deleted.file != next.deleted.file || prev.deleted.hunk != next.deleted.hunk {
+			continue
+		}
+
+		addedGap := next.added.line - (prev.added.line + prev.addedLen)
+		deletedGap := next.deleted.line - (prev.deleted.line + prev.deletedLen)
+		if addedGap < 0 || deletedGap < 0 || addedGap > movedMaxEditGap || deletedGap > movedMaxEditGap {
+			continue
+		}
+		if !d.unmarked(prev.added, prev.addedLen, addedGap, LineTypeAdded) ||
+			!d.unmarked(prev.deleted, prev.deletedLen, deletedGap, LineTypeDeleted) {
+			continue
+		}
+
+		merged := movedBlock{
+			added:      prev.added,
+			deleted:    prev.deleted,
+			addedLen:   prev.addedLen + addedGap + next.addedLen,
+			deletedLen: prev.deletedLen + deletedGap + next.deletedLen,
+			block:      prev.block,
+		}
+
+		// Pair up the edited lines in between, extra ones point at the first
+		// line after the previous block on the other side
+		addedGapStart := lineRef{prev.added.file, prev.added.hunk, prev.added.line + prev.addedLen}
+		deletedGapStart := lineRef{prev.deleted.file, prev.deleted.hunk, prev.deleted.line + prev.deletedLen}
+		for k := 0; k < max(addedGap, deletedGap); k++ {
+			added := lineRef{addedGapStart.file, addedGapStart.hunk, addedGapStart.line + k}
+			deleted := lineRef{deletedGapStart.file, deletedGapStart.hunk, deletedGapStart.line + k}
+			switch {
+			case k < addedGap && k < deletedGap:
+				d.link(added, deleted, MoveKindMovedEdited, merged.block)
+			case k < addedGap:
+				d.point(added, d.files[deleted.file].oldPath(), *d.line(deletedGapStart).OldNumber, merged.block)
+			default:
+				d.point(deleted, d.files[added.file].Path, *d.line(addedGapStart).NewNumber, merged.block)
+			}
+		}
+
+		d.relabel(merged.added, merged.addedLen, merged.block)
+		d.relabel(merged.deleted, merged.deletedLen, merged.block)
+		d.blocks[i+1] = merged
+	}
+}
+
+// unmarked reports whether the n lines following a block are unmarked lines of the given type
+func (d *moveDetector) unmarked(start lineRef, blockLen, n int, lineType LineType) bool {
+	for k := 0; k < n; k++ {
+		line := d.line(lineRef{start.file, start.hunk, start.line + blockLen + k})
+		if line.Type != lineType || line.Move != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// point marks a line as part of a block edited while moving, pointing at path:line
+func (d *moveDetector) point(ref lineRef, path string, line, block int) {
+	d.line(ref).Move = &Move{
+		Kind:  MoveKindMovedEdited,
+		Block: block,
+		Path:  path,
+		Line:  line,
+	}
+}
+
+// relabel marks n lines from start as one block moved with edits
+func (d *moveDetector) relabel(start lineRef, n, block int) {
+	for k := 0; k < n; k++ {
+		move := d.line(lineRef{start.file, start.hunk, start.line + k}).Move
+		move.Kind = MoveKindMovedEdited
+		move.Block = block
+	}
+}
+
+// renumber makes block numbers consecutive again after merging, so adjacent
+// blocks keep alternating for zebra coloring
+func (d *moveDetector) renumber() {
+	numbers := make(map[int]int)
+	for _, b := range d.blocks {
+		if _, seen := numbers[b.block]; !seen {
+			numbers[b.block] = len(numbers) + 1
+		}
+	}
+
+	for fi := range d.files {
+		for hi := range d.files[fi].Hunks {
+			for li := range d.files[fi].Hunks[hi].Lines {
+				if move := d.files[fi].Hunks[hi].Lines[li].Move; move != nil {
+					move.Block = numbers[move.Block]
+				}
+			}
+		}
+	}
+}
+
+func (f *FileDiff) oldPath() string {
+	if f.OldPath != "" {
+		return f.OldPath
+	}
+	return f.Path
+}
+
+func alnumCount(s string) int {
+	count := 0
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,147 @@
+package git
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// moves lists the moved lines of a diff as "path:side line -> kind block path:line"
+func moves(files []FileDiff) []string {
+	var moved []string
+	for _, file := range files {
+		for _, hunk := range file.Hunks {
+			for _, line := range hunk.Lines {
+				if line.Move == nil {
+					continue
+				}
+				side, number := line.Position()
+				moved = append(moved, fmt.Sprintf("%s:%s%d -> %s %d %s:%d",
+					file.Path, side, number, line.Move.Kind, line.Move.Block, line.Move.Path, line.Move.Line))
+			}
+		}
+	}
+	return moved
+}
+
+func TestDetectMoves(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+		want []string
+	}{
+		{
+			name: "block moved down a file",
+			diff: `diff --git a/f.go b/f.go
+--- a/f.go
++++ b/f.go
+@@ -1,6 +1,6 @@
+-func helper() int {
+-	return computeTheAnswer()
+-}
+ func main() {
+ 	run()
+ }
++func helper() int {
++	return computeTheAnswer()
++}
+`,
+			want: []string{
+				"f.go:old1 -> moved 1 f.go:4",
+				"f.go:old2 -> moved 1 f.go:5",
+				"f.go:old3 -> moved 1 f.go:6",
+				"f.go:new4 -> moved 1 f.go:1",
+				"f.go:new5 -> moved 1 f.go:2",
+				"f.go:new6 -> moved 1 f.go:3",
+			},
+		},
+		{
+			name: "block moved to another file and reindented",
+			diff: `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -1,3 +1,1 @@
+ package a
+-	configuration := loadConfiguration()
+-	validateConfiguration(configuration)
+diff --git a/b.go b/b.go
+--- a/b.go
++++ b/b.go
+@@ -1,1 +1,3 @@
+ package b
++		configuration := loadConfiguration()
++		validateConfiguration(configuration)
+`,
+			want: []string{
+				"a.go:old2 -> moved 1 b.go:2",
+				"a.go:old3 -> moved 1 b.go:3",
+				"b.go:new2 -> moved 1 a.go:2",
+				"b.go:new3 -> moved 1 a.go:3",
+			},
+		},
+		{
+			name: "short lines are not moves",
+			diff: `diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -1,3 +1,3 @@
+-}
+ x
+ y
++}
+`,
+			want: nil,
+		},
+		{
+			name: "lines rewritten in place are edits",
+			diff: `diff --git a/f.go b/f.go
+--- a/f.go
++++ b/f.go
+@@ -1,2 +1,2 @@
+-if configurationIsValid(settings) {
++	if configurationIsValid(settings) {
+ }
+`,
+			want: nil,
+		},
+		{
+			name: "block moved with an edited line",
+			diff: `diff --git a/f.go b/f.go
+--- a/f.go
++++ b/f.go
+@@ -1,8 +1,8 @@
+-first := computeFirstValue()
+-second := computeSecondValue(first)
+-third := computeThirdValue(second)
+ keep()
+ keep()
++first := computeFirstValue()
++second := computeSecondValue(first, options)
++third := computeThirdValue(second)
+ keep()
+`,
+			want: []string{
+				"f.go:old1 -> moved-edited 1 f.go:3",
+				"f.go:old2 -> moved-edited 1 f.go:4",
+				"f.go:old3 -> moved-edited 1 f.go:5",
+				"f.go:new3 -> moved-edited 1 f.go:1",
+				"f.go:new4 -> moved-edited 1 f.go:2",
+				"f.go:new5 -> moved-edited 1 f.go:3",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := newDiffParser(strings.NewReader(tt.diff)).parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			detectMoves(files)
+			if got := moves(files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("moves\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,365 @@
+package git
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// revWorkTree reads files from the working tree
+	revWorkTree = ""
+	// revIndex reads files from the index (stage 0)
+	revIndex = ":0"
+)
+
+// Revisions are the old and new side a diff compares. Resolving them can
+// take several git calls in PR mode, so callers reading many files resolve
+// them once and pass them along.
+type Revisions struct {
+	old, new string
+}
+
+// rev returns the revision of one side
+func (r Revisions) rev(side Side) string {
+	if side == SideOld {
+		return r.old
+	}
+	return r.new
+}
+
+// Revisions resolves the sides compared by a diff type
+func (s *Service) Revisions(diffType DiffType) (Revisions, error) {
+	base, err := s.DiffBase(diffType)
+	if err != nil {
+		return Revisions{}, err
+	}
+	return s.revisions(diffType, base), nil
+}
+
+// DiffRevisions returns the sides compared by an already computed diff
+func (s *Service) DiffRevisions(diff *DiffResult) Revisions {
+	return s.revisions(diff.Type, diff.Base)
+}
+
+// revisions returns the sides compared by a diff type against base
+func (s *Service) revisions(diffType DiffType, base *DiffBase) Revisions {
+	snapshot := s.ReviewSnapshot()
+	switch {
+	case diffType == DiffTypeSinceReview && snapshot != nil:
+		return Revisions{snapshot.Tree, revWorkTree}
+	case base != nil:
+		return Revisions{base.rev(), revWorkTree}
+	case diffType == DiffTypeStaged:
+		return Revisions{"HEAD", revIndex}
+	case diffType == DiffTypeUnstaged:
+		return Revisions{revIndex, revWorkTree}
+	default:
+		return Revisions{"HEAD", revWorkTree}
+	}
+}
+
+// readFileAt reads a file at the given revision
+func (s *Service) readFileAt(rev, path string) ([]byte, error) {
+	if rev == revWorkTree {
+		return os.ReadFile(s.workTreePath(path))
+	}
+
+	content, err := s.runGitCommand("show", fmt.Sprintf("%s:%s", rev, path))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(content), nil
+}
+
+// ReadFile reads a file as it is on one side of a diff
+func (s *Service) ReadFile(revs Revisions, side Side, path string) ([]byte, error) {
+	if s.patch != nil || s.oldDir != "" {
+		return nil, ErrReadOnly
+	}
+	return s.readFileAt(revs.rev(side), path)
+}
+
+// ListDir returns the paths of the files directly inside dir on one side of a diff
+func (s *Service) ListDir(revs Revisions, side Side, dir string) ([]string, error) {
+	if s.patch != nil || s.oldDir != "" {
+		return nil, ErrReadOnly
+	}
+	rev := revs.rev(side)
+
+	var paths []string
+	switch rev {
+	case revWorkTree:
+		entries, err := os.ReadDir(s.workTreePath(dir))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				paths = append(paths, path.Join(dir, entry.Name()))
+			}
+		}
+	case revIndex:
+		output, err := s.runGitCommand("ls-files", "--", dir+"/")
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range strings.Split(strings.TrimSpace(output), "\n") {
+			if p != "" && path.Dir(p) == dir {
+				paths = append(paths, p)
+			}
+		}
+	default:
+		output, err := s.runGitCommand("ls-tree", "--name-only", rev, "--", dir+"/")
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range strings.Split(strings.TrimSpace(output), "\n") {
+			if p != "" {
+				paths = append(paths, p)
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+// GoModulePath returns the module path declared in go.mod on the new side
+// of a diff, or "" outside of a Go module
+func (s *Service) GoModulePath(revs Revisions) string {
+	content, err := s.ReadFile(revs, SideNew, "go.mod")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+	return ""
+}
+
+// GetOutlines returns the changed symbol outline of each Go file in a diff,
+// keyed by path. Only Go files are diffed, without context, and both sides
+// of them are read by a single git process, so it's cheap enough to run
+// alongside the diff listing.
+func (s *Service) GetOutlines(diffType DiffType) (map[string][]Symbol, error) {
+	if s.patch != nil || s.oldDir != "" {
+		return nil, ErrReadOnly
+	}
+	base, err := s.DiffBase(diffType)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []FileDiff
+	err = s.readDiff(diffType, base, DiffOptions{Paths: []string{"*.go"}}, func(file *FileDiff) error {
+		files = append(files, *file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.addOutlines(files, s.revisions(diffType, base))
+
+	outlines := make(map[string][]Symbol, len(files))
+	for _, file := range files {
+		if len(file.Outline) > 0 {
+			outlines[file.Path] = file.Outline
+		}
+	}
+	return outlines, nil
+}
+
+// addOutlines fills in the changed symbol outline of the Go files in a diff
+func (s *Service) addOutlines(files []FileDiff, revs Revisions) {
+	var goFiles []*FileDiff
+	var refs []fileRef
+	for i := range files {
+		file := &files[i]
+		if file.IsBinary || !strings.HasSuffix(file.Path, ".go") {
+			continue
+		}
+		oldPath := file.Path
+		if file.OldPath != "" {
+			oldPath = file.OldPath
+		}
+		goFiles = append(goFiles, file)
+		refs = append(refs, fileRef{revs.old, oldPath}, fileRef{revs.new, file.Path})
+	}
+	if len(goFiles) == 0 {
+		return
+	}
+
+	contents, err := s.readFiles(refs)
+	if err != nil {
+		return
+	}
+	for i, file := range goFiles {
+		var oldSrc, newSrc []byte
+		if file.Status != FileStatusAdded {
+			oldSrc = contents[2*i]
+		}
+		if file.Status != FileStatusDeleted {
+			newSrc = contents[2*i+1]
+		}
+
+		outline, err := goOutline(file, oldSrc, newSrc)
+		if err != nil {
+			// Files mid-edit often don't parse, leave them without an outline
+			continue
+		}
+		file.Outline = outline
+	}
+}
+
+// goDecl is a top-level declaration and the lines it spans
+type goDecl struct {
+	name       string
+	kind       SymbolKind
+	start, end int
+}
+
+// goOutline compares the declarations of both revisions of a Go file and
+// returns the ones the diff adds, removes or touches
+func goOutline(file *FileDiff, oldSrc, newSrc []byte) ([]Symbol, error) {
+	oldDecls, err := goDecls(oldSrc)
+	if err != nil {
+		return nil, err
+	}
+	newDecls, err := goDecls(newSrc)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := make(map[int]bool)
+	added := make(map[int]bool)
+	for _, hunk := range file.Hunks {
+		for _, line := range hunk.Lines {
+			switch line.Type {
+			case LineTypeDeleted:
+				deleted[*line.OldNumber] = true
+			case LineTypeAdded:
+				added[*line.NewNumber] = true
+			}
+		}
+	}
+
+	oldByName := make(map[string]goDecl, len(oldDecls))
+	for _, decl := range oldDecls {
+		oldByName[string(decl.kind)+" "+decl.name] = decl
+	}
+
+	var outline []Symbol
+	for _, decl := range newDecls {
+		key := string(decl.kind) + " " + decl.name
+		old, existed := oldByName[key]
+		delete(oldByName, key)
+
+		switch {
+		case !existed:
+			outline = append(outline, Symbol{Name: decl.name, Kind: decl.kind, Change: SymbolAdded, Line: decl.start})
+		case touches(added, decl.start, decl.end) || touches(deleted, old.start, old.end):
+			outline = append(outline, Symbol{Name: decl.name, Kind: decl.kind, Change: SymbolModified, Line: decl.start})
+		}
+	}
+	for _, decl := range oldByName {
+		outline = append(outline, Symbol{Name: decl.name, Kind: decl.kind, Change: SymbolRemoved, Line: decl.start})
+	}
+
+	sort.SliceStable(outline, func(i, j int) bool {
+		return outline[i].Line < outline[j].Line
+	})
+	return outline, nil
+}
+
+func touches(lines map[int]bool, start, end int) bool {
+	for line := range lines {
+		if line >= start && line <= end {
+			return true
+		}
+	}
+	return false
+}
+
+// goDecls lists the functions, methods and types declared in a Go file
+func goDecls(src []byte) ([]goDecl, error) {
+	if src == nil {
+		return nil, nil
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	var decls []goDecl
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			start := d.Pos()
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+			item := goDecl{
+				name:  d.Name.Name,
+				kind:  SymbolKindFunc,
+				start: fset.Position(start).Line,
+				end:   fset.Position(d.End()).Line,
+			}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				item.kind = SymbolKindMethod
+				item.name = receiverName(d.Recv.List[0].Type) + "." + d.Name.Name
+			}
+			decls = append(decls, item)
+
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+				// A lone type owns the whole declaration including its doc comment
+				var start, end token.Pos = ts.Pos(), ts.End()
+				if len(d.Specs) == 1 {
+					start, end = d.Pos(), d.End()
+					if d.Doc != nil {
+						start = d.Doc.Pos()
+					}
+				} else if ts.Doc != nil {
+					start = ts.Doc.Pos()
+				}
+				decls = append(decls, goDecl{
+					name:  ts.Name.Name,
+					kind:  SymbolKindType,
+					start: fset.Position(start).Line,
+					end:   fset.Position(end).Line,
+				})
+			}
+		}
+	}
+
+	return decls, nil
+}
+
+// receiverName returns the type name of a method receiver, e.g. "Service"
+// for "(s *Service)" or "List" for "(l *List[T])"
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
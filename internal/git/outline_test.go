@@ -0,0 +1,172 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// diffSources diffs two versions of a file the way git would
+func diffSources(t *testing.T, oldSrc, newSrc string) *FileDiff {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a/f.go": oldSrc, "b/f.go": newSrc})
+	cmd := exec.Command("git", "diff", "--no-index", "--no-color", "-U0", "a/f.go", "b/f.go")
+	cmd.Dir = dir
+	output, _ := cmd.Output()
+	files, err := newDiffParser(strings.NewReader(string(output))).parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		return &FileDiff{Path: "f.go", Status: FileStatusModified}
+	}
+	return &files[0]
+}
+
+// symbols formats an outline as "change kind name@line"
+func symbols(outline []Symbol) []string {
+	var formatted []string
+	for _, symbol := range outline {
+		formatted = append(formatted, fmt.Sprintf("%s %s %s@%d", symbol.Change, symbol.Kind, symbol.Name, symbol.Line))
+	}
+	return formatted
+}
+
+func TestGoOutline(t *testing.T) {
+	const base = `package p
+
+// Config is configuration
+type Config struct {
+	Name string
+}
+
+func helper() int {
+	return 1
+}
+
+func (c *Config) Load() error {
+	return nil
+}
+
+func untouched() {}
+`
+
+	tests := []struct {
+		name string
+		new  string
+		want []string
+	}{
+		{
+			name: "unchanged",
+			new:  base,
+			want: nil,
+		},
+		{
+			name: "function body changed",
+			new:  strings.Replace(base, "return 1", "return 2", 1),
+			want: []string{"modified func helper@8"},
+		},
+		{
+			name: "doc comment counts as the type",
+			new:  strings.Replace(base, "is configuration", "holds the settings", 1),
+			want: []string{"modified type Config@3"},
+		},
+		{
+			name: "method changed",
+			new:  strings.Replace(base, "return nil", "return c.check()", 1),
+			want: []string{"modified method Config.Load@12"},
+		},
+		{
+			name: "added and removed",
+			new:  strings.Replace(base, "func untouched() {}\n", "func added() {}\n\ntype List[T any] []T\n\nfunc (l *List[T]) Len() int { return len(*l) }\n", 1),
+			want: []string{"added func added@16", "removed func untouched@16", "added type List@18", "added method List.Len@20"},
+		},
+		{
+			name: "renamed is removed and added",
+			new:  strings.Replace(base, "func helper()", "func compute()", 1),
+			want: []string{"added func compute@8", "removed func helper@8"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outline, err := goOutline(diffSources(t, base, tt.new), []byte(base), []byte(tt.new))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := symbols(outline); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("outline\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoOutlineUnparsable(t *testing.T) {
+	if _, err := goOutline(&FileDiff{}, []byte("package p\n"), []byte("package p\nfunc {")); err == nil {
+		t.Error("outlined a file that doesn't parse")
+	}
+}
+
+func TestGetOutlines(t *testing.T) {
+	dir := testRepo(t, map[string]string{
+		"a.go":       "package p\n\nfunc A() {}\n",
+		"sub/b.go":   "package sub\n\nfunc B() {}\n",
+		"gone.go":    "package p\n\nfunc Gone() {}\n",
+		"README.md":  "readme\n",
+		"staged.go":  "package p\n\nfunc S() {}\n",
+		"broken.go":  "package p\n",
+		"same.go":    "package p\n\nfunc Same() {}\n",
+		"renamed.go": "package p\n\nfunc Renamed() {}\n",
+	})
+	writeFiles(t, dir, map[string]string{
+		"a.go":      "package p\n\nfunc A() { _ = 1 }\n",
+		"sub/b.go":  "package sub\n\nfunc B() {}\n\nfunc C() {}\n",
+		"new.go":    "package p\n\ntype T int\n",
+		"README.md": "changed\n",
+		"staged.go": "package p\n\nfunc S() { _ = 2 }\n",
+		"broken.go": "package p\nfunc {\n",
+	})
+	if err := os.Remove(filepath.Join(dir, "gone.go")); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "add", "staged.go")
+	gitIn(t, dir, "mv", "renamed.go", "moved.go")
+
+	s := testService(t, dir)
+	tests := []struct {
+		diffType DiffType
+		want     map[string][]string
+	}{
+		{DiffTypeAll, map[string][]string{
+			"a.go":      {"modified func A@3"},
+			"sub/b.go":  {"added func C@5"},
+			"new.go":    {"added type T@3"},
+			"gone.go":   {"removed func Gone@3"},
+			"staged.go": {"modified func S@3"},
+		}},
+		{DiffTypeStaged, map[string][]string{
+			"staged.go": {"modified func S@3"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.diffType), func(t *testing.T) {
+			outlines, err := s.GetOutlines(tt.diffType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string][]string)
+			for path, outline := range outlines {
+				got[path] = symbols(outline)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("outlines\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
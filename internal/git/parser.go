@@ -1,39 +1,95 @@
 package git
 
 import (
+	"bufio"
+	"errors"
+	"io"
 	"regexp"
 	"strconv"
 	"strings"
 )
 
+var (
+	diffGitPattern = regexp.MustCompile(`diff --git [a-z]/(.*) [a-z]/(.*)`)
+	hunkPattern    = regexp.MustCompile(`@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@(.*)`)
+)
+
+// diffParser reads a diff line by line and emits one file at a time, so
+// memory use is bounded by the largest file rather than the whole diff
 type diffParser struct {
-	lines   []string
-	current int
+	reader *bufio.Reader
+	// pending holds lines read ahead of the current one
+	pending []string
+	err     error
 }
 
-func newDiffParser(diff string) *diffParser {
+func newDiffParser(r io.Reader) *diffParser {
 	return &diffParser{
-		lines: strings.Split(diff, "\n"),
+		reader: bufio.NewReaderSize(r, 64*1024),
 	}
 }
 
+// parse reads every remaining file
 func (p *diffParser) parse() ([]FileDiff, error) {
-	var files []FileDiff
+	files := []FileDiff{}
+	for {
+		file, err := p.next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, *file)
+	}
+}
 
-	for p.current < len(p.lines) {
-		line := p.lines[p.current]
+// next returns the next file in the diff, or io.EOF once the input is exhausted
+func (p *diffParser) next() (*FileDiff, error) {
+	for {
+		line, ok := p.peek(0)
+		if !ok {
+			if p.err != nil && !errors.Is(p.err, io.EOF) {
+				return nil, p.err
+			}
+			return nil, io.EOF
+		}
 
-		if strings.HasPrefix(line, "diff --git") {
-			file := p.parseFile()
-			if file != nil {
-				files = append(files, *file)
+		switch {
+		case strings.HasPrefix(line, "diff --git"):
+			return p.parseFile(), nil
+		case p.atUnifiedHeader():
+			// Plain "diff -u" output has no "diff --git" line, only ---/+++ headers
+			return p.parseUnifiedFile(), nil
+		default:
+			p.advance()
+		}
+	}
+}
+
+// peek returns the line n lines after the current one without consuming it
+func (p *diffParser) peek(n int) (string, bool) {
+	for len(p.pending) <= n && p.err == nil {
+		line, err := p.reader.ReadString('\n')
+		if err != nil {
+			p.err = err
+			if line == "" {
+				break
 			}
-		} else {
-			p.current++
 		}
+		p.pending = append(p.pending, strings.TrimSuffix(line, "\n"))
+	}
+	if len(p.pending) <= n {
+		return "", false
 	}
+	return p.pending[n], true
+}
 
-	return files, nil
+// advance moves past the current line
+func (p *diffParser) advance() {
+	if len(p.pending) > 0 {
+		p.pending = p.pending[1:]
+	}
 }
 
 func (p *diffParser) parseFile() *FileDiff {
@@ -41,16 +97,24 @@ func (p *diffParser) parseFile() *FileDiff {
 		Hunks: []Hunk{},
 	}
 
-	diffLine := p.lines[p.current]
-	paths := regexp.MustCompile(`diff --git [a-z]/(.*) [a-z]/(.*)`).FindStringSubmatch(diffLine)
+	diffLine, _ := p.peek(0)
+	file.header = []string{diffLine}
+	paths := diffGitPattern.FindStringSubmatch(diffLine)
 	if len(paths) >= 3 {
 		file.OldPath = paths[1]
 		file.Path = paths[2]
 	}
-	p.current++
+	p.advance()
 
-	for p.current < len(p.lines) && !strings.HasPrefix(p.lines[p.current], "diff --git") {
-		line := p.lines[p.current]
+	for {
+		line, ok := p.peek(0)
+		if !ok || strings.HasPrefix(line, "diff --git") {
+			break
+		}
+
+		if len(file.Hunks) == 0 && !strings.HasPrefix(line, "@@") {
+			file.header = append(file.header, line)
+		}
 
 		switch {
 		case strings.HasPrefix(line, "new file"):
@@ -68,13 +132,112 @@ func (p *diffParser) parseFile() *FileDiff {
 				continue
 			}
 		}
-		p.current++
+		p.advance()
 	}
 
 	if file.Status == "" {
 		file.Status = FileStatusModified
 	}
 
+	countChanges(file)
+
+	return file
+}
+
+// atUnifiedHeader reports whether the current line starts a file in plain
+// unified diff format ("--- old", "+++ new", "@@ ...").
+func (p *diffParser) atUnifiedHeader() bool {
+	oldHeader, _ := p.peek(0)
+	newHeader, _ := p.peek(1)
+	hunkHeader, _ := p.peek(2)
+	return strings.HasPrefix(oldHeader, "--- ") &&
+		strings.HasPrefix(newHeader, "+++ ") &&
+		strings.HasPrefix(hunkHeader, "@@")
+}
+
+// parseUnifiedFile parses a file from plain "diff -u" output
+func (p *diffParser) parseUnifiedFile() *FileDiff {
+	file := &FileDiff{
+		Hunks: []Hunk{},
+	}
+
+	oldHeader, _ := p.peek(0)
+	newHeader, _ := p.peek(1)
+	file.header = []string{oldHeader, newHeader}
+	oldPath := unifiedHeaderPath(strings.TrimPrefix(oldHeader, "--- "))
+	newPath := unifiedHeaderPath(strings.TrimPrefix(newHeader, "+++ "))
+	p.advance()
+	p.advance()
+
+	for {
+		if line, ok := p.peek(0); !ok || !strings.HasPrefix(line, "@@") {
+			break
+		}
+		hunk := p.parseHunk()
+		if hunk == nil {
+			break
+		}
+		file.Hunks = append(file.Hunks, *hunk)
+	}
+
+	// "diff -ruN old/ new/" prefixes every path with its root directory,
+	// strip it the same way "patch -p1" would. Against /dev/null only git's
+	// a/ and b/ prefixes are recognizable as such.
+	switch {
+	case oldPath == "/dev/null":
+		newPath = stripPrefix(newPath, "b/")
+	case newPath == "/dev/null":
+		oldPath = stripPrefix(oldPath, "a/")
+	case strings.Contains(oldPath, "/") && strings.Contains(newPath, "/"):
+		oldPath = oldPath[strings.Index(oldPath, "/")+1:]
+		newPath = newPath[strings.Index(newPath, "/")+1:]
+	}
+
+	switch {
+	case oldPath == "/dev/null" || onlyNewSide(file.Hunks):
+		file.Status = FileStatusAdded
+		file.Path = newPath
+	case newPath == "/dev/null" || onlyOldSide(file.Hunks):
+		file.Status = FileStatusDeleted
+		file.Path = oldPath
+		file.OldPath = oldPath
+	default:
+		file.Status = FileStatusModified
+		file.Path = newPath
+		file.OldPath = oldPath
+	}
+
+	countChanges(file)
+
+	return file
+}
+
+// unifiedHeaderPath extracts the path from a ---/+++ header line, dropping
+// the optional tab-separated timestamp
+func unifiedHeaderPath(header string) string {
+	if i := strings.IndexByte(header, '\t'); i >= 0 {
+		header = header[:i]
+	}
+	return strings.TrimSpace(header)
+}
+
+// stripPrefix removes prefix from a path that has more after it
+func stripPrefix(path, prefix string) string {
+	if strings.HasPrefix(path, prefix) && len(path) > len(prefix) {
+		return path[len(prefix):]
+	}
+	return path
+}
+
+func onlyNewSide(hunks []Hunk) bool {
+	return len(hunks) == 1 && hunks[0].OldStart == 0 && hunks[0].OldLines == 0
+}
+
+func onlyOldSide(hunks []Hunk) bool {
+	return len(hunks) == 1 && hunks[0].NewStart == 0 && hunks[0].NewLines == 0
+}
+
+func countChanges(file *FileDiff) {
 	for _, hunk := range file.Hunks {
 		for _, line := range hunk.Lines {
 			switch line.Type {
@@ -85,20 +248,19 @@ func (p *diffParser) parseFile() *FileDiff {
 			}
 		}
 	}
-
-	return file
 }
 
 func (p *diffParser) parseHunk() *Hunk {
-	header := p.lines[p.current]
-	matches := regexp.MustCompile(`@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@(.*)`).FindStringSubmatch(header)
-	if len(matches) < 5 {
+	header, _ := p.peek(0)
+	matches := hunkPattern.FindStringSubmatch(header)
+	if len(matches) < 6 {
 		return nil
 	}
 
 	hunk := &Hunk{
-		Header: header,
-		Lines:  []Line{},
+		Header:  header,
+		Section: strings.TrimSpace(matches[5]),
+		Lines:   []Line{},
 	}
 
 	hunk.OldStart, _ = strconv.Atoi(matches[1])
@@ -115,20 +277,33 @@ func (p *diffParser) parseHunk() *Hunk {
 		hunk.NewLines = 1
 	}
 
-	p.current++
+	p.advance()
 
 	oldLine := hunk.OldStart
 	newLine := hunk.NewStart
 
-	for p.current < len(p.lines) {
-		if p.current >= len(p.lines) || strings.HasPrefix(p.lines[p.current], "@@") || strings.HasPrefix(p.lines[p.current], "diff --git") {
+	// Track how many lines the header promised so trailing text in patch
+	// files (mail signatures, the next commit message) isn't read as content
+	oldRemaining := hunk.OldLines
+	newRemaining := hunk.NewLines
+
+	for {
+		line, ok := p.peek(0)
+		if !ok || strings.HasPrefix(line, "@@") || strings.HasPrefix(line, "diff --git") {
 			break
 		}
 
-		line := p.lines[p.current]
+		if oldRemaining <= 0 && newRemaining <= 0 && !strings.HasPrefix(line, "\\") {
+			break
+		}
 		if len(line) == 0 {
-			p.current++
-			continue
+			if oldRemaining > 0 && newRemaining > 0 {
+				// Some mailers strip the leading space of empty context lines
+				line = " "
+			} else {
+				p.advance()
+				continue
+			}
 		}
 
 		lineObj := Line{
@@ -141,12 +316,14 @@ func (p *diffParser) parseHunk() *Hunk {
 			num := newLine
 			lineObj.NewNumber = &num
 			newLine++
+			newRemaining--
 			lineObj.Content = line[1:]
 		case '-':
 			lineObj.Type = LineTypeDeleted
 			num := oldLine
 			lineObj.OldNumber = &num
 			oldLine++
+			oldRemaining--
 			lineObj.Content = line[1:]
 		case ' ':
 			lineObj.Type = LineTypeContext
@@ -156,17 +333,23 @@ func (p *diffParser) parseHunk() *Hunk {
 			lineObj.NewNumber = &newNum
 			oldLine++
 			newLine++
+			oldRemaining--
+			newRemaining--
 			lineObj.Content = line[1:]
 		case '\\':
-			p.current++
+			// "\ No newline at end of file" applies to the line before it
+			if n := len(hunk.Lines); n > 0 {
+				hunk.Lines[n-1].NoNewline = true
+			}
+			p.advance()
 			continue
 		default:
-			p.current++
+			p.advance()
 			continue
 		}
 
 		hunk.Lines = append(hunk.Lines, lineObj)
-		p.current++
+		p.advance()
 	}
 
 	return hunk
@@ -0,0 +1,448 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// fileSummary is what the parser tests compare of a parsed file
+type fileSummary struct {
+	Path, OldPath        string
+	Status               FileStatus
+	Additions, Deletions int
+	Binary               bool
+	Hunks                []string
+}
+
+func summarize(files []FileDiff) []fileSummary {
+	summaries := make([]fileSummary, 0, len(files))
+	for _, file := range files {
+		summary := fileSummary{
+			Path:      file.Path,
+			OldPath:   file.OldPath,
+			Status:    file.Status,
+			Additions: file.Additions,
+			Deletions: file.Deletions,
+			Binary:    file.IsBinary,
+		}
+		for _, hunk := range file.Hunks {
+			summary.Hunks = append(summary.Hunks, hunkSummary(hunk))
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// hunkSummary formats a hunk as its range followed by its lines with their
+// numbers, e.g. "-1,2 +1,2 | 1:1 a | 2:-b | :2+c"
+func hunkSummary(hunk Hunk) string {
+	parts := []string{fmt.Sprintf("-%d,%d +%d,%d", hunk.OldStart, hunk.OldLines, hunk.NewStart, hunk.NewLines)}
+	for _, line := range hunk.Lines {
+		oldNumber, newNumber := "", ""
+		if line.OldNumber != nil {
+			oldNumber = fmt.Sprint(*line.OldNumber)
+		}
+		if line.NewNumber != nil {
+			newNumber = fmt.Sprint(*line.NewNumber)
+		}
+		marker := map[LineType]string{LineTypeContext: " ", LineTypeAdded: "+", LineTypeDeleted: "-"}[line.Type]
+		if line.NoNewline {
+			marker += "\\"
+		}
+		parts = append(parts, fmt.Sprintf("%s:%s%s%s", oldNumber, newNumber, marker, line.Content))
+	}
+	return strings.Join(parts, " | ")
+}
+
+// parseTests are diffs and what the parser makes of them
+var parseTests = []struct {
+	name string
+	diff string
+	want []fileSummary
+}{
+	{
+		name: "modified",
+		diff: `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@ package main
+ a
+-b
++c
+ d
+`,
+		want: []fileSummary{{
+			Path: "main.go", OldPath: "main.go", Status: FileStatusModified, Additions: 1, Deletions: 1,
+			Hunks: []string{"-1,3 +1,3 | 1:1 a | 2:-b | :2+c | 3:3 d"},
+		}},
+	},
+	{
+		name: "added, deleted and renamed",
+		diff: `diff --git a/new.txt b/new.txt
+new file mode 100644
+index 0000000..3e75765
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,2 @@
++x
++y
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+index 3e75765..0000000
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-x
+diff --git a/from.txt b/to.txt
+similarity index 100%
+rename from from.txt
+rename to to.txt
+`,
+		want: []fileSummary{
+			{Path: "new.txt", OldPath: "new.txt", Status: FileStatusAdded, Additions: 2,
+				Hunks: []string{"-0,0 +1,2 | :1+x | :2+y"}},
+			{Path: "old.txt", OldPath: "old.txt", Status: FileStatusDeleted, Deletions: 1,
+				Hunks: []string{"-1,1 +0,0 | 1:-x"}},
+			{Path: "to.txt", OldPath: "from.txt", Status: FileStatusRenamed},
+		},
+	},
+	{
+		name: "binary",
+		diff: `diff --git a/logo.png b/logo.png
+index 1111111..2222222 100644
+Binary files a/logo.png and b/logo.png differ
+`,
+		want: []fileSummary{{Path: "logo.png", OldPath: "logo.png", Status: FileStatusModified, Binary: true}},
+	},
+	{
+		name: "no newline at end of file",
+		diff: `diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -1 +1 @@
+-a
+\ No newline at end of file
++a
+`,
+		want: []fileSummary{{
+			Path: "f", OldPath: "f", Status: FileStatusModified, Additions: 1, Deletions: 1,
+			Hunks: []string{"-1,1 +1,1 | 1:-\\a | :1+a"},
+		}},
+	},
+	{
+		name: "plain diff -u with root directories",
+		diff: `diff -ruN old/src/f.c new/src/f.c
+--- old/src/f.c	2026-01-01 00:00:00.000000000 +0000
++++ new/src/f.c	2026-01-02 00:00:00.000000000 +0000
+@@ -1,2 +1,2 @@
+-int a;
++int b;
+ int c;
+`,
+		want: []fileSummary{{
+			Path: "src/f.c", OldPath: "src/f.c", Status: FileStatusModified, Additions: 1, Deletions: 1,
+			Hunks: []string{"-1,2 +1,2 | 1:-int a; | :1+int b; | 2:2 int c;"},
+		}},
+	},
+	{
+		name: "plain diff -u against /dev/null keeps the whole path",
+		diff: `--- /dev/null
++++ docs/README
+@@ -0,0 +1 @@
++hello
+--- b/a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+`,
+		want: []fileSummary{
+			{Path: "docs/README", Status: FileStatusAdded, Additions: 1, Hunks: []string{"-0,0 +1,1 | :1+hello"}},
+			{Path: "b/a/gone.txt", OldPath: "b/a/gone.txt", Status: FileStatusDeleted, Deletions: 1,
+				Hunks: []string{"-1,1 +0,0 | 1:-bye"}},
+		},
+	},
+	{
+		name: "mail trailer after the last hunk",
+		diff: `diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -1 +1 @@
+-a
++b
+--
+2.39.5
+
+`,
+		want: []fileSummary{{
+			Path: "f", OldPath: "f", Status: FileStatusModified, Additions: 1, Deletions: 1,
+			Hunks: []string{"-1,1 +1,1 | 1:-a | :1+b"},
+		}},
+	},
+}
+
+func TestParseDiff(t *testing.T) {
+	for _, tt := range parseTests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := newDiffParser(strings.NewReader(tt.diff)).parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := summarize(files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsed\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// The streaming path hands files on as the parser reads them, it has to
+// come to the same result as parsing a diff that is already in memory
+func TestParseIncremental(t *testing.T) {
+	inputs := map[string]string{"series": series}
+	for _, tt := range parseTests {
+		inputs[tt.name] = tt.diff
+	}
+
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			want, err := newDiffParser(strings.NewReader(input)).parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			readers := map[string]io.Reader{
+				"one byte": iotest.OneByteReader(strings.NewReader(input)),
+				"half":     iotest.HalfReader(strings.NewReader(input)),
+			}
+			for readerName, r := range readers {
+				parser := newDiffParser(r)
+				got := []FileDiff{}
+				for {
+					file, err := parser.next()
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+					got = append(got, *file)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%s reader parsed\n%#v\nwant\n%#v", readerName, summarize(got), summarize(want))
+				}
+			}
+		})
+	}
+}
+
+// series is "git format-patch" output of three commits: the first and second
+// edit f, the second also renames g to h and adds n, and the third deletes n
+const series = `From a8e230db065860467b1147def607bb86509fa06a Mon Sep 17 00:00:00 2001
+From: a <a@a>
+Date: Thu, 1 Jan 2026 00:00:00 +0000
+Subject: [PATCH 1/3] one
+
+---
+ f | 2 +-
+ 1 file changed, 1 insertion(+), 1 deletion(-)
+
+diff --git a/f b/f
+index f00c965..ed75e4e 100644
+--- a/f
++++ b/f
+@@ -1,5 +1,5 @@
+ 1
+-2
++two
+ 3
+ 4
+ 5
+--
+2.39.5
+
+
+From 735b05257ba166583f0ad201025fcdf0d1b8dfc0 Mon Sep 17 00:00:00 2001
+From: a <a@a>
+Date: Thu, 1 Jan 2026 00:00:00 +0000
+Subject: [PATCH 2/3] two
+
+---
+ f      | 2 +-
+ g => h | 0
+ n      | 1 +
+ 3 files changed, 2 insertions(+), 1 deletion(-)
+ rename g => h (100%)
+ create mode 100644 n
+
+diff --git a/f b/f
+index ed75e4e..372583c 100644
+--- a/f
++++ b/f
+@@ -5,6 +5,6 @@ two
+ 5
+ 6
+ 7
+-8
++eight
+ 9
+ 10
+diff --git a/g b/h
+similarity index 100%
+rename from g
+rename to h
+diff --git a/n b/n
+new file mode 100644
+index 0000000..3e75765
+--- /dev/null
++++ b/n
+@@ -0,0 +1 @@
++new
+--
+2.39.5
+
+
+From e6e849c518b48c777e7a394487719666d7c90232 Mon Sep 17 00:00:00 2001
+From: a <a@a>
+Date: Thu, 1 Jan 2026 00:00:00 +0000
+Subject: [PATCH 3/3] three
+
+---
+ n | 1 -
+ 1 file changed, 1 deletion(-)
+ delete mode 100644 n
+
+diff --git a/n b/n
+deleted file mode 100644
+index 3e75765..0000000
+--- a/n
++++ /dev/null
+@@ -1 +0,0 @@
+-new
+--
+2.39.5
+`
+
+func TestLoadPatchSeries(t *testing.T) {
+	s := NewService()
+	if err := s.LoadPatch(strings.NewReader(series)); err != nil {
+		t.Fatal(err)
+	}
+
+	// What "git diff HEAD~3" shows for the same commits
+	want := []fileSummary{
+		{
+			Path: "f", OldPath: "f", Status: FileStatusModified, Additions: 2, Deletions: 2,
+			Hunks: []string{"-1,10 +1,10 | 1:1 1 | 2:-2 | :2+two | 3:3 3 | 4:4 4 | 5:5 5 | 6:6 6 | 7:7 7 | 8:-8 | :8+eight | 9:9 9 | 10:10 10"},
+		},
+		{Path: "h", OldPath: "g", Status: FileStatusRenamed},
+	}
+	if got := summarize(s.patch); !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded\n%#v\nwant\n%#v", got, want)
+	}
+}
+
+func TestComposeHunks(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{
+			name: "separate changes shift each other",
+			a: `@@ -1,3 +1,4 @@
+ 1
++new
+ 2
+ 3
+`,
+			b: `@@ -20,3 +20,2 @@
+ 20
+-21
+ 22
+`,
+			want: []string{
+				"-1,3 +1,4 | 1:1 1 | :2+new | 2:3 2 | 3:4 3",
+				"-19,3 +20,2 | 19:20 20 | 20:-21 | 21:21 22",
+			},
+		},
+		{
+			name: "line added then edited",
+			a: `@@ -1,2 +1,3 @@
+ 1
++x
+ 2
+`,
+			b: `@@ -1,3 +1,3 @@
+ 1
+-x
++y
+ 2
+`,
+			want: []string{"-1,2 +1,3 | 1:1 1 | :2+y | 2:3 2"},
+		},
+		{
+			name: "line added then removed",
+			a: `@@ -1,2 +1,3 @@
+ 1
++x
+ 2
+`,
+			b: `@@ -1,3 +1,2 @@
+ 1
+-x
+ 2
+`,
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := parseHunks(t, tt.a)
+			b := parseHunks(t, tt.b)
+			var got []string
+			for _, hunk := range composeHunks(a, b) {
+				got = append(got, hunkSummary(hunk))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("composed\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+// parseHunks parses the hunks of a single file
+func parseHunks(t *testing.T, hunks string) []Hunk {
+	t.Helper()
+	files, err := newDiffParser(strings.NewReader("diff --git a/f b/f\n--- a/f\n+++ b/f\n" + hunks)).parse()
+	if err != nil || len(files) != 1 {
+		t.Fatalf("failed to parse hunks: %v", err)
+	}
+	return files[0].Hunks
+}
+
+func TestParseHunkSection(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"@@ -10 +10 @@ func (s *Service) GetDiff() {", "func (s *Service) GetDiff() {"},
+		{"@@ -1 +1 @@ type Config struct {  ", "type Config struct {"},
+		{"@@ -1 +1 @@", ""},
+		{"@@ -1 +1 @@ ", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			hunks := parseHunks(t, tt.header+"\n-a\n+b\n")
+			if len(hunks) != 1 || hunks[0].Section != tt.want {
+				t.Errorf("section of %q: %+v, want %q", tt.header, hunks, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WritePatch writes files as a unified diff that "git apply" accepts. Files
+// parsed from git or patch output keep their original headers, so parsing a
+// patch and writing it back reproduces the diff part of it unchanged.
+func WritePatch(w io.Writer, files []FileDiff) error {
+	bw := bufio.NewWriter(w)
+	for i := range files {
+		writeFilePatch(bw, &files[i])
+	}
+	return bw.Flush()
+}
+
+func writeFilePatch(w *bufio.Writer, file *FileDiff) {
+	header := file.header
+	if header == nil {
+		header = file.patchHeader()
+	}
+	for _, line := range header {
+		w.WriteString(line + "\n")
+	}
+
+	for _, hunk := range file.Hunks {
+		w.WriteString(hunk.Header + "\n")
+		for _, line := range hunk.Lines {
+			switch line.Type {
+			case LineTypeAdded:
+				w.WriteByte('+')
+			case LineTypeDeleted:
+				w.WriteByte('-')
+			default:
+				w.WriteByte(' ')
+			}
+			if line.raw != "" {
+				w.WriteString(line.raw)
+			} else {
+				w.WriteString(line.Content)
+			}
+			w.WriteByte('\n')
+			if line.NoNewline {
+				w.WriteString("\\ No newline at end of file\n")
+			}
+		}
+	}
+}
+
+// patchHeader builds git style headers for files that weren't parsed from a
+// diff, such as untracked files
+func (f *FileDiff) patchHeader() []string {
+	oldPath := f.oldPath()
+	header := []string{fmt.Sprintf("diff --git a/%s b/%s", oldPath, f.Path)}
+
+	switch f.Status {
+	case FileStatusAdded:
+		header = append(header, "new file mode 100644")
+	case FileStatusDeleted:
+		header = append(header, "deleted file mode 100644")
+	case FileStatusRenamed:
+		header = append(header, "rename from "+oldPath, "rename to "+f.Path)
+	}
+
+	from, to := "a/"+oldPath, "b/"+f.Path
+	switch f.Status {
+	case FileStatusAdded:
+		from = "/dev/null"
+	case FileStatusDeleted:
+		to = "/dev/null"
+	}
+
+	if f.IsBinary {
+		return append(header, fmt.Sprintf("Binary files %s and %s differ", from, to))
+	}
+	if len(f.Hunks) == 0 {
+		return header
+	}
+	return append(header, "--- "+from, "+++ "+to)
+}
+
+// PatchSelection picks parts of a diff for export. Files are selected by
+// path, hunks and lines by ID. An empty selection selects everything.
+type PatchSelection struct {
+	Files []string `json:"files,omitempty"`
+	Hunks []string `json:"hunks,omitempty"`
+	Lines []string `json:"lines,omitempty"`
+}
+
+func (sel PatchSelection) empty() bool {
+	return len(sel.Files) == 0 && len(sel.Hunks) == 0 && len(sel.Lines) == 0
+}
+
+// Select returns the selected parts of a diff with hunk headers recomputed,
+// so the result applies on its own. Like "git add -p", deleted lines that
+// aren't selected are kept as context and added lines that aren't selected
+// are left out.
+func (d *DiffResult) Select(sel PatchSelection) []FileDiff {
+	if sel.empty() {
+		return d.Files
+	}
+
+	files := toSet(sel.Files)
+	hunks := toSet(sel.Hunks)
+	lines := toSet(sel.Lines)
+
+	selected := []FileDiff{}
+	for _, file := range d.Files {
+		if files[file.Path] || (file.OldPath != "" && files[file.OldPath]) {
+			selected = append(selected, file)
+			continue
+		}
+
+		partial := file
+		partial.Hunks = []Hunk{}
+		whole := true
+		// offset is how many lines the selected changes in earlier hunks
+		// added to the new side, which shifts where later hunks start
+		offset := 0
+		for _, hunk := range file.Hunks {
+			h, complete := selectHunk(hunk, hunks[hunk.ID], lines, offset)
+			whole = whole && complete
+			if h == nil {
+				continue
+			}
+			offset += h.NewLines - h.OldLines
+			partial.Hunks = append(partial.Hunks, *h)
+		}
+		if len(partial.Hunks) == 0 {
+			continue
+		}
+
+		// Deleting part of a file leaves the file in place
+		if !whole && file.Status == FileStatusDeleted {
+			partial.Status = FileStatusModified
+			partial.header = nil
+		}
+		partial.Additions, partial.Deletions = 0, 0
+		countChanges(&partial)
+		selected = append(selected, partial)
+	}
+
+	return selected
+}
+
+// selectHunk keeps the selected changes of a hunk, returning nil when none
+// are left. complete reports whether every change was selected.
+func selectHunk(hunk Hunk, all bool, lines map[string]bool, offset int) (h *Hunk, complete bool) {
+	selected := hunk
+	h = &selected
+	h.Lines = make([]Line, 0, len(hunk.Lines))
+	changed, complete := false, true
+	for _, line := range hunk.Lines {
+		picked := all || lines[line.ID]
+		switch {
+		case line.Type == LineTypeContext:
+			h.Lines = append(h.Lines, line)
+		case picked:
+			h.Lines = append(h.Lines, line)
+			changed = true
+		case line.Type == LineTypeDeleted:
+			line.Type = LineTypeContext
+			line.Move = nil
+			h.Lines = append(h.Lines, line)
+			complete = false
+		default:
+			complete = false
+		}
+	}
+	if !changed {
+		return nil, false
+	}
+
+	h.OldLines, h.NewLines = 0, 0
+	for _, line := range h.Lines {
+		if line.Type != LineTypeAdded {
+			h.OldLines++
+		}
+		if line.Type != LineTypeDeleted {
+			h.NewLines++
+		}
+	}
+
+	// A range with no lines starts at the line before it
+	first := h.OldStart
+	if h.OldLines == 0 {
+		first++
+	}
+	h.NewStart = first + offset
+	if h.NewLines == 0 {
+		h.NewStart--
+	}
+
+	if h.OldLines != hunk.OldLines || h.NewLines != hunk.NewLines || h.NewStart != hunk.NewStart {
+		h.Header = hunkHeader(*h)
+	}
+	return h, complete
+}
+
+// hunkHeader formats a hunk header the way git does, leaving out counts of one
+func hunkHeader(h Hunk) string {
+	rangeOf := func(start, count int) string {
+		if count == 1 {
+			return strconv.Itoa(start)
+		}
+		return fmt.Sprintf("%d,%d", start, count)
+	}
+	header := fmt.Sprintf("@@ -%s +%s @@", rangeOf(h.OldStart, h.OldLines), rangeOf(h.NewStart, h.NewLines))
+	if h.Section != "" {
+		header += " " + h.Section
+	}
+	return header
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// MailHeader describes the commit a patch is wrapped in by WriteMbox
+type MailHeader struct {
+	Author  string
+	Email   string
+	Date    time.Time
+	Subject string
+	// Message is the body of the commit message, may be empty
+	Message string
+}
+
+// WriteMbox writes files as a single "git format-patch" style message, which
+// "git am" applies as a commit
+func WriteMbox(w io.Writer, header MailHeader, files []FileDiff) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "From 0000000000000000000000000000000000000000 Mon Sep 17 00:00:00 2001\n")
+	fmt.Fprintf(bw, "From: %s\n", (&mail.Address{Name: header.Author, Address: header.Email}).String())
+	fmt.Fprintf(bw, "Date: %s\n", header.Date.Format(time.RFC1123Z))
+	// Headers are ASCII only, "git am" decodes the encoded word back
+	subject := strings.Join(strings.Fields("[PATCH] "+header.Subject), " ")
+	fmt.Fprintf(bw, "Subject: %s\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(bw, "MIME-Version: 1.0\n")
+	fmt.Fprintf(bw, "Content-Type: text/plain; charset=UTF-8\n")
+	fmt.Fprintf(bw, "Content-Transfer-Encoding: 8bit\n\n")
+	if message := strings.TrimSpace(header.Message); message != "" {
+		fmt.Fprintf(bw, "%s\n", message)
+	}
+	fmt.Fprintf(bw, "---\n\n")
+
+	for i := range files {
+		writeFilePatch(bw, &files[i])
+	}
+	fmt.Fprintf(bw, "-- \nvibediff\n\n")
+	return bw.Flush()
+}
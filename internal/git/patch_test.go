@@ -0,0 +1,234 @@
+package git
+
+import (
+	"bytes"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWritePatchRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+	}{
+		{
+			name: "modified with function context",
+			diff: `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -10,3 +10,4 @@ func main() {
+ 	a()
+-	b()
++	c()
++	d()
+ }
+`,
+		},
+		{
+			name: "added, deleted, renamed and binary",
+			diff: `diff --git a/new.txt b/new.txt
+new file mode 100644
+index 0000000..3e75765
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++x
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+index 3e75765..0000000
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-x
+diff --git a/from.txt b/to.txt
+similarity index 90%
+rename from from.txt
+rename to to.txt
+index 1111111..2222222 100644
+--- a/from.txt
++++ b/to.txt
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+diff --git a/logo.png b/logo.png
+index 1111111..2222222 100644
+Binary files a/logo.png and b/logo.png differ
+`,
+		},
+		{
+			name: "no newline at end of file",
+			diff: `diff --git a/f b/f
+index 1111111..2222222 100644
+--- a/f
++++ b/f
+@@ -1,2 +1,2 @@
+ a
+-b
+\ No newline at end of file
++b
+`,
+		},
+		{
+			name: "mode change",
+			diff: `diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := newDiffParser(strings.NewReader(tt.diff)).parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := WritePatch(&out, files); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.diff {
+				t.Errorf("wrote\n%s\nwant\n%s", out.String(), tt.diff)
+			}
+		})
+	}
+}
+
+func TestNoNewlineRoundTrip(t *testing.T) {
+	dir := testRepo(t, map[string]string{
+		"dropped.txt": "a\nb\n",
+		"added.txt":   "a\nb",
+		"both.txt":    "a\nb",
+	})
+	changed := map[string]string{
+		"dropped.txt":   "a\nb",
+		"added.txt":     "a\nb\n",
+		"both.txt":      "a\nc",
+		"untracked.txt": "x",
+	}
+	writeFiles(t, dir, changed)
+
+	diff, err := testService(t, dir).GetDiff(DiffTypeAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []fileSummary{
+		{Path: "added.txt", OldPath: "added.txt", Status: FileStatusModified, Additions: 1, Deletions: 1,
+			Hunks: []string{"-1,2 +1,2 | 1:1 a | 2:-\\b | :2+b"}},
+		{Path: "both.txt", OldPath: "both.txt", Status: FileStatusModified, Additions: 1, Deletions: 1,
+			Hunks: []string{"-1,2 +1,2 | 1:1 a | 2:-\\b | :2+\\c"}},
+		{Path: "dropped.txt", OldPath: "dropped.txt", Status: FileStatusModified, Additions: 1, Deletions: 1,
+			Hunks: []string{"-1,2 +1,2 | 1:1 a | 2:-b | :2+\\b"}},
+		{Path: "untracked.txt", Status: FileStatusAdded, Additions: 1,
+			Hunks: []string{"-0,0 +1,1 | :1+\\x"}},
+	}
+	if got := summarize(diff.Files); !reflect.DeepEqual(got, want) {
+		t.Errorf("parsed\n%#v\nwant\n%#v", got, want)
+	}
+
+	// The written patch recreates the changes on a clean checkout
+	var out bytes.Buffer
+	if err := WritePatch(&out, diff.Files); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "checkout", "--", ".")
+	gitIn(t, dir, "clean", "-fdq")
+	patchPath := filepath.Join(t.TempDir(), "changes.patch")
+	if err := os.WriteFile(patchPath, out.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "apply", patchPath)
+	for path, content := range changed {
+		got, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("%s after applying is %q, want %q", path, got, content)
+		}
+	}
+}
+
+func TestWritePatchMergedSeries(t *testing.T) {
+	s := NewService()
+	if err := s.LoadPatch(strings.NewReader(series)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Merged files have no parsed header, so one is made up for them
+	var out bytes.Buffer
+	if err := WritePatch(&out, s.patch); err != nil {
+		t.Fatal(err)
+	}
+	files, err := newDiffParser(&out).parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summarize(files), summarize(s.patch); !reflect.DeepEqual(got, want) {
+		t.Errorf("read back\n%#v\nwant\n%#v", got, want)
+	}
+}
+
+func TestWriteMboxRoundTrip(t *testing.T) {
+	diff := `diff --git a/f b/f
+index 1111111..2222222 100644
+--- a/f
++++ b/f
+@@ -1 +1 @@
+-a
++b
+`
+	files, err := newDiffParser(strings.NewReader(diff)).parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := MailHeader{
+		Author:  "Zoë Example",
+		Email:   "zoe@example.com",
+		Date:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Subject: "Fix the naïve\n parser",
+		Message: "Longer explanation.\n",
+	}
+	var out bytes.Buffer
+	if err := WriteMbox(&out, header, files); err != nil {
+		t.Fatal(err)
+	}
+
+	// Skip the mbox "From " separator line to read the message
+	_, message, _ := strings.Cut(out.String(), "\n")
+	msg, err := mail.ReadMessage(strings.NewReader(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[PATCH] Fix the naïve parser"; subject != want {
+		t.Errorf("subject %q, want %q", subject, want)
+	}
+	from, err := msg.Header.AddressList("From")
+	if err != nil || len(from) != 1 || from[0].Name != header.Author || from[0].Address != header.Email {
+		t.Errorf("from %v (%v), want %s <%s>", from, err, header.Author, header.Email)
+	}
+	if date, err := msg.Header.Date(); err != nil || !date.Equal(header.Date) {
+		t.Errorf("date %v (%v), want %v", date, err, header.Date)
+	}
+
+	s := NewService()
+	if err := s.LoadPatch(&out); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summarize(s.patch), summarize(files); !reflect.DeepEqual(got, want) {
+		t.Errorf("read back\n%#v\nwant\n%#v", got, want)
+	}
+}
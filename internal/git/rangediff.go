@@ -0,0 +1,146 @@
+package git
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type RangeDiffStatus string
+
+const (
+	// RangeDiffMatched commits are identical in both versions of the series
+	RangeDiffMatched RangeDiffStatus = "matched"
+	// RangeDiffChanged commits correspond to each other but their patches differ
+	RangeDiffChanged RangeDiffStatus = "changed"
+	// RangeDiffAdded commits only exist in the new version
+	RangeDiffAdded RangeDiffStatus = "added"
+	// RangeDiffDropped commits only exist in the old version
+	RangeDiffDropped RangeDiffStatus = "dropped"
+)
+
+// RangeDiffPair is a commit of the old series and its counterpart in the new one
+type RangeDiffPair struct {
+	Status RangeDiffStatus `json:"status"`
+	// OldIndex and NewIndex are 1-based positions in each series, 0 when absent
+	OldIndex  int    `json:"oldIndex,omitempty"`
+	NewIndex  int    `json:"newIndex,omitempty"`
+	OldCommit string `json:"oldCommit,omitempty"`
+	NewCommit string `json:"newCommit,omitempty"`
+	Subject   string `json:"subject"`
+	// Hunks is the diff between the two patches. Each line's content is a
+	// line of the patch itself, so it starts with its own +, - or space.
+	// Hunk sections name the file, or "Metadata" and "Commit message".
+	Hunks []Hunk `json:"hunks"`
+}
+
+// RangeDiff compares two versions of a patch series, see "git range-diff"
+type RangeDiff struct {
+	Old   string          `json:"old"`
+	New   string          `json:"new"`
+	Pairs []RangeDiffPair `json:"pairs"`
+}
+
+// ErrInvalidRange is returned for range-diff arguments that aren't commit ranges
+var ErrInvalidRange = errors.New("ranges must look like <base>..<head>")
+
+var rangeDiffPairPattern = regexp.MustCompile(`^\s*(-|\d+):\s+(-+|[0-9a-f]+) ([=!<>]) \s*(-|\d+):\s+(-+|[0-9a-f]+) (.*)$`)
+
+// rangeDiffIndent is how far git range-diff indents the diff between patches
+const rangeDiffIndent = "    "
+
+// GetRangeDiff compares the commits in oldRange with those in newRange, for
+// example "main@{1}..feature@{1}" and "main..feature" after a rebase
+func (s *Service) GetRangeDiff(oldRange, newRange string) (*RangeDiff, error) {
+	if s.patch != nil || s.oldDir != "" {
+		return nil, ErrReadOnly
+	}
+	for _, r := range []string{oldRange, newRange} {
+		if r == "" || strings.HasPrefix(r, "-") || !strings.Contains(r, "..") {
+			return nil, ErrInvalidRange
+		}
+	}
+
+	output, err := s.runGitCommand("range-diff", "--no-color", oldRange, newRange)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RangeDiff{
+		Old:   oldRange,
+		New:   newRange,
+		Pairs: parseRangeDiff(output),
+	}, nil
+}
+
+func parseRangeDiff(output string) []RangeDiffPair {
+	pairs := []RangeDiffPair{}
+	var pair *RangeDiffPair
+	var hunk *Hunk
+
+	for _, line := range strings.Split(output, "\n") {
+		if m := rangeDiffPairPattern.FindStringSubmatch(line); m != nil {
+			pairs = append(pairs, RangeDiffPair{
+				OldIndex: rangeDiffIndex(m[1]),
+				NewIndex: rangeDiffIndex(m[4]),
+				Subject:  m[6],
+				Hunks:    []Hunk{},
+			})
+			pair = &pairs[len(pairs)-1]
+			hunk = nil
+
+			if !strings.HasPrefix(m[2], "-") {
+				pair.OldCommit = m[2]
+			}
+			if !strings.HasPrefix(m[5], "-") {
+				pair.NewCommit = m[5]
+			}
+			switch m[3] {
+			case "=":
+				pair.Status = RangeDiffMatched
+			case "!":
+				pair.Status = RangeDiffChanged
+			case "<":
+				pair.Status = RangeDiffDropped
+			case ">":
+				pair.Status = RangeDiffAdded
+			}
+			continue
+		}
+
+		if pair == nil || !strings.HasPrefix(line, rangeDiffIndent) {
+			continue
+		}
+		line = strings.TrimPrefix(line, rangeDiffIndent)
+
+		if strings.HasPrefix(line, "@@") {
+			pair.Hunks = append(pair.Hunks, Hunk{
+				Header:  line,
+				Section: strings.TrimSpace(strings.TrimPrefix(line, "@@")),
+				Lines:   []Line{},
+			})
+			hunk = &pair.Hunks[len(pair.Hunks)-1]
+			continue
+		}
+		if hunk == nil || line == "" {
+			continue
+		}
+
+		l := Line{Type: LineTypeContext, Content: line[1:]}
+		switch line[0] {
+		case '+':
+			l.Type = LineTypeAdded
+		case '-':
+			l.Type = LineTypeDeleted
+		}
+		hunk.Lines = append(hunk.Lines, l)
+	}
+
+	return pairs
+}
+
+func rangeDiffIndex(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
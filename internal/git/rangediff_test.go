@@ -0,0 +1,55 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRangeDiff(t *testing.T) {
+	// "git range-diff" of a series where the second commit was edited, the
+	// third dropped and a new one added
+	output := `1:  5dfc710 = 1:  5dfc710 Add a
+2:  1a720d2 ! 2:  f400b8a Add b
+    @@ b (new)
+     +12
+     +13
+     +14
+    -+15
+    ++fifteen
+     +16
+     +17
+     +18
+3:  e8df692 < -:  ------- Add c
+-:  ------- > 3:  2997809 Add d
+`
+
+	want := []RangeDiffPair{
+		{Status: RangeDiffMatched, OldIndex: 1, NewIndex: 1, OldCommit: "5dfc710", NewCommit: "5dfc710", Subject: "Add a", Hunks: []Hunk{}},
+		{Status: RangeDiffChanged, OldIndex: 2, NewIndex: 2, OldCommit: "1a720d2", NewCommit: "f400b8a", Subject: "Add b", Hunks: []Hunk{{
+			Header:  "@@ b (new)",
+			Section: "b (new)",
+			Lines: []Line{
+				{Type: LineTypeContext, Content: "+12"},
+				{Type: LineTypeContext, Content: "+13"},
+				{Type: LineTypeContext, Content: "+14"},
+				{Type: LineTypeDeleted, Content: "+15"},
+				{Type: LineTypeAdded, Content: "+fifteen"},
+				{Type: LineTypeContext, Content: "+16"},
+				{Type: LineTypeContext, Content: "+17"},
+				{Type: LineTypeContext, Content: "+18"},
+			},
+		}}},
+		{Status: RangeDiffDropped, OldIndex: 3, OldCommit: "e8df692", Subject: "Add c", Hunks: []Hunk{}},
+		{Status: RangeDiffAdded, NewIndex: 3, NewCommit: "2997809", Subject: "Add d", Hunks: []Hunk{}},
+	}
+
+	if got := parseRangeDiff(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parsed\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseRangeDiffEmpty(t *testing.T) {
+	if got := parseRangeDiff(""); got == nil || len(got) != 0 {
+		t.Errorf("parsed %#v, want an empty list", got)
+	}
+}
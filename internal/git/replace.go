@@ -0,0 +1,148 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrContentChanged is returned by ReplaceLines when the lines to replace no
+// longer read what the caller expects
+var ErrContentChanged = errors.New("the lines have changed since the suggestion was made")
+
+// ReplaceLines replaces lines start to end (1-based, inclusive) of a file in
+// the working tree, after checking they still read expected. The file keeps
+// each line's ending, its mode and whether it ends with a newline. Symlinks
+// and files that resolve to outside the working tree are refused.
+func (s *Service) ReplaceLines(path string, start, end int, expected, replacement []string) error {
+	if s.patch != nil || s.oldDir != "" {
+		return ErrReadOnly
+	}
+	fullPath, err := s.workTreeFile(path)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("not a regular file: %s", path)
+	}
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return err
+	}
+
+	lines := splitLineEndings(string(data))
+	if start < 1 || end < start || end > len(lines) || end-start+1 != len(expected) {
+		return ErrContentChanged
+	}
+	for i, line := range expected {
+		if lines[start-1+i].text != line {
+			return ErrContentChanged
+		}
+	}
+
+	// Replacement lines end like the first line they replace, the last one
+	// like the last, so a missing final newline stays missing
+	eol := lines[start-1].eol
+	if eol == "" {
+		eol = "\n"
+		if start > 1 {
+			eol = lines[start-2].eol
+		}
+	}
+	var result strings.Builder
+	for _, line := range lines[:start-1] {
+		result.WriteString(line.text + line.eol)
+	}
+	for i, line := range replacement {
+		if i == len(replacement)-1 {
+			result.WriteString(line + lines[end-1].eol)
+		} else {
+			result.WriteString(line + eol)
+		}
+	}
+	for _, line := range lines[end:] {
+		result.WriteString(line.text + line.eol)
+	}
+	// Deleting the last lines moves the end of the file up to the line before
+	content := result.String()
+	if len(replacement) == 0 && end == len(lines) && lines[end-1].eol == "" {
+		content = strings.TrimSuffix(strings.TrimSuffix(content, "\n"), "\r")
+	}
+
+	// Write next to the file and rename over it, so a failed write can't
+	// leave it half replaced
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), filepath.Base(fullPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fullPath)
+}
+
+// workTreeFile returns where a file of the working tree is on disk, with
+// symlinked directories resolved. It fails for paths that lead outside the
+// working tree and for symlinks, which could point anywhere.
+func (s *Service) workTreeFile(path string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(path)) {
+		return "", fmt.Errorf("path outside the working tree: %s", path)
+	}
+
+	root, err := filepath.EvalSymlinks(s.WorkDir())
+	if err != nil {
+		return "", err
+	}
+	fullPath := s.workTreePath(path)
+	if info, err := os.Lstat(fullPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return "", fmt.Errorf("refusing to write through symlink: %s", path)
+	}
+	resolved, err := filepath.EvalSymlinks(fullPath)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(root, resolved); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("path outside the working tree: %s", path)
+	}
+	return resolved, nil
+}
+
+// fileLine is a line of a file and the ending it has there, "" for a last
+// line without newline
+type fileLine struct {
+	text, eol string
+}
+
+// splitLineEndings splits content into lines, keeping each line's ending
+func splitLineEndings(content string) []fileLine {
+	var lines []fileLine
+	for content != "" {
+		text, rest, found := strings.Cut(content, "\n")
+		line := fileLine{text: text}
+		if found {
+			line.eol = "\n"
+			if strings.HasSuffix(text, "\r") {
+				line.text, line.eol = strings.TrimSuffix(text, "\r"), "\r\n"
+			}
+		}
+		lines = append(lines, line)
+		content = rest
+	}
+	return lines
+}
@@ -0,0 +1,129 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplaceLines(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		start, end  int
+		expected    []string
+		replacement []string
+		want        string
+		err         error
+	}{
+		{
+			name:    "single line",
+			content: "a\nb\nc\n",
+			start:   2, end: 2, expected: []string{"b"}, replacement: []string{"B"},
+			want: "a\nB\nc\n",
+		},
+		{
+			name:    "more lines than replaced",
+			content: "a\nb\nc\n",
+			start:   2, end: 2, expected: []string{"b"}, replacement: []string{"x", "y"},
+			want: "a\nx\ny\nc\n",
+		},
+		{
+			name:    "line endings kept",
+			content: "a\r\nb\r\nc\n",
+			start:   1, end: 2, expected: []string{"a", "b"}, replacement: []string{"x", "y", "z"},
+			want: "x\r\ny\r\nz\r\nc\n",
+		},
+		{
+			name:    "missing final newline kept",
+			content: "a\nb",
+			start:   2, end: 2, expected: []string{"b"}, replacement: []string{"x", "y"},
+			want: "a\nx\ny",
+		},
+		{
+			name:    "lines deleted",
+			content: "a\nb\nc\n",
+			start:   2, end: 2, expected: []string{"b"}, replacement: []string{},
+			want: "a\nc\n",
+		},
+		{
+			name:    "last lines deleted without final newline",
+			content: "a\nb\nc",
+			start:   2, end: 3, expected: []string{"b", "c"}, replacement: []string{},
+			want: "a",
+		},
+		{
+			name:    "lines changed since",
+			content: "a\nchanged\nc\n",
+			start:   2, end: 2, expected: []string{"b"}, replacement: []string{"B"},
+			want: "a\nchanged\nc\n", err: ErrContentChanged,
+		},
+		{
+			name:    "file got shorter",
+			content: "a\n",
+			start:   2, end: 3, expected: []string{"b", "c"}, replacement: []string{"B"},
+			want: "a\n", err: ErrContentChanged,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "f.txt")
+			if err := os.WriteFile(path, []byte(tt.content), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			s := &Service{workDir: dir}
+			err := s.ReplaceLines("f.txt", tt.start, tt.end, tt.expected, tt.replacement)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("file reads %q, want %q", data, tt.want)
+			}
+			if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o755 {
+				t.Errorf("file mode changed: %v (%v)", info.Mode(), err)
+			}
+		})
+	}
+}
+
+func TestReplaceLinesRefusesOutsideFiles(t *testing.T) {
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("a\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "linkdir")); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Service{workDir: dir}
+	for _, path := range []string{"link", "linkdir/secret", "../" + filepath.Base(outside) + "/secret", "/etc/hostname"} {
+		if err := s.ReplaceLines(path, 1, 1, []string{"a"}, []string{"b"}); err == nil {
+			t.Errorf("replaced lines of %s", path)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(outside, "secret"))
+	if err != nil || string(data) != "a\n" {
+		t.Errorf("file outside the working tree reads %q (%v)", data, err)
+	}
+}
+
+func TestReplaceLinesReadOnly(t *testing.T) {
+	s := &Service{patch: []FileDiff{}}
+	if err := s.ReplaceLines("f", 1, 1, []string{"a"}, []string{"b"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("error %v, want %v", err, ErrReadOnly)
+	}
+}
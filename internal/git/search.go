@@ -0,0 +1,233 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultSearchLimit caps the number of matches returned from each source
+const DefaultSearchLimit = 1000
+
+// ErrInvalidSearch is returned for empty queries and patterns that don't compile
+var ErrInvalidSearch = errors.New("invalid search")
+
+// SearchOptions controls what Search looks for and where
+type SearchOptions struct {
+	Query string
+	// Regexp treats Query as a regular expression instead of literal text.
+	// The diff is searched with Go's RE2 syntax and the tree with git's
+	// extended regular expressions, which agree for common patterns.
+	Regexp     bool
+	IgnoreCase bool
+	// Tree additionally searches every file on one side of the diff with
+	// "git grep", leave empty to search only the lines in the diff
+	Tree  Side
+	Limit int
+}
+
+// SearchMatch is a line that matched a search. HunkID and LineID are set
+// when the line is part of the diff.
+type SearchMatch struct {
+	File    string   `json:"file"`
+	Side    Side     `json:"side"`
+	Line    int      `json:"line"`
+	HunkID  string   `json:"hunkId,omitempty"`
+	LineID  string   `json:"lineId,omitempty"`
+	Type    LineType `json:"type,omitempty"`
+	Content string   `json:"content"`
+}
+
+type SearchResult struct {
+	Query string `json:"query"`
+	// Matches are lines of the diff, in diff order
+	Matches []SearchMatch `json:"matches"`
+	// TreeMatches are lines anywhere in the tree searched by SearchOptions.Tree
+	TreeMatches []SearchMatch `json:"treeMatches,omitempty"`
+	// Truncated reports whether either list was cut off at the limit
+	Truncated bool `json:"truncated"`
+}
+
+// Search finds lines matching a query in the diff and optionally the tree
+func (s *Service) Search(diffType DiffType, opts SearchOptions) (*SearchResult, error) {
+	if opts.Query == "" {
+		return nil, fmt.Errorf("%w: empty query", ErrInvalidSearch)
+	}
+	if opts.Tree != "" && opts.Tree != SideOld && opts.Tree != SideNew {
+		return nil, fmt.Errorf("%w: tree must be %q or %q", ErrInvalidSearch, SideOld, SideNew)
+	}
+	if opts.Tree != "" && (s.patch != nil || s.oldDir != "") {
+		return nil, ErrReadOnly
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultSearchLimit
+	}
+
+	pattern := opts.Query
+	if !opts.Regexp {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if opts.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSearch, err)
+	}
+
+	diff, err := s.GetDiffWithOptions(diffType, DiffOptions{Context: 3})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SearchResult{
+		Query:   opts.Query,
+		Matches: []SearchMatch{},
+	}
+
+scan:
+	for _, file := range diff.Files {
+		for _, hunk := range file.Hunks {
+			for i := range hunk.Lines {
+				line := &hunk.Lines[i]
+				if !re.MatchString(line.Content) {
+					continue
+				}
+				if len(result.Matches) == opts.Limit {
+					result.Truncated = true
+					break scan
+				}
+				side, number := line.Position()
+				result.Matches = append(result.Matches, SearchMatch{
+					File:    file.Path,
+					Side:    side,
+					Line:    number,
+					HunkID:  hunk.ID,
+					LineID:  line.ID,
+					Type:    line.Type,
+					Content: line.Content,
+				})
+			}
+		}
+	}
+
+	if opts.Tree != "" {
+		matches, truncated, err := s.grepTree(s.DiffRevisions(diff), opts)
+		if err != nil {
+			return nil, err
+		}
+		linkDiffLines(diff, opts.Tree, matches)
+		result.TreeMatches = matches
+		result.Truncated = result.Truncated || truncated
+	}
+
+	return result, nil
+}
+
+// grepTree runs "git grep" over one side of a diff
+func (s *Service) grepTree(revs Revisions, opts SearchOptions) ([]SearchMatch, bool, error) {
+	rev := revs.rev(opts.Tree)
+
+	args := []string{"grep", "-n", "-I", "-z", "--full-name", "--no-color"}
+	if opts.Regexp {
+		args = append(args, "-E")
+	} else {
+		args = append(args, "-F")
+	}
+	if opts.IgnoreCase {
+		args = append(args, "-i")
+	}
+	switch rev {
+	case revWorkTree:
+		// Untracked files are part of the diff, so search them too
+		args = append(args, "--untracked", "-e", opts.Query)
+	case revIndex:
+		args = append(args, "--cached", "-e", opts.Query)
+	default:
+		args = append(args, "-e", opts.Query, rev)
+	}
+	args = append(args, "--")
+
+	cmd := s.gitCommand(args...)
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		// git grep exits with 1 when nothing matched
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 || stderr.Len() > 0 {
+			return nil, false, fmt.Errorf("git command failed: %s", stderr.String())
+		}
+	}
+
+	matches := []SearchMatch{}
+	prefix := ""
+	if rev != revWorkTree && rev != revIndex {
+		prefix = rev + ":"
+	}
+	for _, record := range strings.Split(out.String(), "\n") {
+		// With -z each record is path NUL line NUL content
+		fields := strings.SplitN(record, "\x00", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		number, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		if len(matches) == opts.Limit {
+			return matches, true, nil
+		}
+		matches = append(matches, SearchMatch{
+			File:    strings.TrimPrefix(fields[0], prefix),
+			Side:    opts.Tree,
+			Line:    number,
+			Content: strings.TrimSuffix(fields[2], "\r"),
+		})
+	}
+	return matches, false, nil
+}
+
+// linkDiffLines fills in the hunk and line IDs of tree matches that fall
+// on a line shown in the diff
+func linkDiffLines(diff *DiffResult, side Side, matches []SearchMatch) {
+	type position struct {
+		path string
+		line int
+	}
+	type location struct {
+		hunkID string
+		line   *Line
+	}
+	lines := make(map[position]location)
+	for _, file := range diff.Files {
+		path := file.Path
+		if side == SideOld {
+			path = file.oldPath()
+		}
+		for _, hunk := range file.Hunks {
+			for i := range hunk.Lines {
+				line := &hunk.Lines[i]
+				number := line.NewNumber
+				if side == SideOld {
+					number = line.OldNumber
+				}
+				if number != nil {
+					lines[position{path, *number}] = location{hunk.ID, line}
+				}
+			}
+		}
+	}
+
+	for i := range matches {
+		if loc, ok := lines[position{matches[i].File, matches[i].Line}]; ok {
+			matches[i].HunkID = loc.hunkID
+			matches[i].LineID = loc.line.ID
+			matches[i].Type = loc.line.Type
+		}
+	}
+}
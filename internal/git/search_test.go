@@ -0,0 +1,158 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// matchStrings formats matches as "file side:line type content". Tree
+// matches get a trailing "*" when they are linked to a line of the diff.
+func matchStrings(matches []SearchMatch, tree bool) []string {
+	formatted := []string{}
+	for _, match := range matches {
+		linked := ""
+		if tree && match.LineID != "" && match.HunkID != "" {
+			linked = "*"
+		}
+		formatted = append(formatted, fmt.Sprintf("%s %s:%d %s %s%s", match.File, match.Side, match.Line, match.Type, match.Content, linked))
+	}
+	return formatted
+}
+
+func TestSearch(t *testing.T) {
+	dir := testRepo(t, map[string]string{
+		"a.go":       "package a\n\nfunc Old() {}\n\n// TODO: tidy\n",
+		"b.go":       "package b\n\nvar Total = 1\n",
+		"renamed.go": "package r\n\n// TODO: rename\n",
+	})
+	writeFiles(t, dir, map[string]string{
+		"a.go":   "package a\n\nfunc New() {}\n\n// TODO: tidy\n",
+		"new.go": "package n\n\n// todo: lower case\nvar total = 2\n",
+	})
+	gitIn(t, dir, "mv", "renamed.go", "moved.go")
+	s := testService(t, dir)
+
+	tests := []struct {
+		name     string
+		opts     SearchOptions
+		want     []string
+		wantTree []string
+		// truncated is whether the result was cut off at the limit
+		truncated bool
+		wantErr   error
+	}{
+		{
+			name: "literal",
+			opts: SearchOptions{Query: "func"},
+			want: []string{"a.go old:3 deleted func Old() {}", "a.go new:3 added func New() {}"},
+		},
+		{
+			name: "literal doesn't treat the query as a pattern",
+			opts: SearchOptions{Query: "Old()"},
+			want: []string{"a.go old:3 deleted func Old() {}"},
+		},
+		{
+			name: "case sensitive by default",
+			opts: SearchOptions{Query: "TODO"},
+			want: []string{"a.go new:5 context // TODO: tidy"},
+		},
+		{
+			name: "ignoring case",
+			opts: SearchOptions{Query: "TODO", IgnoreCase: true},
+			want: []string{"a.go new:5 context // TODO: tidy", "new.go new:3 added // todo: lower case"},
+		},
+		{
+			name: "regular expression",
+			opts: SearchOptions{Query: `func (Old|New)\(\)`, Regexp: true},
+			want: []string{"a.go old:3 deleted func Old() {}", "a.go new:3 added func New() {}"},
+		},
+		{
+			name:      "limit",
+			opts:      SearchOptions{Query: "package", Limit: 1},
+			want:      []string{"a.go new:1 context package a"},
+			truncated: true,
+		},
+		{
+			name: "new tree",
+			opts: SearchOptions{Query: "total", IgnoreCase: true, Tree: SideNew},
+			want: []string{"new.go new:4 added var total = 2"},
+			wantTree: []string{
+				"b.go new:3  var Total = 1",
+				"new.go new:4 added var total = 2*",
+			},
+		},
+		{
+			name:     "old tree",
+			opts:     SearchOptions{Query: "TODO", Tree: SideOld},
+			want:     []string{"a.go new:5 context // TODO: tidy"},
+			wantTree: []string{"a.go old:5 context // TODO: tidy*", "renamed.go old:3  // TODO: rename"},
+		},
+		{
+			name:    "empty query",
+			opts:    SearchOptions{},
+			wantErr: ErrInvalidSearch,
+		},
+		{
+			name:    "invalid pattern",
+			opts:    SearchOptions{Query: "(", Regexp: true},
+			wantErr: ErrInvalidSearch,
+		},
+		{
+			name:    "invalid tree",
+			opts:    SearchOptions{Query: "a", Tree: "both"},
+			wantErr: ErrInvalidSearch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := s.Search(DiffTypeAll, tt.opts)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("returned %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := matchStrings(result.Matches, false); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matches\n%q\nwant\n%q", got, tt.want)
+			}
+			for _, match := range result.Matches {
+				if match.LineID == "" || match.HunkID == "" {
+					t.Errorf("match %+v has no line or hunk ID", match)
+				}
+			}
+			if tt.wantTree != nil || result.TreeMatches != nil {
+				if got := matchStrings(result.TreeMatches, true); !reflect.DeepEqual(got, tt.wantTree) {
+					t.Errorf("tree matches\n%q\nwant\n%q", got, tt.wantTree)
+				}
+			}
+			if result.Truncated != tt.truncated {
+				t.Errorf("truncated = %v, want %v", result.Truncated, tt.truncated)
+			}
+		})
+	}
+}
+
+func TestSearchPatchTree(t *testing.T) {
+	s := NewService()
+	patch := "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n+b\n"
+	if err := s.LoadPatch(strings.NewReader(patch)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Search(DiffTypeAll, SearchOptions{Query: "b", Tree: SideNew}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("searching the tree of a patch returned %v", err)
+	}
+	result, err := s.Search(DiffTypeAll, SearchOptions{Query: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := matchStrings(result.Matches, false), []string{"f new:1 added b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("matches %q, want %q", got, want)
+	}
+}
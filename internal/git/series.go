@@ -0,0 +1,309 @@
+package git
+
+import (
+	"sort"
+)
+
+// seriesContext is how many context lines composed hunks keep around changes
+const seriesContext = 3
+
+// mergeSeries combines files that a patch series changes in more than one
+// patch, so each file is listed once with the net change of the series. A
+// later patch continues a file when its old path is the earlier new path.
+func mergeSeries(files []FileDiff) []FileDiff {
+	merged := make([]FileDiff, 0, len(files))
+	dropped := make(map[int]bool)
+	latest := make(map[string]int)
+	for _, file := range files {
+		if i, ok := latest[file.oldPath()]; ok {
+			delete(latest, merged[i].Path)
+			composed, changed := composeFiles(merged[i], file)
+			if !changed {
+				// Added and deleted again within the series
+				dropped[i] = true
+				continue
+			}
+			merged[i] = composed
+			latest[composed.Path] = i
+			continue
+		}
+		latest[file.Path] = len(merged)
+		merged = append(merged, file)
+	}
+
+	kept := merged[:0]
+	for i, file := range merged {
+		if !dropped[i] {
+			kept = append(kept, file)
+		}
+	}
+	return kept
+}
+
+// composeFiles returns the change of a followed by b, or false when b
+// deletes the file a added
+func composeFiles(a, b FileDiff) (FileDiff, bool) {
+	file := FileDiff{
+		Path:     b.Path,
+		OldPath:  a.OldPath,
+		IsBinary: a.IsBinary || b.IsBinary,
+		Hunks:    []Hunk{},
+	}
+
+	switch {
+	case a.Status == FileStatusAdded && b.Status == FileStatusDeleted:
+		return FileDiff{}, false
+	case a.Status == FileStatusAdded:
+		file.Status = FileStatusAdded
+		file.OldPath = ""
+	case b.Status == FileStatusDeleted:
+		file.Status = FileStatusDeleted
+		file.Path = a.oldPath()
+		file.OldPath = a.oldPath()
+	case a.oldPath() != b.Path:
+		file.Status = FileStatusRenamed
+		file.OldPath = a.oldPath()
+	default:
+		file.Status = FileStatusModified
+		file.OldPath = a.OldPath
+	}
+
+	// Binary changes have no lines to compose, the last one wins
+	if !file.IsBinary {
+		file.Hunks = composeHunks(a.Hunks, b.Hunks)
+	}
+	countChanges(&file)
+	return file, true
+}
+
+// seriesLine is a line of the middle version, after a and before b
+type seriesLine struct {
+	line Line
+	// changed is set for lines a added or b deleted
+	changed bool
+	// pos counts the lines of the outer version (the old side for lines of
+	// a, the new side for lines of b) that come before this one
+	pos int
+}
+
+// composeHunks composes the hunks of two consecutive diffs of a file. Lines
+// outside both diffs' hunks are unknown, so composed hunks only span lines
+// that either diff shows.
+func composeHunks(a, b []Hunk) []Hunk {
+	// Lines of the middle version keyed by their number in it, and the lines
+	// a deleted or b added in the gap before each middle line
+	aMid := make(map[int]seriesLine)
+	bMid := make(map[int]seriesLine)
+	aGap := make(map[int][]Line)
+	bGap := make(map[int][]Line)
+
+	for _, hunk := range a {
+		oldLine, mid := hunkStarts(hunk)
+		for _, line := range hunk.Lines {
+			switch line.Type {
+			case LineTypeDeleted:
+				aGap[mid] = append(aGap[mid], line)
+				oldLine++
+			case LineTypeAdded:
+				aMid[mid] = seriesLine{line: line, changed: true, pos: oldLine - 1}
+				mid++
+			default:
+				aMid[mid] = seriesLine{line: line, pos: oldLine - 1}
+				oldLine++
+				mid++
+			}
+		}
+	}
+	for _, hunk := range b {
+		mid, newLine := hunkStarts(hunk)
+		for _, line := range hunk.Lines {
+			switch line.Type {
+			case LineTypeAdded:
+				bGap[mid] = append(bGap[mid], line)
+				newLine++
+			case LineTypeDeleted:
+				bMid[mid] = seriesLine{line: line, changed: true, pos: newLine - 1}
+				mid++
+			default:
+				bMid[mid] = seriesLine{line: line, pos: newLine - 1}
+				newLine++
+				mid++
+			}
+		}
+	}
+
+	keys := make(map[int]bool)
+	for _, m := range []map[int]seriesLine{aMid, bMid} {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+	for _, m := range []map[int][]Line{aGap, bGap} {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+	sorted := make([]int, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Ints(sorted)
+
+	// oldPos and newPos count the lines of the old and new version before
+	// the gap in front of middle line k
+	oldPos := func(k int) int {
+		if l, ok := aMid[k]; ok {
+			return l.pos - len(aGap[k])
+		}
+		return k - 1 - sizeChange(a, k, false) - len(aGap[k])
+	}
+	newPos := func(k int) int {
+		if l, ok := bMid[k]; ok {
+			return l.pos - len(bGap[k])
+		}
+		return k - 1 + sizeChange(b, k, true) - len(bGap[k])
+	}
+
+	var hunks []Hunk
+	var run []Line
+	var runOld, runNew int
+	flush := func() {
+		if h := seriesHunk(run, runOld, runNew); h != nil {
+			hunks = append(hunks, *h)
+		}
+		run = nil
+	}
+
+	next := -1
+	for _, k := range sorted {
+		if k != next {
+			flush()
+			runOld, runNew = oldPos(k), newPos(k)
+		}
+		run = append(run, aGap[k]...)
+		run = append(run, bGap[k]...)
+
+		aLine, inA := aMid[k]
+		bLine, inB := bMid[k]
+		if !inA && !inB {
+			// Middle line k isn't shown by either diff
+			next = -1
+			continue
+		}
+		next = k + 1
+
+		line := bLine.line
+		if !inB {
+			line = aLine.line
+		}
+		switch {
+		case aLine.changed && bLine.changed:
+			// Added by a and deleted by b
+			continue
+		case aLine.changed:
+			line.Type = LineTypeAdded
+		case bLine.changed:
+			line.Type = LineTypeDeleted
+		default:
+			line.Type = LineTypeContext
+		}
+		run = append(run, line)
+	}
+	flush()
+
+	if hunks == nil {
+		return []Hunk{}
+	}
+	return hunks
+}
+
+// hunkStarts returns the first old and new line number of a hunk. Git gives
+// the line before the hunk as the start of a side without lines.
+func hunkStarts(h Hunk) (int, int) {
+	oldStart, newStart := h.OldStart, h.NewStart
+	if h.OldLines == 0 {
+		oldStart++
+	}
+	if h.NewLines == 0 {
+		newStart++
+	}
+	return oldStart, newStart
+}
+
+// sizeChange sums how many lines the hunks ending before middle line k add,
+// measured on the new side of a's hunks or the old side of b's
+func sizeChange(hunks []Hunk, k int, oldSide bool) int {
+	change := 0
+	for _, h := range hunks {
+		start, count := h.NewStart, h.NewLines
+		if oldSide {
+			start, count = h.OldStart, h.OldLines
+		}
+		end := start + count - 1
+		if count == 0 {
+			end = start
+		}
+		if end < k {
+			change += h.NewLines - h.OldLines
+		}
+	}
+	return change
+}
+
+// seriesHunk turns a run of composed lines into a hunk, trimming the context
+// around its changes. It returns nil when the run changes nothing.
+func seriesHunk(lines []Line, oldPos, newPos int) *Hunk {
+	first, last := -1, -1
+	for i, line := range lines {
+		if line.Type != LineTypeContext {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return nil
+	}
+
+	start := max(0, first-seriesContext)
+	end := min(len(lines), last+1+seriesContext)
+	oldPos += start
+	newPos += start
+
+	hunk := &Hunk{Lines: make([]Line, 0, end-start)}
+	oldLine, newLine := oldPos+1, newPos+1
+	for _, line := range lines[start:end] {
+		line.OldNumber, line.NewNumber = nil, nil
+		switch line.Type {
+		case LineTypeAdded:
+			n := newLine
+			line.NewNumber = &n
+			newLine++
+			hunk.NewLines++
+		case LineTypeDeleted:
+			n := oldLine
+			line.OldNumber = &n
+			oldLine++
+			hunk.OldLines++
+		default:
+			o, n := oldLine, newLine
+			line.OldNumber, line.NewNumber = &o, &n
+			oldLine++
+			newLine++
+			hunk.OldLines++
+			hunk.NewLines++
+		}
+		hunk.Lines = append(hunk.Lines, line)
+	}
+
+	hunk.OldStart, hunk.NewStart = oldPos+1, newPos+1
+	if hunk.OldLines == 0 {
+		hunk.OldStart = oldPos
+	}
+	if hunk.NewLines == 0 {
+		hunk.NewStart = newPos
+	}
+	hunk.Header = hunkHeader(*hunk)
+	return hunk
+}
@@ -2,36 +2,297 @@ package git
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
-type Service struct{
+// ErrReadOnly is returned for operations that need a live repository while
+// reviewing a patch file
+var ErrReadOnly = errors.New("not available when reviewing a patch")
+
+// ErrNoReviewSnapshot is returned for DiffTypeSinceReview before any review round ended
+var ErrNoReviewSnapshot = errors.New("no review round has ended yet")
+
+// reviewedRef keeps the snapshot of the last review round, so it survives
+// restarts and "git gc". Refs under refs/worktree/ belong to a single worktree.
+const reviewedRef = "refs/worktree/vibediff/reviewed"
+
+type Service struct {
+	// mu guards workDir, snapshot and the cached tree, which change while
+	// requests are served
+	mu sync.RWMutex
+	// workDir is the working tree git runs in, the current directory when empty
+	workDir    string
 	diffTarget string
+	// baseMode picks the base when diffTarget is empty
+	baseMode BaseMode
+	// patch holds the parsed files when reviewing a patch instead of a repository
+	patch []FileDiff
+	// oldDir and newDir are compared with "git diff --no-index" when set
+	oldDir string
+	newDir string
+	// snapshot is the working tree state when the last review round ended,
+	// read from reviewedRef the first time it's needed
+	snapshot       *ReviewSnapshot
+	snapshotLoaded bool
+	// cacheTree makes currentTree reuse tree until the working tree changes,
+	// treeGen counts the changes so a snapshot racing one isn't kept
+	cacheTree bool
+	tree      string
+	treeGen   int
 }
 
 func NewService() *Service {
 	return &Service{}
 }
 
+// SetWorkDir switches the working tree under review, for example to another
+// worktree of the same repository. Each worktree has its own review snapshot.
+func (s *Service) SetWorkDir(dir string) error {
+	if s.patch != nil || s.oldDir != "" {
+		return ErrReadOnly
+	}
+
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("not a git working tree: %s", dir)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workDir = strings.TrimSpace(string(output))
+	s.snapshot, s.snapshotLoaded = nil, false
+	s.tree = ""
+	s.treeGen++
+	return nil
+}
+
+// WorkDir returns the working tree under review, "" for the current directory
+func (s *Service) WorkDir() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.workDir
+}
+
+// gitCommand prepares a git command running in the working tree under review
+func (s *Service) gitCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = s.WorkDir()
+	return cmd
+}
+
+// workTreePath turns a repository relative path into one that can be opened
+func (s *Service) workTreePath(path string) string {
+	return filepath.Join(s.WorkDir(), filepath.FromSlash(path))
+}
+
 // SetDiffTarget sets the target for git diff (e.g., "main", "HEAD~1", commit hash)
 func (s *Service) SetDiffTarget(target string) {
 	s.diffTarget = target
 }
 
+// LoadPatch switches the service to read-only patch review. It accepts
+// "git diff" output, "git format-patch" mbox series and plain "diff -u" output.
+func (s *Service) LoadPatch(r io.Reader) error {
+	files, err := newDiffParser(r).parse()
+	if err != nil {
+		return fmt.Errorf("failed to read patch: %w", err)
+	}
+	if len(files) == 0 {
+		return errors.New("no changes found in patch")
+	}
+
+	files = mergeSeries(files)
+	analyzeFiles(files, nil)
+	s.patch = files
+	return nil
+}
+
+// SetCompareDirs switches the service to comparing two directories with
+// "git diff --no-index", which doesn't need either of them to be in a repository
+func (s *Service) SetCompareDirs(oldDir, newDir string) error {
+	for _, dir := range []string{oldDir, newDir} {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("not a directory: %s", dir)
+		}
+	}
+
+	s.oldDir = oldDir
+	s.newDir = newDir
+	return nil
+}
+
+// CompareDirs returns the directories being compared, if any
+func (s *Service) CompareDirs() (string, string) {
+	return s.oldDir, s.newDir
+}
+
+// ReadOnly reports whether the service serves a loaded patch rather than a repository
+func (s *Service) ReadOnly() bool {
+	return s.patch != nil
+}
+
+// DiffOptions controls how a diff is generated
+type DiffOptions struct {
+	// Context is the number of context lines around each change
+	Context int
+	// IgnoreEOL hides changes that only affect line endings
+	IgnoreEOL bool
+	// Paths limits the diff to files matching these pathspecs, e.g. "*.go",
+	// it's ignored when comparing directories
+	Paths []string
+}
+
 // GetDiff retrieves the git diff with optional context lines (default: 3)
 func (s *Service) GetDiff(diffType DiffType, contextLines ...int) (*DiffResult, error) {
-	context := 3
+	opts := DiffOptions{Context: 3}
 	if len(contextLines) > 0 {
-		context = contextLines[0]
+		opts.Context = contextLines[0]
+	}
+	return s.GetDiffWithOptions(diffType, opts)
+}
+
+// GetDiffWithOptions retrieves the git diff as configured by opts
+func (s *Service) GetDiffWithOptions(diffType DiffType, opts DiffOptions) (*DiffResult, error) {
+	if s.patch != nil {
+		// A patch carries fixed context, so the requested amount is ignored
+		files := s.patch
+		if opts.IgnoreEOL {
+			files = withoutEOLChanges(files)
+		}
+		return &DiffResult{
+			Files: files,
+			Type:  diffType,
+		}, nil
+	}
+
+	base, err := s.DiffBase(diffType)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []FileDiff{}
+	err = s.readDiff(diffType, base, opts, func(file *FileDiff) error {
+		files = append(files, *file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.oldDir == "" {
+		s.classifyFiles(files)
+	}
+	analyzeFiles(files, s.readSources(files, s.revisions(diffType, base)))
+	if opts.IgnoreEOL {
+		files = withoutEOLChanges(files)
+	}
+
+	return &DiffResult{
+		Files: files,
+		Type:  diffType,
+		Base:  base,
+	}, nil
+}
+
+// StreamDiff is like GetDiffWithOptions but hands files to emit as git
+// produces them instead of collecting the whole diff in memory. Files are
+// classified and analyzed in small batches, so moved code, which needs the
+// whole diff, isn't detected. Returning an error from emit stops git.
+func (s *Service) StreamDiff(diffType DiffType, opts DiffOptions, emit func(FileDiff) error) error {
+	if s.patch != nil {
+		files := s.patch
+		if opts.IgnoreEOL {
+			files = withoutEOLChanges(files)
+		}
+		for _, file := range files {
+			if err := emit(file); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	base, err := s.DiffBase(diffType)
+	if err != nil {
+		return err
+	}
+	revs := s.revisions(diffType, base)
+
+	batch := make([]FileDiff, 0, streamBatchSize)
+	flush := func() error {
+		if s.oldDir == "" {
+			s.classifyFiles(batch)
+		}
+		sources := s.readSources(batch, revs)
+		detectTextFormats(batch, sources)
+		assignIDs(batch, sources)
+		files := batch
+		if opts.IgnoreEOL {
+			files = withoutEOLChanges(files)
+		}
+		for _, file := range files {
+			if err := emit(file); err != nil {
+				return err
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	err = s.readDiff(diffType, base, opts, func(file *FileDiff) error {
+		batch = append(batch, *file)
+		if len(batch) < streamBatchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// streamBatchSize is how many files StreamDiff classifies with a single git call
+const streamBatchSize = 64
+
+// readDiff runs git diff for diffType against base, if any, and passes each
+// parsed file to emit, followed by untracked files where the diff type
+// includes them
+func (s *Service) readDiff(diffType DiffType, base *DiffBase, opts DiffOptions, emit func(*FileDiff) error) error {
+	if s.oldDir != "" {
+		return s.readDirDiff(opts, emit)
 	}
 
 	var args []string
 
-	// If a diff target is specified, use it instead of the default behavior
-	if s.diffTarget != "" {
-		args = []string{"diff", s.diffTarget, "--no-color", "--no-ext-diff"}
+	// Compare the last reviewed snapshot with a fresh one, otherwise if a
+	// diff target or PR base is set, use it instead of the default behavior
+	if diffType == DiffTypeSinceReview {
+		snapshot := s.ReviewSnapshot()
+		if snapshot == nil {
+			return ErrNoReviewSnapshot
+		}
+		current, err := s.currentTree()
+		if err != nil {
+			return err
+		}
+		args = []string{"diff", snapshot.Tree, current, "--no-color", "--no-ext-diff"}
+	} else if base != nil {
+		args = []string{"diff", base.rev(), "--no-color", "--no-ext-diff"}
 	} else {
 		switch diffType {
 		case DiffTypeStaged:
@@ -43,38 +304,196 @@ func (s *Service) GetDiff(diffType DiffType, contextLines ...int) (*DiffResult,
 		}
 	}
 
-	// Add context parameter
-	if context >= 0 {
-		args = append(args, fmt.Sprintf("-U%d", context))
+	args = append(args, opts.flags()...)
+	if len(opts.Paths) > 0 {
+		args = append(append(args, "--"), opts.Paths...)
 	}
 
-	output, err := s.runGitCommand(args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get diff: %w", err)
-	}
-
-	files, err := s.parseDiff(output)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse diff: %w", err)
+	if err := s.streamDiffCommand(args, false, emit); err != nil {
+		return fmt.Errorf("failed to get diff: %w", err)
 	}
 
 	// Get untracked files and add them to the diff
 	if diffType == DiffTypeUnstaged || diffType == DiffTypeAll {
-		untrackedFiles, err := s.getUntrackedFiles()
+		untrackedFiles, err := s.getUntrackedFiles(opts.Paths...)
 		if err == nil && len(untrackedFiles) > 0 {
 			for _, filepath := range untrackedFiles {
-				fileDiff, err := s.getUntrackedFileDiff(filepath, context)
+				fileDiff, err := s.getUntrackedFileDiff(filepath, opts.Context)
 				if err == nil && fileDiff != nil {
-					files = append(files, *fileDiff)
+					if err := emit(fileDiff); err != nil {
+						return err
+					}
 				}
 			}
 		}
 	}
 
-	return &DiffResult{
-		Files: files,
-		Type:  diffType,
-	}, nil
+	return nil
+}
+
+// SnapshotWorkingTree writes the working tree, including untracked files, to
+// a tree object without touching the real index and returns its ID
+func (s *Service) SnapshotWorkingTree() (string, error) {
+	if s.patch != nil || s.oldDir != "" {
+		return "", ErrReadOnly
+	}
+
+	tmp, err := os.CreateTemp("", "vibediff-index-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary index: %w", err)
+	}
+	tmpIndex := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpIndex)
+
+	// Start from a copy of the real index so unchanged files aren't rehashed
+	indexPath, err := s.runGitCommand("rev-parse", "--git-path", "index")
+	if err != nil {
+		return "", fmt.Errorf("failed to locate index: %w", err)
+	}
+	indexPath = strings.TrimSpace(indexPath)
+	if !filepath.IsAbs(indexPath) {
+		indexPath = filepath.Join(s.WorkDir(), indexPath)
+	}
+	index, err := os.ReadFile(indexPath)
+	if err == nil {
+		err = os.WriteFile(tmpIndex, index, 0o600)
+	} else if errors.Is(err, os.ErrNotExist) {
+		// A fresh repository has no index yet, git expects a missing file
+		err = os.Remove(tmpIndex)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare temporary index: %w", err)
+	}
+
+	env := []string{"GIT_INDEX_FILE=" + tmpIndex}
+	if _, err := s.runGitCommandEnv(env, "add", "-A"); err != nil {
+		return "", fmt.Errorf("failed to snapshot working tree: %w", err)
+	}
+	tree, err := s.runGitCommandEnv(env, "write-tree")
+	if err != nil {
+		return "", fmt.Errorf("failed to snapshot working tree: %w", err)
+	}
+
+	return strings.TrimSpace(tree), nil
+}
+
+// CacheWorkingTree makes the service reuse its snapshot of the working tree
+// until WorkingTreeChanged is called, for when a watcher reports changes
+func (s *Service) CacheWorkingTree() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cacheTree = true
+}
+
+// WorkingTreeChanged drops the cached snapshot of the working tree
+func (s *Service) WorkingTreeChanged() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tree = ""
+	s.treeGen++
+}
+
+// currentTree returns a snapshot of the working tree, cached if enabled
+func (s *Service) currentTree() (string, error) {
+	s.mu.RLock()
+	cached, gen := s.tree, s.treeGen
+	s.mu.RUnlock()
+	if cached != "" {
+		return cached, nil
+	}
+
+	tree, err := s.SnapshotWorkingTree()
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	if s.cacheTree && s.treeGen == gen {
+		s.tree = tree
+	}
+	s.mu.Unlock()
+	return tree, nil
+}
+
+// EndReviewRound snapshots the working tree as the reviewed state, which
+// DiffTypeSinceReview then compares against. The snapshot is committed
+// under reviewedRef, outside of any branch.
+func (s *Service) EndReviewRound() (*ReviewSnapshot, error) {
+	tree, err := s.SnapshotWorkingTree()
+	if err != nil {
+		return nil, err
+	}
+
+	// A fixed identity, so it works without user.name configured
+	env := []string{
+		"GIT_AUTHOR_NAME=VibeDiff", "GIT_AUTHOR_EMAIL=vibediff@localhost",
+		"GIT_COMMITTER_NAME=VibeDiff", "GIT_COMMITTER_EMAIL=vibediff@localhost",
+	}
+	commit, err := s.runGitCommandEnv(env, "commit-tree", "--no-gpg-sign", "-m", "VibeDiff review round", tree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record review round: %w", err)
+	}
+	commit = strings.TrimSpace(commit)
+	if _, err := s.runGitCommand("update-ref", "-m", "vibediff: end review round", reviewedRef, commit); err != nil {
+		return nil, fmt.Errorf("failed to record review round: %w", err)
+	}
+	snapshot, err := s.readSnapshot(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.snapshot, s.snapshotLoaded = snapshot, true
+	s.mu.Unlock()
+	return snapshot, nil
+}
+
+// ReviewSnapshot returns the state recorded when the last review round ended, if any
+func (s *Service) ReviewSnapshot() *ReviewSnapshot {
+	if s.patch != nil || s.oldDir != "" {
+		return nil
+	}
+
+	s.mu.RLock()
+	snapshot, loaded, workDir := s.snapshot, s.snapshotLoaded, s.workDir
+	s.mu.RUnlock()
+	if loaded {
+		return snapshot
+	}
+
+	// No ref means no round ended yet, anything else is retried next time
+	snapshot = nil
+	commit, err := s.runGitCommand("rev-parse", "-q", "--verify", reviewedRef+"^{commit}")
+	if err == nil {
+		snapshot, err = s.readSnapshot(strings.TrimSpace(commit))
+		if err != nil {
+			return nil
+		}
+	} else if _, err := s.runGitCommand("rev-parse", "--git-dir"); err != nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Keep what EndReviewRound or SetWorkDir did meanwhile
+	if !s.snapshotLoaded && s.workDir == workDir {
+		s.snapshot, s.snapshotLoaded = snapshot, true
+	}
+	return s.snapshot
+}
+
+// readSnapshot reads the review snapshot committed as commit
+func (s *Service) readSnapshot(commit string) (*ReviewSnapshot, error) {
+	output, err := s.runGitCommand("show", "-s", "--format=%T %cI", commit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read review round: %w", err)
+	}
+	tree, date, _ := strings.Cut(strings.TrimSpace(output), " ")
+	createdAt, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read review round: %w", err)
+	}
+	return &ReviewSnapshot{Tree: tree, CreatedAt: createdAt}, nil
 }
 
 func (s *Service) GetStatus() ([]string, error) {
@@ -94,8 +513,23 @@ func (s *Service) GetStatus() ([]string, error) {
 	return files, nil
 }
 
+// Author returns the configured git user, used when exporting commits
+func (s *Service) Author() (name, email string) {
+	name, _ = s.runGitCommand("config", "user.name")
+	email, _ = s.runGitCommand("config", "user.email")
+	return strings.TrimSpace(name), strings.TrimSpace(email)
+}
+
 func (s *Service) runGitCommand(args ...string) (string, error) {
-	cmd := exec.Command("git", args...)
+	return s.runGitCommandEnv(nil, args...)
+}
+
+// runGitCommandEnv runs git with extra environment variables
+func (s *Service) runGitCommandEnv(env []string, args ...string) (string, error) {
+	cmd := s.gitCommand(args...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
 	var out bytes.Buffer
 	var stderr bytes.Buffer
 	cmd.Stdout = &out
@@ -109,21 +543,151 @@ func (s *Service) runGitCommand(args ...string) (string, error) {
 	return out.String(), nil
 }
 
-func (s *Service) parseDiff(diffOutput string) ([]FileDiff, error) {
-	if diffOutput == "" {
-		return []FileDiff{}, nil
+// streamDiffCommand runs a git diff command and parses its output straight
+// from the pipe, passing each file to emit. "git diff --no-index" exits with
+// 1 when the inputs differ, which noIndex accepts as success.
+func (s *Service) streamDiffCommand(args []string, noIndex bool, emit func(*FileDiff) error) error {
+	cmd := s.gitCommand(args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("git command failed: %w", err)
+	}
+
+	parser := newDiffParser(stdout)
+	for {
+		file, err := parser.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err == nil {
+			err = emit(file)
+		}
+		if err != nil {
+			// Stop git rather than leaving it blocked on a full pipe
+			cmd.Process.Kill()
+			cmd.Wait()
+			return err
+		}
+	}
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if err != nil && !(noIndex && errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return fmt.Errorf("git command failed: %s", stderr.String())
+	}
+
+	return nil
+}
+
+// readDirDiff compares the two directories set with SetCompareDirs
+func (s *Service) readDirDiff(opts DiffOptions, emit func(*FileDiff) error) error {
+	args := []string{"diff", "--no-index", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/"}
+	args = append(args, opts.flags()...)
+	args = append(args, "--", s.oldDir, s.newDir)
+
+	err := s.streamDiffCommand(args, true, func(file *FileDiff) error {
+		// Git reports paths including the compared directories, make them
+		// relative so both sides of a file share the same path
+		file.Path = s.trimCompareDir(file.Path)
+		if file.OldPath != "" {
+			file.OldPath = s.trimCompareDir(file.OldPath)
+		}
+		return emit(file)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get diff: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) trimCompareDir(path string) string {
+	for _, dir := range []string{s.newDir, s.oldDir} {
+		prefix := strings.TrimPrefix(filepath.ToSlash(filepath.Clean(dir)), "/") + "/"
+		if strings.HasPrefix(path, prefix) {
+			return strings.TrimPrefix(path, prefix)
+		}
 	}
+	return path
+}
 
-	parser := newDiffParser(diffOutput)
-	return parser.parse()
+// flags returns the git diff arguments for the options
+func (o DiffOptions) flags() []string {
+	var flags []string
+	if o.Context >= 0 {
+		flags = append(flags, fmt.Sprintf("-U%d", o.Context))
+	}
+	if o.IgnoreEOL {
+		flags = append(flags, "--ignore-cr-at-eol")
+	}
+	return flags
+}
+
+// analyzeFiles runs the checks that need the parsed diff and, where
+// available, the contents of both sides of its files
+func analyzeFiles(files []FileDiff, sources []fileSources) {
+	detectTextFormats(files, sources)
+	assignIDs(files, sources)
+	detectMoves(files)
+}
+
+// withoutEOLChanges drops files whose only change is in line endings. Git
+// still lists such files without hunks when run with --ignore-cr-at-eol.
+// Files that also change mode are kept with just the mode change.
+func withoutEOLChanges(files []FileDiff) []FileDiff {
+	kept := make([]FileDiff, 0, len(files))
+	for _, file := range files {
+		eolOnly := file.EOLOnly || (file.Status == FileStatusModified && !file.IsBinary && len(file.Hunks) == 0)
+		if eolOnly && !file.modeChanged() {
+			continue
+		}
+		if file.EOLOnly {
+			file.Hunks = []Hunk{}
+			file.Additions, file.Deletions = 0, 0
+		}
+		kept = append(kept, file)
+	}
+	return kept
+}
+
+// modeChanged reports whether a file's diff header changes its mode
+func (f *FileDiff) modeChanged() bool {
+	for _, line := range f.header {
+		if strings.HasPrefix(line, "old mode ") {
+			return true
+		}
+	}
+	return false
 }
 
 func (s *Service) GetFileContent(filePath string) (string, error) {
+	if s.patch != nil {
+		return "", ErrReadOnly
+	}
+
+	if s.oldDir != "" {
+		// Prefer the new side, fall back to the old one for deleted files
+		content, err := os.ReadFile(filepath.Join(s.newDir, filepath.FromSlash(filePath)))
+		if err != nil {
+			content, err = os.ReadFile(filepath.Join(s.oldDir, filepath.FromSlash(filePath)))
+			if err != nil {
+				return "", fmt.Errorf("failed to read file: %w", err)
+			}
+		}
+		return string(content), nil
+	}
+
 	// First check if file exists in working directory
 	content, err := s.runGitCommand("show", fmt.Sprintf("HEAD:%s", filePath))
 	if err != nil {
 		// If not in HEAD, try to read from filesystem
-		output, err := exec.Command("cat", filePath).Output()
+		output, err := os.ReadFile(s.workTreePath(filePath))
 		if err != nil {
 			return "", fmt.Errorf("failed to read file: %w", err)
 		}
@@ -140,11 +704,22 @@ func (s *Service) GetFileDiff(filename string, diffType DiffType, contextLines .
 	}
 
 	// Check if it's an untracked file
-	untrackedFiles, err := s.getUntrackedFiles()
-	if err == nil {
-		for _, untracked := range untrackedFiles {
-			if untracked == filename {
-				return s.getUntrackedFileDiff(filename, context)
+	if s.patch == nil && s.oldDir == "" && diffType != DiffTypeSinceReview {
+		untrackedFiles, err := s.getUntrackedFiles()
+		if err == nil {
+			for _, untracked := range untrackedFiles {
+				if untracked == filename {
+					file, err := s.getUntrackedFileDiff(filename, context)
+					if err != nil {
+						return nil, err
+					}
+					files := []FileDiff{*file}
+					// Untracked files only have a new side, in the working tree
+					revs := s.revisions(diffType, nil)
+					analyzeFiles(files, s.readSources(files, revs))
+					s.addOutlines(files, revs)
+					return &files[0], nil
+				}
 			}
 		}
 	}
@@ -155,23 +730,36 @@ func (s *Service) GetFileDiff(filename string, diffType DiffType, contextLines .
 		return nil, err
 	}
 
-	for _, file := range diff.Files {
-		if file.Path == filename {
-			return &file, nil
+	for i := range diff.Files {
+		if diff.Files[i].Path == filename {
+			// Outlines read both sides of a file, so only the requested one gets it
+			files := diff.Files[i : i+1]
+			if s.patch == nil && s.oldDir == "" {
+				s.addOutlines(files, s.DiffRevisions(diff))
+			}
+			return &files[0], nil
 		}
 	}
 
 	return nil, fmt.Errorf("file not found in diff: %s", filename)
 }
 
+// FullContext is the amount of context that shows whole files
+const FullContext = 999999
+
 // GetFileDiffWithFullContext is a convenience method for getting full file context
 func (s *Service) GetFileDiffWithFullContext(filename string, diffType DiffType) (*FileDiff, error) {
-	return s.GetFileDiff(filename, diffType, 999999)
+	return s.GetFileDiff(filename, diffType, FullContext)
 }
 
-// getUntrackedFiles returns list of untracked files from git status
-func (s *Service) getUntrackedFiles() ([]string, error) {
-	output, err := s.runGitCommand("ls-files", "--others", "--exclude-standard")
+// getUntrackedFiles returns list of untracked files from git status,
+// optionally only those matching pathspecs
+func (s *Service) getUntrackedFiles(pathspecs ...string) ([]string, error) {
+	args := []string{"ls-files", "--others", "--exclude-standard"}
+	if len(pathspecs) > 0 {
+		args = append(append(args, "--"), pathspecs...)
+	}
+	output, err := s.runGitCommand(args...)
 	if err != nil {
 		return nil, err
 	}
@@ -194,13 +782,26 @@ func (s *Service) getUntrackedFiles() ([]string, error) {
 // getUntrackedFileDiff creates a diff for an untracked file
 func (s *Service) getUntrackedFileDiff(filepath string, contextLines int) (*FileDiff, error) {
 	// Read file content
-	content, err := exec.Command("cat", filepath).Output()
+	content, err := os.ReadFile(s.workTreePath(filepath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read untracked file %s: %w", filepath, err)
 	}
 
-	lines := strings.Split(string(content), "\n")
-	
+	file := &FileDiff{
+		Path:     filepath,
+		Status:   FileStatusAdded,
+		IsBinary: false,
+		Hunks:    []Hunk{},
+	}
+	if len(content) == 0 {
+		return file, nil
+	}
+
+	// A trailing newline ends the last line rather than starting another one
+	text := string(content)
+	noNewline := !strings.HasSuffix(text, "\n")
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+
 	// Create diff lines showing all lines as added
 	var diffLines []Line
 	for i, line := range lines {
@@ -211,22 +812,16 @@ func (s *Service) getUntrackedFileDiff(filepath string, contextLines int) (*File
 			Content:   line,
 		})
 	}
-
-	return &FileDiff{
-		Path:      filepath,
-		Status:    FileStatusAdded,
-		Additions: len(lines),
-		Deletions: 0,
-		IsBinary:  false,
-		Hunks: []Hunk{
-			{
-				OldStart: 0,
-				OldLines: 0,
-				NewStart: 1,
-				NewLines: len(lines),
-				Header:   fmt.Sprintf("@@ -0,0 +1,%d @@", len(lines)),
-				Lines:    diffLines,
-			},
-		},
-	}, nil
+	diffLines[len(diffLines)-1].NoNewline = noNewline
+
+	file.Additions = len(lines)
+	file.Hunks = append(file.Hunks, Hunk{
+		OldStart: 0,
+		OldLines: 0,
+		NewStart: 1,
+		NewLines: len(lines),
+		Header:   fmt.Sprintf("@@ -0,0 +1,%d @@", len(lines)),
+		Lines:    diffLines,
+	})
+	return file, nil
 }
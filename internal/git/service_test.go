@@ -0,0 +1,203 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testRepo creates a repository with files committed on branch main and
+// returns its directory
+func testRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	t.Setenv("GIT_AUTHOR_NAME", "a")
+	t.Setenv("GIT_AUTHOR_EMAIL", "a@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "a")
+	t.Setenv("GIT_COMMITTER_EMAIL", "a@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	dir := t.TempDir()
+	gitIn(t, dir, "init", "-q", "-b", "main")
+	writeFiles(t, dir, files)
+	gitIn(t, dir, "add", "-A")
+	gitIn(t, dir, "commit", "-q", "-m", "initial")
+	return dir
+}
+
+// gitIn runs git in dir and returns its output
+func gitIn(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return string(output)
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		full := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testService(t *testing.T, dir string) *Service {
+	t.Helper()
+	s := NewService()
+	if err := s.SetWorkDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// changedPaths lists the files of a diff
+func changedPaths(t *testing.T, s *Service, diffType DiffType) []string {
+	t.Helper()
+	diff, err := s.GetDiff(diffType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, file := range diff.Files {
+		paths = append(paths, file.Path)
+	}
+	return paths
+}
+
+func TestReviewSnapshot(t *testing.T) {
+	dir := testRepo(t, map[string]string{"a": "a\n", "b": "b\n"})
+	s := testService(t, dir)
+
+	if _, err := s.GetDiff(DiffTypeSinceReview); !errors.Is(err, ErrNoReviewSnapshot) {
+		t.Fatalf("diff before the first round: %v, want %v", err, ErrNoReviewSnapshot)
+	}
+
+	writeFiles(t, dir, map[string]string{"a": "reviewed\n"})
+	snapshot, err := s.EndReviewRound()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, map[string]string{"b": "fixed\n", "new": "untracked\n"})
+
+	if got := strings.Join(changedPaths(t, s, DiffTypeSinceReview), " "); got != "b new" {
+		t.Errorf("changed since review: %s, want b new", got)
+	}
+
+	// A restarted server picks the round up again, even after "git gc"
+	gitIn(t, dir, "gc", "-q", "--prune=now")
+	restarted := testService(t, dir)
+	got := restarted.ReviewSnapshot()
+	if got == nil || got.Tree != snapshot.Tree || !got.CreatedAt.Equal(snapshot.CreatedAt) {
+		t.Fatalf("restored %+v, want %+v", got, snapshot)
+	}
+	if got := strings.Join(changedPaths(t, restarted, DiffTypeSinceReview), " "); got != "b new" {
+		t.Errorf("changed since review after restart: %s, want b new", got)
+	}
+
+	// Other worktrees have rounds of their own
+	other := filepath.Join(t.TempDir(), "other")
+	gitIn(t, dir, "worktree", "add", "-q", other)
+	if err := restarted.SetWorkDir(other); err != nil {
+		t.Fatal(err)
+	}
+	if got := restarted.ReviewSnapshot(); got != nil {
+		t.Errorf("other worktree has snapshot %+v", got)
+	}
+}
+
+func TestWorkingTreeCache(t *testing.T) {
+	dir := testRepo(t, map[string]string{"a": "a\n"})
+	s := testService(t, dir)
+	s.CacheWorkingTree()
+	if _, err := s.EndReviewRound(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := changedPaths(t, s, DiffTypeSinceReview); len(got) != 0 {
+		t.Fatalf("changed right after the round: %v", got)
+	}
+	writeFiles(t, dir, map[string]string{"a": "changed\n"})
+	if got := changedPaths(t, s, DiffTypeSinceReview); len(got) != 0 {
+		t.Errorf("cached snapshot was taken again: %v", got)
+	}
+	s.WorkingTreeChanged()
+	if got := strings.Join(changedPaths(t, s, DiffTypeSinceReview), " "); got != "a" {
+		t.Errorf("changed after the watcher noticed: %s, want a", got)
+	}
+}
+
+func TestStreamDiff(t *testing.T) {
+	// More files than a batch, with a line moved between two of them
+	committed := make(map[string]string)
+	changed := make(map[string]string)
+	for i := 0; i < streamBatchSize+6; i++ {
+		path := fmt.Sprintf("f%03d.txt", i)
+		committed[path] = fmt.Sprintf("file %d\nline\n", i)
+		changed[path] = fmt.Sprintf("file %d\nline changed\n", i)
+	}
+	committed["from.txt"] = "moved line that is long enough to match\n"
+	committed["to.txt"] = "\n"
+	changed["from.txt"] = ""
+	changed["to.txt"] = "\nmoved line that is long enough to match\n"
+	changed["untracked.txt"] = "new\n"
+	dir := testRepo(t, committed)
+	writeFiles(t, dir, changed)
+	s := testService(t, dir)
+
+	opts := DiffOptions{Context: 3}
+	want, err := s.GetDiffWithOptions(DiffTypeAll, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []FileDiff
+	err = s.StreamDiff(DiffTypeAll, opts, func(file FileDiff) error {
+		got = append(got, file)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Streaming leaves out moved code detection, and nothing else
+	moved := 0
+	for i := range want.Files {
+		for hi := range want.Files[i].Hunks {
+			for li := range want.Files[i].Hunks[hi].Lines {
+				if line := &want.Files[i].Hunks[hi].Lines[li]; line.Move != nil {
+					line.Move = nil
+					moved++
+				}
+			}
+		}
+	}
+	if moved != 2 {
+		t.Errorf("buffered diff has %d moved lines, want 2", moved)
+	}
+	if !reflect.DeepEqual(got, want.Files) {
+		t.Errorf("streamed\n%#v\nwant\n%#v", summarize(got), summarize(want.Files))
+	}
+
+	// Stopping early stops git too
+	stop := errors.New("stop")
+	count := 0
+	err = s.StreamDiff(DiffTypeAll, opts, func(FileDiff) error {
+		count++
+		return stop
+	})
+	if !errors.Is(err, stop) || count != 1 {
+		t.Errorf("stopping returned %v after %d files", err, count)
+	}
+}
@@ -0,0 +1,58 @@
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// stateDir is where VibeDiff keeps its files inside the git directory
+const stateDir = "vibediff"
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ReviewStatePath returns the file the review of the current branch and
+// diff target is kept in, under .git/vibediff/. It returns "" for patches
+// and directory comparisons, which have no repository to keep it in.
+func (s *Service) ReviewStatePath() (string, error) {
+	if s.patch != nil || s.oldDir != "" {
+		return "", nil
+	}
+
+	gitDir, err := s.runGitCommand("rev-parse", "--path-format=absolute", "--git-common-dir")
+	if err != nil {
+		return "", err
+	}
+
+	branch, err := s.runGitCommand("symbolic-ref", "--short", "-q", "HEAD")
+	if err != nil {
+		// Detached HEAD, key the review by commit instead
+		commit, err := s.runGitCommand("rev-parse", "--short", "HEAD")
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve HEAD: %w", err)
+		}
+		branch = "detached-" + commit
+	}
+	branch = strings.TrimSpace(branch)
+
+	target := s.diffTarget
+	if target == "" {
+		target = string(s.baseMode)
+	}
+	if target == "" {
+		target = "HEAD"
+	}
+
+	// The readable part can collide after sanitizing, the hash can't
+	key := branch + "\x00" + target
+	sum := sha256.Sum256([]byte(key))
+	name := fmt.Sprintf("%s--%s-%s.json",
+		unsafeFileChars.ReplaceAllString(branch, "_"),
+		unsafeFileChars.ReplaceAllString(target, "_"),
+		hex.EncodeToString(sum[:4]))
+
+	return filepath.Join(strings.TrimSpace(gitDir), stateDir, name), nil
+}
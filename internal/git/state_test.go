@@ -0,0 +1,89 @@
+package git
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReviewStatePath(t *testing.T) {
+	dir := testRepo(t, map[string]string{"a": "a\n"})
+	gitIn(t, dir, "branch", "-q", "fix/crash")
+	stateDir := filepath.Join(dir, ".git", "vibediff")
+
+	tests := []struct {
+		name   string
+		setup  func(t *testing.T, s *Service)
+		prefix string
+	}{
+		{
+			name:   "branch against HEAD",
+			setup:  func(t *testing.T, s *Service) {},
+			prefix: "main--HEAD-",
+		},
+		{
+			name:   "base mode",
+			setup:  func(t *testing.T, s *Service) { s.SetBaseMode(BaseModePR) },
+			prefix: "main--pr-",
+		},
+		{
+			name:   "target",
+			setup:  func(t *testing.T, s *Service) { s.SetDiffTarget("origin/main~2") },
+			prefix: "main--origin_main_2-",
+		},
+		{
+			name: "unsafe branch name",
+			setup: func(t *testing.T, s *Service) {
+				gitIn(t, dir, "checkout", "-q", "fix/crash")
+				t.Cleanup(func() { gitIn(t, dir, "checkout", "-q", "main") })
+			},
+			prefix: "fix_crash--HEAD-",
+		},
+		{
+			name: "detached HEAD",
+			setup: func(t *testing.T, s *Service) {
+				gitIn(t, dir, "checkout", "-q", "--detach")
+				t.Cleanup(func() { gitIn(t, dir, "checkout", "-q", "main") })
+			},
+			prefix: "detached-" + strings.TrimSpace(gitIn(t, dir, "rev-parse", "--short", "HEAD")) + "--HEAD-",
+		},
+	}
+
+	seen := make(map[string]string)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testService(t, dir)
+			tt.setup(t, s)
+			path, err := s.ReviewStatePath()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if filepath.Dir(path) != stateDir || !strings.HasPrefix(filepath.Base(path), tt.prefix) ||
+				!strings.HasSuffix(path, ".json") {
+				t.Errorf("review kept in %s, want %s/%s*.json", path, stateDir, tt.prefix)
+			}
+			if other, ok := seen[path]; ok {
+				t.Errorf("shares %s with %q", path, other)
+			}
+			seen[path] = tt.name
+		})
+	}
+}
+
+func TestReviewStatePathWithoutRepository(t *testing.T) {
+	s := NewService()
+	if err := s.LoadPatch(strings.NewReader("diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n+b\n")); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := s.ReviewStatePath(); path != "" || err != nil {
+		t.Errorf("patch review kept in %q, %v", path, err)
+	}
+
+	s = NewService()
+	if err := s.SetCompareDirs(t.TempDir(), t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := s.ReviewStatePath(); path != "" || err != nil {
+		t.Errorf("directory review kept in %q, %v", path, err)
+	}
+}
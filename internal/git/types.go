@@ -1,11 +1,15 @@
 package git
 
+import "time"
+
 type DiffType string
 
 const (
 	DiffTypeUnstaged DiffType = "unstaged"
 	DiffTypeStaged   DiffType = "staged"
 	DiffTypeAll      DiffType = "all"
+	// DiffTypeSinceReview shows what changed since the last review round ended
+	DiffTypeSinceReview DiffType = "since-review"
 )
 
 type FileDiff struct {
@@ -16,6 +20,29 @@ type FileDiff struct {
 	Deletions int        `json:"deletions"`
 	IsBinary  bool       `json:"isBinary"`
 	Hunks     []Hunk     `json:"hunks"`
+	// Outline lists the symbols touched by the change, currently for Go files
+	// only. It is filled in for single file diffs, not whole diff listings.
+	Outline []Symbol `json:"outline,omitempty"`
+	// Generated, Vendored and Ignored come from .gitattributes and .vibediffignore
+	Generated bool `json:"generated,omitempty"`
+	Vendored  bool `json:"vendored,omitempty"`
+	Ignored   bool `json:"ignored,omitempty"`
+	// OldFormat and NewFormat describe the line endings and encoding of each
+	// side, EOLOnly is set when nothing but line endings or the BOM changed
+	OldFormat *TextFormat `json:"oldFormat,omitempty"`
+	NewFormat *TextFormat `json:"newFormat,omitempty"`
+	EOLOnly   bool        `json:"eolOnly,omitempty"`
+	// HunksOmitted is set when the hunks were left out of a response and
+	// have to be loaded separately
+	HunksOmitted bool `json:"hunksOmitted,omitempty"`
+	// header holds the lines between "diff --git" and the first hunk as
+	// parsed, so the file can be written back out unchanged
+	header []string
+}
+
+// Collapsed reports whether a file's hunks are left out of diff listings by default
+func (f *FileDiff) Collapsed() bool {
+	return f.Generated || f.Vendored || f.Ignored
 }
 
 type FileStatus string
@@ -27,20 +54,65 @@ const (
 	FileStatusRenamed  FileStatus = "renamed"
 )
 
+type EOL string
+
+const (
+	EOLLF    EOL = "lf"
+	EOLCRLF  EOL = "crlf"
+	EOLMixed EOL = "mixed"
+)
+
+// TextFormat describes how one side of a text file is stored. Lines in other
+// encodings than UTF-8 are transcoded to UTF-8 in the diff.
+type TextFormat struct {
+	Encoding string `json:"encoding"`
+	EOL      EOL    `json:"eol"`
+	BOM      bool   `json:"bom,omitempty"`
+}
+
 type Hunk struct {
+	// ID is derived from the path and content, see assignIDs
+	ID       string `json:"id,omitempty"`
 	OldStart int    `json:"oldStart"`
 	OldLines int    `json:"oldLines"`
 	NewStart int    `json:"newStart"`
 	NewLines int    `json:"newLines"`
 	Header   string `json:"header"`
-	Lines    []Line `json:"lines"`
+	// Section is the function context git prints after the range, e.g. "func Foo() {"
+	Section string `json:"section,omitempty"`
+	Lines   []Line `json:"lines"`
 }
 
 type Line struct {
+	// ID is derived from the path, content and neighbouring lines
+	ID        string   `json:"id,omitempty"`
 	Type      LineType `json:"type"`
 	OldNumber *int     `json:"oldNumber,omitempty"`
 	NewNumber *int     `json:"newNumber,omitempty"`
 	Content   string   `json:"content"`
+	// Move links a line that was moved elsewhere in the diff to its counterpart
+	Move *Move `json:"move,omitempty"`
+	// NoNewline marks the last line of a side that has no trailing newline
+	NoNewline bool `json:"noNewline,omitempty"`
+	// raw is the original content of a line transcoded from a legacy encoding
+	raw string
+}
+
+type MoveKind string
+
+const (
+	MoveKindMoved       MoveKind = "moved"
+	MoveKindMovedEdited MoveKind = "moved-edited"
+)
+
+// Move points a moved line at the matching line of the other block. Path and
+// Line refer to the new side for deleted lines and to the old side for added
+// ones. Block numbers alternate between adjacent blocks for zebra coloring.
+type Move struct {
+	Kind  MoveKind `json:"kind"`
+	Block int      `json:"block"`
+	Path  string   `json:"path"`
+	Line  int      `json:"line"`
 }
 
 type LineType string
@@ -51,7 +123,48 @@ const (
 	LineTypeDeleted LineType = "deleted"
 )
 
+// Side selects the old or new version of a diff
+type Side string
+
+const (
+	SideOld Side = "old"
+	SideNew Side = "new"
+)
+
+type SymbolKind string
+
+const (
+	SymbolKindFunc   SymbolKind = "func"
+	SymbolKindMethod SymbolKind = "method"
+	SymbolKindType   SymbolKind = "type"
+)
+
+type SymbolChange string
+
+const (
+	SymbolAdded    SymbolChange = "added"
+	SymbolRemoved  SymbolChange = "removed"
+	SymbolModified SymbolChange = "modified"
+)
+
+// Symbol is a declaration touched by a file's change. Line refers to the new
+// side, or to the old side for removed symbols.
+type Symbol struct {
+	Name   string       `json:"name"`
+	Kind   SymbolKind   `json:"kind"`
+	Change SymbolChange `json:"change"`
+	Line   int          `json:"line"`
+}
+
 type DiffResult struct {
 	Files []FileDiff `json:"files"`
 	Type  DiffType   `json:"type"`
+	// Base is the commit the diff was taken against, unset for HEAD
+	Base *DiffBase `json:"base,omitempty"`
+}
+
+// ReviewSnapshot records the working tree state at the end of a review round
+type ReviewSnapshot struct {
+	Tree      string    `json:"tree"`
+	CreatedAt time.Time `json:"createdAt"`
 }
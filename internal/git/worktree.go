@@ -0,0 +1,133 @@
+package git
+
+import (
+	"bufio"
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnknownWorktree is returned when selecting a path that isn't a usable
+// worktree of the repository
+var ErrUnknownWorktree = errors.New("not a worktree of this repository")
+
+// Worktree is a working tree attached to the repository, see "git worktree"
+type Worktree struct {
+	Path string `json:"path"`
+	// Branch is the short branch name, empty when HEAD is detached
+	Branch   string `json:"branch,omitempty"`
+	Head     string `json:"head"`
+	Detached bool   `json:"detached,omitempty"`
+	Bare     bool   `json:"bare,omitempty"`
+	Locked   bool   `json:"locked,omitempty"`
+	Prunable bool   `json:"prunable,omitempty"`
+	// Dirty is set when the worktree has uncommitted or untracked changes
+	Dirty bool `json:"dirty"`
+	// Current marks the worktree under review
+	Current bool `json:"current"`
+}
+
+// ListWorktrees returns every worktree of the repository under review
+func (s *Service) ListWorktrees() ([]Worktree, error) {
+	if s.patch != nil || s.oldDir != "" {
+		return nil, ErrReadOnly
+	}
+
+	output, err := s.runGitCommand("worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+	worktrees := parseWorktrees(output)
+
+	current, _ := s.runGitCommand("rev-parse", "--show-toplevel")
+	current = strings.TrimSpace(current)
+	for i := range worktrees {
+		wt := &worktrees[i]
+		wt.Current = samePath(wt.Path, current)
+		if wt.Bare || wt.Prunable {
+			continue
+		}
+
+		cmd := s.gitCommand("status", "--porcelain", "--untracked-files=normal")
+		cmd.Dir = wt.Path
+		status, err := cmd.Output()
+		wt.Dirty = err == nil && len(strings.TrimSpace(string(status))) > 0
+	}
+
+	return worktrees, nil
+}
+
+// SelectWorktree switches the review to another worktree of the repository
+func (s *Service) SelectWorktree(path string) (*Worktree, error) {
+	worktrees, err := s.ListWorktrees()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, wt := range worktrees {
+		if !samePath(wt.Path, path) || wt.Bare || wt.Prunable {
+			continue
+		}
+		if err := s.SetWorkDir(wt.Path); err != nil {
+			return nil, err
+		}
+		wt.Current = true
+		return &wt, nil
+	}
+
+	return nil, ErrUnknownWorktree
+}
+
+// parseWorktrees parses "git worktree list --porcelain" output, which lists
+// one attribute per line with a blank line between worktrees
+func parseWorktrees(output string) []Worktree {
+	worktrees := []Worktree{}
+	var wt *Worktree
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), " ")
+		switch key {
+		case "worktree":
+			worktrees = append(worktrees, Worktree{Path: value})
+			wt = &worktrees[len(worktrees)-1]
+		case "HEAD":
+			if wt != nil {
+				wt.Head = value
+			}
+		case "branch":
+			if wt != nil {
+				wt.Branch = strings.TrimPrefix(value, "refs/heads/")
+			}
+		case "detached":
+			if wt != nil {
+				wt.Detached = true
+			}
+		case "bare":
+			if wt != nil {
+				wt.Bare = true
+			}
+		case "locked":
+			if wt != nil {
+				wt.Locked = true
+			}
+		case "prunable":
+			if wt != nil {
+				wt.Prunable = true
+			}
+		}
+	}
+
+	return worktrees
+}
+
+// samePath compares two paths after resolving symlinks, git prints
+// worktree paths as they were added
+func samePath(a, b string) bool {
+	if a == b {
+		return true
+	}
+	ra, errA := filepath.EvalSymlinks(a)
+	rb, errB := filepath.EvalSymlinks(b)
+	return errA == nil && errB == nil && ra == rb
+}
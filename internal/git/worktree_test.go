@@ -0,0 +1,103 @@
+package git
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseWorktrees(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []Worktree
+	}{
+		{
+			name:   "empty",
+			output: "",
+			want:   []Worktree{},
+		},
+		{
+			name: "branches, detached and locked",
+			output: "worktree /src/app\nHEAD 1111111111111111111111111111111111111111\nbranch refs/heads/main\n\n" +
+				"worktree /src/app-fix\nHEAD 2222222222222222222222222222222222222222\nbranch refs/heads/fix/crash\nlocked\n\n" +
+				"worktree /src/app-old\nHEAD 3333333333333333333333333333333333333333\ndetached\n\n",
+			want: []Worktree{
+				{Path: "/src/app", Head: "1111111111111111111111111111111111111111", Branch: "main"},
+				{Path: "/src/app-fix", Head: "2222222222222222222222222222222222222222", Branch: "fix/crash", Locked: true},
+				{Path: "/src/app-old", Head: "3333333333333333333333333333333333333333", Detached: true},
+			},
+		},
+		{
+			name: "bare and prunable with reasons",
+			output: "worktree /src/app.git\nbare\n\n" +
+				"worktree /tmp/gone\nHEAD 4444444444444444444444444444444444444444\nbranch refs/heads/topic\n" +
+				"locked on a removable disk\nprunable gitdir file points to non-existent location\n",
+			want: []Worktree{
+				{Path: "/src/app.git", Bare: true},
+				{Path: "/tmp/gone", Head: "4444444444444444444444444444444444444444", Branch: "topic", Locked: true, Prunable: true},
+			},
+		},
+		{
+			name:   "paths with spaces",
+			output: "worktree /src/my app\nHEAD 5555555555555555555555555555555555555555\nbranch refs/heads/main\n",
+			want: []Worktree{
+				{Path: "/src/my app", Head: "5555555555555555555555555555555555555555", Branch: "main"},
+			},
+		},
+		{
+			name:   "attributes before any worktree are ignored",
+			output: "HEAD 6666666666666666666666666666666666666666\nbranch refs/heads/main\n",
+			want:   []Worktree{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseWorktrees(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsed\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectWorktree(t *testing.T) {
+	dir := testRepo(t, map[string]string{"a": "a\n"})
+	other := filepath.Join(t.TempDir(), "other")
+	gitIn(t, dir, "worktree", "add", "-q", "-b", "topic", other)
+	writeFiles(t, other, map[string]string{"b": "b\n"})
+	s := testService(t, dir)
+
+	worktrees, err := s.ListWorktrees()
+	if err != nil {
+		t.Fatal(err)
+	}
+	type summary struct {
+		Branch         string
+		Dirty, Current bool
+	}
+	var got []summary
+	for _, wt := range worktrees {
+		got = append(got, summary{wt.Branch, wt.Dirty, wt.Current})
+	}
+	want := []summary{{"main", false, true}, {"topic", true, false}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("worktrees %+v, want %+v", got, want)
+	}
+
+	if _, err := s.SelectWorktree(t.TempDir()); !errors.Is(err, ErrUnknownWorktree) {
+		t.Errorf("selecting another directory returned %v", err)
+	}
+
+	selected, err := s.SelectWorktree(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selected.Branch != "topic" || !selected.Current {
+		t.Errorf("selected %+v", selected)
+	}
+	if paths := changedPaths(t, s, DiffTypeAll); !reflect.DeepEqual(paths, []string{"b"}) {
+		t.Errorf("diff of the selected worktree has %q, want [b]", paths)
+	}
+}
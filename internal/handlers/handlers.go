@@ -2,21 +2,37 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 
+	"github.com/malvex/vibediff/internal/apidiff"
 	"github.com/malvex/vibediff/internal/git"
 	"github.com/malvex/vibediff/internal/review"
+	"github.com/malvex/vibediff/internal/stats"
+	"github.com/malvex/vibediff/internal/watcher"
 )
 
 type Handler struct {
 	gitService  *git.Service
 	reviewStore *review.Store
 	format      string
+	// watcher follows the service when another worktree is selected
+	watcher *watcher.GitWatcher
+	// hub tells connected clients about new replies
+	hub *WSHub
+	// reviewMu is held for reading by requests that change comments and for
+	// writing while the review is switched to another worktree's, so no
+	// change lands in the review it wasn't made in
+	reviewMu sync.RWMutex
 }
 
 func NewHandler(gitService *git.Service, reviewStore *review.Store) *Handler {
@@ -30,6 +46,41 @@ func (h *Handler) SetFormat(format string) {
 	h.format = format
 }
 
+func (h *Handler) SetWatcher(w *watcher.GitWatcher) {
+	h.watcher = w
+}
+
+func (h *Handler) SetHub(hub *WSHub) {
+	h.hub = hub
+}
+
+// RejectCrossSite refuses requests a browser sends from another site, so
+// pages open in the reviewer's browser can't write files through the local
+// server. Clients that aren't browsers send no Origin and pass.
+func RejectCrossSite(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if crossSite(r) {
+			http.Error(w, "Cross-site requests are not allowed", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// crossSite reports whether a browser sent a request from a page served
+// by another origin than VibeDiff
+func crossSite(r *http.Request) bool {
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err != nil || u.Host != r.Host
+}
+
 // writeJSON is a helper method to reduce repetitive JSON response code
 func (h *Handler) writeJSON(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -44,20 +95,145 @@ func (h *Handler) GetDiff(w http.ResponseWriter, r *http.Request) {
 		diffType = git.DiffTypeAll
 	}
 
-	diff, err := h.gitService.GetDiff(diffType)
+	opts := git.DiffOptions{
+		Context:   3,
+		IgnoreEOL: r.URL.Query().Get("ignoreEol") == "true",
+	}
+
+	expand := r.URL.Query().Get("expand") == "true"
+
+	if r.URL.Query().Get("stream") == "true" {
+		h.streamDiff(w, diffType, opts, expand)
+		return
+	}
+
+	diff, err := h.gitService.GetDiffWithOptions(diffType, opts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), diffErrorStatus(err))
 		return
 	}
 
+	if r.URL.Query().Get("format") == "patch" {
+		w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+		if err := git.WritePatch(w, diff.Files); err != nil {
+			log.Printf("Failed to write patch: %v", err)
+		}
+		return
+	}
+
+	if !expand {
+		for i := range diff.Files {
+			omitHunks(&diff.Files[i])
+		}
+	}
+
 	result := map[string]interface{}{
-		"files": diff.Files,
-		"type":  diffType,
+		"files":    diff.Files,
+		"type":     diffType,
+		"base":     diff.Base,
+		"readOnly": h.gitService.ReadOnly(),
 	}
 
 	h.writeJSON(w, result)
 }
 
+// omitHunks drops the hunks of generated, vendored and ignored files. They
+// still count in the stats but their hunks are only sent on request or
+// through GetFileDiff.
+func omitHunks(file *git.FileDiff) {
+	if file.Collapsed() {
+		file.Hunks = []git.Hunk{}
+		file.HunksOmitted = true
+	}
+}
+
+// streamDiff writes the same response as GetDiff while git is still producing
+// the diff, so memory stays bounded for huge diffs. Moved code isn't
+// detected, which the response flags with "movesOmitted". Once the first
+// file is sent an error can't change the status anymore, so it's logged and
+// the connection aborted rather than ending the document as if it was whole.
+func (h *Handler) streamDiff(w http.ResponseWriter, diffType git.DiffType, opts git.DiffOptions, expand bool) {
+	rc := http.NewResponseController(w)
+	// A huge diff can take longer to send than the server's write timeout
+	// allows, which would cut the document off mid-stream
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Failed to lift the write deadline for streaming: %v", err)
+	}
+
+	encoder := json.NewEncoder(w)
+	started := false
+
+	base, err := h.gitService.DiffBase(diffType)
+	if err != nil {
+		http.Error(w, err.Error(), diffErrorStatus(err))
+		return
+	}
+
+	// Files come last so everything before them can be written up front
+	start := func() error {
+		started = true
+		typeJSON, err := json.Marshal(diffType)
+		if err != nil {
+			return err
+		}
+		baseJSON, err := json.Marshal(base)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, err = fmt.Fprintf(w, `{"type":%s,"base":%s,"readOnly":%t,"movesOmitted":true,"files":[`, typeJSON, baseJSON, h.gitService.ReadOnly())
+		return err
+	}
+
+	err = h.gitService.StreamDiff(diffType, opts, func(file git.FileDiff) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		} else if _, err := w.Write([]byte(",")); err != nil {
+			return err
+		}
+
+		if !expand {
+			omitHunks(&file)
+		}
+		if err := encoder.Encode(file); err != nil {
+			return err
+		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		_, err = w.Write([]byte("]}\n"))
+	}
+
+	if err != nil {
+		if !started {
+			http.Error(w, err.Error(), diffErrorStatus(err))
+			return
+		}
+		log.Printf("Streaming diff failed: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// diffErrorStatus maps service errors to HTTP status codes
+func diffErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, git.ErrNoReviewSnapshot), errors.Is(err, git.ErrReadOnly), errors.Is(err, git.ErrNoPRBase):
+		return http.StatusConflict
+	case errors.Is(err, git.ErrUnknownWorktree), errors.Is(err, git.ErrInvalidRange), errors.Is(err, git.ErrInvalidSearch):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func (h *Handler) GetFileDiff(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	filename, err := url.QueryUnescape(vars["file"])
@@ -73,7 +249,7 @@ func (h *Handler) GetFileDiff(w http.ResponseWriter, r *http.Request) {
 
 	diff, err := h.gitService.GetFileDiff(filename, diffType)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), diffErrorStatus(err))
 		return
 	}
 
@@ -81,30 +257,412 @@ func (h *Handler) GetFileDiff(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) AddComment(w http.ResponseWriter, r *http.Request) {
+	h.reviewMu.RLock()
+	defer h.reviewMu.RUnlock()
+
 	var comment review.Comment
 	if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	// Lines are numbered in the diff the comment is made in
+	comment.DiffType = r.URL.Query().Get("type")
+
+	if comment.LineID != "" || comment.LineEndID != "" || comment.HunkID != "" {
+		if status, err := h.resolveDiffIDs(&comment); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+	}
 
-	h.reviewStore.AddComment(&comment)
+	h.anchorComment(&comment)
+
+	err := h.reviewStore.AddComment(&comment)
+	switch {
+	case errors.Is(err, review.ErrInvalidSeverity), errors.Is(err, review.ErrInvalidScope):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Failed to save comment: %v", err), http.StatusInternalServerError)
+		return
+	}
 
 	// Print immediately in text format
 	if h.format == "text" {
-		if comment.LineEnd != 0 && comment.LineEnd != comment.Line {
-			fmt.Printf("\n%s:%d-%d\n", comment.File, comment.Line, comment.LineEnd)
-		} else {
-			fmt.Printf("\n%s:%d\n", comment.File, comment.Line)
+		review.WriteText(os.Stdout, &comment)
+	}
+
+	h.writeJSON(w, comment)
+}
+
+// UpdateComment edits the content, range or labels of a comment. Text mode
+// prints the new version so whoever reads stdout sees the correction.
+func (h *Handler) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	h.reviewMu.RLock()
+	defer h.reviewMu.RUnlock()
+
+	id := mux.Vars(r)["id"]
+
+	var edit review.CommentEdit
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Moving a comment anchors it to the code at its new range
+	if edit.Line != nil || edit.LineEnd != nil || edit.LineID != "" || edit.LineEndID != "" {
+		current := h.reviewStore.GetComment(id)
+		if current == nil {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+			return
+		}
+		if edit.LineID != "" || edit.LineEndID != "" {
+			if status, err := h.resolveEditIDs(current, &edit); err != nil {
+				http.Error(w, err.Error(), status)
+				return
+			}
 		}
-		fmt.Printf("%s\n", comment.Content)
+		if edit.Line != nil {
+			current.Line = *edit.Line
+		}
+		if edit.LineEnd != nil {
+			current.LineEnd = *edit.LineEnd
+		}
+		h.anchorComment(current)
+		edit.Anchor = current.Anchor
+	}
+
+	comment, err := h.reviewStore.UpdateComment(id, edit)
+	switch {
+	case errors.Is(err, review.ErrCommentNotFound):
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	case errors.Is(err, review.ErrInvalidEdit), errors.Is(err, review.ErrInvalidSeverity):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Failed to save comment: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if h.format == "text" && comment.UpdatedAt != nil {
+		review.WriteText(os.Stdout, comment)
 	}
 
 	h.writeJSON(w, comment)
 }
 
+// diffIDs looks up hunk and line IDs in the diff a comment is made in. Line IDs are the same in every view of a diff, hunks merge as
+// context grows though, so IDs missing from the default diff are looked up
+// in the full context one the full file view shows next.
+type diffIDs struct {
+	service  *git.Service
+	diffType git.DiffType
+	diffs    []*git.DiffResult
+}
+
+func (h *Handler) diffIDs(comment *review.Comment) *diffIDs {
+	return &diffIDs{service: h.gitService, diffType: commentDiffType(comment.DiffType)}
+}
+
+// find calls match with each view of the diff until it reports a match
+func (d *diffIDs) find(match func(*git.DiffResult) bool) (int, error) {
+	for i, context := range []int{3, git.FullContext} {
+		if i == len(d.diffs) {
+			diff, err := d.service.GetDiff(d.diffType, context)
+			if err != nil {
+				return diffErrorStatus(err), err
+			}
+			d.diffs = append(d.diffs, diff)
+		}
+		if match(d.diffs[i]) {
+			return http.StatusOK, nil
+		}
+	}
+	return http.StatusNotFound, nil
+}
+
+func (d *diffIDs) hunk(id string) (*git.FileDiff, *git.Hunk, int, error) {
+	var file *git.FileDiff
+	var hunk *git.Hunk
+	status, err := d.find(func(diff *git.DiffResult) bool {
+		file, hunk = diff.FindHunk(id)
+		return hunk != nil
+	})
+	if err == nil && hunk == nil {
+		err = fmt.Errorf("hunk not found in diff: %s", id)
+	}
+	return file, hunk, status, err
+}
+
+func (d *diffIDs) line(id string) (*git.FileDiff, *git.Line, int, error) {
+	var file *git.FileDiff
+	var line *git.Line
+	status, err := d.find(func(diff *git.DiffResult) bool {
+		file, line = diff.FindLine(id)
+		return line != nil
+	})
+	if err == nil && line == nil {
+		err = fmt.Errorf("line not found in diff: %s", id)
+	}
+	return file, line, status, err
+}
+
+// resolveDiffIDs fills in the file, side and line numbers of a comment from
+// the hunk or line IDs it was created with
+func (h *Handler) resolveDiffIDs(comment *review.Comment) (int, error) {
+	ids := h.diffIDs(comment)
+
+	if comment.HunkID != "" {
+		file, hunk, status, err := ids.hunk(comment.HunkID)
+		if err != nil {
+			return status, err
+		}
+		comment.Scope = review.ScopeHunk
+		comment.File = file.Path
+		comment.Hunk = hunk.Header
+		// A hunk that only deletes lines is addressed by its old side
+		if hunk.NewLines == 0 {
+			comment.Side = string(git.SideOld)
+			comment.Line, comment.LineEnd = hunk.OldStart, hunk.OldStart+hunk.OldLines-1
+		} else {
+			comment.Side = string(git.SideNew)
+			comment.Line, comment.LineEnd = hunk.NewStart, hunk.NewStart+hunk.NewLines-1
+		}
+		return http.StatusOK, nil
+	}
+
+	if comment.LineID != "" {
+		file, line, status, err := ids.line(comment.LineID)
+		if err != nil {
+			return status, err
+		}
+		side, number := line.Position()
+		comment.File = file.Path
+		comment.Side = string(side)
+		comment.Line = number
+	}
+
+	if comment.LineEndID != "" {
+		file, line, status, err := ids.line(comment.LineEndID)
+		if err != nil {
+			return status, err
+		}
+		if comment.File != "" && file.Path != comment.File {
+			return http.StatusBadRequest, errors.New("lineEndId is in a different file than the comment")
+		}
+		_, number := line.Position()
+		comment.File = file.Path
+		comment.LineEnd = number
+	}
+
+	return http.StatusOK, nil
+}
+
+// resolveEditIDs turns the line IDs of an edit into line numbers of the
+// comment's file, numbered the way clients send them with deleted lines
+// negative
+func (h *Handler) resolveEditIDs(comment *review.Comment, edit *review.CommentEdit) (int, error) {
+	ids := h.diffIDs(comment)
+	for _, ref := range []struct {
+		id     string
+		number **int
+	}{
+		{edit.LineID, &edit.Line},
+		{edit.LineEndID, &edit.LineEnd},
+	} {
+		if ref.id == "" {
+			continue
+		}
+		file, line, status, err := ids.line(ref.id)
+		if err != nil {
+			return status, err
+		}
+		if file.Path != comment.File {
+			return http.StatusBadRequest, fmt.Errorf("line %s is in a different file than the comment", ref.id)
+		}
+		side, number := line.Position()
+		if side == git.SideOld {
+			number = -number
+		} else if comment.Side == string(git.SideOld) {
+			return http.StatusBadRequest, fmt.Errorf("line %s isn't a deleted line like the comment's", ref.id)
+		}
+		*ref.number = &number
+	}
+	return http.StatusOK, nil
+}
+
+// anchorComment records the code a comment is on so it can follow that code
+// later. Comments on files that can't be read, such as in patches, stay
+// where they are made.
+func (h *Handler) anchorComment(comment *review.Comment) {
+	side, line, lineEnd := comment.Side, comment.Line, comment.LineEnd
+	// The UI numbers deleted lines negatively
+	if line < 0 && lineEnd <= 0 {
+		side, line, lineEnd = string(git.SideOld), -line, -lineEnd
+	}
+	if line <= 0 {
+		return
+	}
+
+	revs, err := h.gitService.Revisions(commentDiffType(comment.DiffType))
+	if err != nil {
+		return
+	}
+	content, err := h.gitService.ReadFile(revs, commentSide(side), comment.File)
+	if err != nil {
+		return
+	}
+	comment.Anchor = review.NewAnchor(review.SplitLines(content), line, lineEnd)
+}
+
+// ReanchorComments moves comments along with their code after the working
+// tree changed, each in the diff it was made in
+func (h *Handler) ReanchorComments() {
+	if h.gitService.ReadOnly() {
+		return
+	}
+
+	h.reviewMu.RLock()
+	defer h.reviewMu.RUnlock()
+
+	err := h.reviewStore.Reanchor(func(diffType string) (*review.Source, error) {
+		diff, err := h.gitService.GetDiff(commentDiffType(diffType))
+		if err != nil {
+			return nil, err
+		}
+		revs := h.gitService.DiffRevisions(diff)
+
+		renames := make(map[string]string)
+		for _, file := range diff.Files {
+			if file.Status == git.FileStatusRenamed && file.OldPath != "" {
+				renames[file.OldPath] = file.Path
+			}
+		}
+
+		return &review.Source{
+			Renames: renames,
+			ReadFile: func(path, side string) ([]string, error) {
+				content, err := h.gitService.ReadFile(revs, commentSide(side), path)
+				if err != nil {
+					return nil, err
+				}
+				return review.SplitLines(content), nil
+			},
+		}, nil
+	})
+	if err != nil {
+		log.Printf("Failed to save re-anchored comments: %v", err)
+	}
+}
+
+// commentDiffType returns the diff a comment was made in, all changes by default
+func commentDiffType(diffType string) git.DiffType {
+	if diffType == "" {
+		return git.DiffTypeAll
+	}
+	return git.DiffType(diffType)
+}
+
+// commentSide returns the side of the diff a comment is on, new by default
+func commentSide(side string) git.Side {
+	if side == string(git.SideOld) {
+		return git.SideOld
+	}
+	return git.SideNew
+}
+
+// AddReply answers a comment, continuing its thread
+func (h *Handler) AddReply(w http.ResponseWriter, r *http.Request) {
+	h.reviewMu.RLock()
+	defer h.reviewMu.RUnlock()
+
+	var reply review.Comment
+	if err := json.NewDecoder(r.Body).Decode(&reply); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := h.reviewStore.AddReply(mux.Vars(r)["id"], &reply)
+	switch {
+	case errors.Is(err, review.ErrCommentNotFound):
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	case errors.Is(err, review.ErrInvalidSeverity):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Failed to save comment: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if h.format == "text" {
+		review.WriteText(os.Stdout, &reply)
+	}
+	if h.hub != nil {
+		h.hub.NotifyComment("comment_reply", &reply)
+	}
+
+	h.writeJSON(w, reply)
+}
+
+// GetThread returns the thread a comment belongs to
+func (h *Handler) GetThread(w http.ResponseWriter, r *http.Request) {
+	thread := h.reviewStore.GetThread(mux.Vars(r)["id"])
+	if thread == nil {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+
+	h.writeJSON(w, thread)
+}
+
+// commentFilter reads the comma separated "status", "severity" and "label"
+// query parameters
+func commentFilter(r *http.Request) (review.Filter, error) {
+	var filter review.Filter
+	var err error
+
+	query := r.URL.Query()
+	if filter.Statuses, err = review.ParseStatuses(query.Get("status")); err != nil {
+		return filter, err
+	}
+	if filter.Severities, err = review.ParseSeverities(query.Get("severity")); err != nil {
+		return filter, err
+	}
+	filter.Labels = review.ParseLabels(query.Get("label"))
+	return filter, nil
+}
+
+// GetThreads returns every comment with its replies, filtered like
+// GetComments. sort=severity puts blockers first.
+func (h *Handler) GetThreads(w http.ResponseWriter, r *http.Request) {
+	filter, err := commentFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	threads := review.FilterThreads(h.reviewStore.GetThreads(), filter)
+	if r.URL.Query().Get("sort") == "severity" {
+		review.SortThreadsBySeverity(threads)
+	}
+
+	h.writeJSON(w, threads)
+}
+
+// GetComments returns comments, optionally only those of one file or with
+// one of the given statuses, severities or labels. sort=severity puts
+// blockers first.
 func (h *Handler) GetComments(w http.ResponseWriter, r *http.Request) {
 	file := r.URL.Query().Get("file")
 
+	filter, err := commentFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	var comments []*review.Comment
 	if file != "" {
 		comments = h.reviewStore.GetComments(file)
@@ -112,20 +670,437 @@ func (h *Handler) GetComments(w http.ResponseWriter, r *http.Request) {
 		comments = h.reviewStore.GetAllComments()
 	}
 
+	comments = review.FilterComments(comments, filter)
+	if r.URL.Query().Get("sort") == "severity" {
+		review.SortBySeverity(comments)
+	}
+
 	h.writeJSON(w, comments)
 }
 
+// SetCommentStatus resolves, reopens or otherwise changes the status of a
+// comment's thread
+func (h *Handler) SetCommentStatus(w http.ResponseWriter, r *http.Request) {
+	h.reviewMu.RLock()
+	defer h.reviewMu.RUnlock()
+
+	var req struct {
+		Status review.Status `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	comment, err := h.reviewStore.SetStatus(mux.Vars(r)["id"], req.Status)
+	switch {
+	case errors.Is(err, review.ErrCommentNotFound):
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	case errors.Is(err, review.ErrInvalidStatus):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Failed to save comment: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if h.format == "text" {
+		review.WriteText(os.Stdout, comment)
+	}
+	if h.hub != nil {
+		h.hub.NotifyComment("comment_status", comment)
+	}
+
+	h.writeJSON(w, comment)
+}
+
+// ApplySuggestion writes a comment's suggested change to the working tree
+// file and resolves the comment. It fails with 409 Conflict when the
+// commented lines no longer read what they did when the comment was made.
+func (h *Handler) ApplySuggestion(w http.ResponseWriter, r *http.Request) {
+	h.reviewMu.RLock()
+	defer h.reviewMu.RUnlock()
+
+	id := mux.Vars(r)["id"]
+
+	comment := h.reviewStore.GetComment(id)
+	switch {
+	case comment == nil:
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	case comment.Suggestion == nil:
+		http.Error(w, "Comment has no suggestion", http.StatusBadRequest)
+		return
+	case !comment.CanApply():
+		http.Error(w, git.ErrContentChanged.Error(), http.StatusConflict)
+		return
+	}
+
+	end := comment.Line + len(comment.Anchor.Lines) - 1
+	err := h.gitService.ReplaceLines(comment.File, comment.Line, end, comment.Anchor.Lines, review.SuggestionLines(*comment.Suggestion))
+	switch {
+	case errors.Is(err, git.ErrContentChanged):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), diffErrorStatus(err))
+		return
+	}
+
+	comment, err = h.reviewStore.SetStatus(id, review.StatusResolved)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Suggestion applied but the comment couldn't be resolved: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if h.format == "text" {
+		review.WriteText(os.Stdout, comment)
+	}
+	if h.hub != nil {
+		h.hub.NotifyComment("comment_status", comment)
+	}
+
+	h.writeJSON(w, comment)
+}
+
+// ExportComments returns the threads that still matter, blockers first, as
+// JSON or with format=text the way text mode prints them. Resolved threads
+// are skipped unless "status" asks for them.
+func (h *Handler) ExportComments(w http.ResponseWriter, r *http.Request) {
+	filter, err := commentFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.Statuses == nil {
+		filter.Statuses = review.ExportFilter().Statuses
+	}
+
+	threads := review.FilterThreads(h.reviewStore.GetThreads(), filter)
+	review.SortThreadsBySeverity(threads)
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		review.WriteThreadsText(w, threads)
+		return
+	}
+
+	h.writeJSON(w, threads)
+}
+
 func (h *Handler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	h.reviewMu.RLock()
+	defer h.reviewMu.RUnlock()
+
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	if h.reviewStore.DeleteComment(id) {
+	deleted, err := h.reviewStore.DeleteComment(id)
+	switch {
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Failed to save review: %v", err), http.StatusInternalServerError)
+	case deleted:
 		w.WriteHeader(http.StatusNoContent)
-	} else {
+	default:
 		http.Error(w, "Comment not found", http.StatusNotFound)
 	}
 }
 
+// GetAPIChanges reports how the exported API of the changed Go packages differs
+func (h *Handler) GetAPIChanges(w http.ResponseWriter, r *http.Request) {
+	diffType := git.DiffType(r.URL.Query().Get("type"))
+	if diffType == "" {
+		diffType = git.DiffTypeAll
+	}
+
+	diff, err := h.gitService.GetDiff(diffType)
+	if err != nil {
+		http.Error(w, err.Error(), diffErrorStatus(err))
+		return
+	}
+
+	report, err := apidiff.Compare(h.gitService, diff)
+	if err != nil {
+		http.Error(w, err.Error(), diffErrorStatus(err))
+		return
+	}
+
+	h.writeJSON(w, report)
+}
+
+// GetStats aggregates the diff by directory, language, Go package and status
+func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+	diffType := git.DiffType(r.URL.Query().Get("type"))
+	if diffType == "" {
+		diffType = git.DiffTypeAll
+	}
+
+	top := stats.DefaultTop
+	if value := r.URL.Query().Get("top"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid top parameter", http.StatusBadRequest)
+			return
+		}
+		top = n
+	}
+
+	diff, err := h.gitService.GetDiff(diffType)
+	if err != nil {
+		http.Error(w, err.Error(), diffErrorStatus(err))
+		return
+	}
+
+	h.writeJSON(w, stats.Compute(diff, h.gitService.GoModulePath(h.gitService.DiffRevisions(diff)), top))
+}
+
+// GetOutlines returns the changed symbol outline of the diff's Go files, keyed by path
+func (h *Handler) GetOutlines(w http.ResponseWriter, r *http.Request) {
+	diffType := git.DiffType(r.URL.Query().Get("type"))
+	if diffType == "" {
+		diffType = git.DiffTypeAll
+	}
+
+	outlines, err := h.gitService.GetOutlines(diffType)
+	if err != nil {
+		http.Error(w, err.Error(), diffErrorStatus(err))
+		return
+	}
+
+	h.writeJSON(w, outlines)
+}
+
+// ExportRequest selects the parts of the diff to download
+type ExportRequest struct {
+	git.PatchSelection
+	Type git.DiffType `json:"type"`
+	// Format is "patch" (default) or "mbox"
+	Format string `json:"format"`
+	// Subject and Message become the commit message of an mbox export
+	Subject string `json:"subject"`
+	Message string `json:"message"`
+}
+
+// ExportPatch returns the selected files, hunks and lines as a patch file or
+// a "git format-patch" style mbox
+func (h *Handler) ExportPatch(w http.ResponseWriter, r *http.Request) {
+	var req ExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Type == "" {
+		req.Type = git.DiffTypeAll
+	}
+	if req.Format == "" {
+		req.Format = "patch"
+	}
+	if req.Format != "patch" && req.Format != "mbox" {
+		http.Error(w, "Format must be patch or mbox", http.StatusBadRequest)
+		return
+	}
+
+	diff, err := h.gitService.GetDiff(req.Type)
+	if err != nil {
+		http.Error(w, err.Error(), diffErrorStatus(err))
+		return
+	}
+
+	files := diff.Select(req.PatchSelection)
+	if len(files) == 0 {
+		http.Error(w, "Nothing selected", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="vibediff.%s"`, req.Format))
+
+	if req.Format == "patch" {
+		err = git.WritePatch(w, files)
+	} else {
+		header := git.MailHeader{
+			Date:    time.Now(),
+			Subject: req.Subject,
+			Message: req.Message,
+		}
+		header.Author, header.Email = h.gitService.Author()
+		if header.Author == "" {
+			header.Author = "VibeDiff"
+		}
+		if header.Email == "" {
+			header.Email = "vibediff@localhost"
+		}
+		if header.Subject == "" {
+			header.Subject = "Changes exported from VibeDiff"
+		}
+		err = git.WriteMbox(w, header, files)
+	}
+	if err != nil {
+		log.Printf("Failed to write export: %v", err)
+	}
+}
+
+// Search finds lines matching "q" in the diff and, with "tree" set to old
+// or new, in every file on that side of it
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	diffType := git.DiffType(query.Get("type"))
+	if diffType == "" {
+		diffType = git.DiffTypeAll
+	}
+
+	opts := git.SearchOptions{
+		Query:      query.Get("q"),
+		Regexp:     query.Get("regex") == "true",
+		IgnoreCase: query.Get("ignoreCase") == "true",
+		Tree:       git.Side(query.Get("tree")),
+	}
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		opts.Limit = n
+	}
+
+	result, err := h.gitService.Search(diffType, opts)
+	if err != nil {
+		http.Error(w, err.Error(), diffErrorStatus(err))
+		return
+	}
+
+	h.writeJSON(w, result)
+}
+
+// GetRangeDiff compares two versions of a patch series given as the "old"
+// and "new" commit ranges
+func (h *Handler) GetRangeDiff(w http.ResponseWriter, r *http.Request) {
+	rangeDiff, err := h.gitService.GetRangeDiff(r.URL.Query().Get("old"), r.URL.Query().Get("new"))
+	if err != nil {
+		http.Error(w, err.Error(), diffErrorStatus(err))
+		return
+	}
+
+	h.writeJSON(w, rangeDiff)
+}
+
+// GetWorktrees lists the worktrees of the repository under review
+func (h *Handler) GetWorktrees(w http.ResponseWriter, r *http.Request) {
+	worktrees, err := h.gitService.ListWorktrees()
+	if err != nil {
+		http.Error(w, err.Error(), diffErrorStatus(err))
+		return
+	}
+
+	h.writeJSON(w, worktrees)
+}
+
+// SelectWorktree switches the review to another worktree
+func (h *Handler) SelectWorktree(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Path string `json:"path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Wait for comment changes made in the old worktree to finish, then
+	// switch the worktree and its review together
+	h.reviewMu.Lock()
+	worktree, err := h.gitService.SelectWorktree(req.Path)
+	if err != nil {
+		h.reviewMu.Unlock()
+		http.Error(w, err.Error(), diffErrorStatus(err))
+		return
+	}
+
+	// Each worktree has its own branch and so its own saved review
+	h.reopenReview()
+	h.reviewMu.Unlock()
+	h.ReanchorComments()
+
+	// Clients reload once the new review is in place
+	if h.watcher != nil {
+		h.watcher.SetWorkDir(h.gitService.WorkDir())
+	}
+
+	h.writeJSON(w, worktree)
+}
+
+// ReloadReview switches to the saved review of the branch now checked out,
+// for the watcher to call when HEAD moves to another branch
+func (h *Handler) ReloadReview() {
+	h.reviewMu.Lock()
+	defer h.reviewMu.Unlock()
+	h.reopenReview()
+}
+
+// reopenReview opens the saved review of the current worktree, branch and
+// diff target unless it is already open. Reviews kept in memory only, such
+// as of patches, stay as they are. Callers hold reviewMu for writing.
+func (h *Handler) reopenReview() {
+	current := h.reviewStore.Path()
+	if current == "" {
+		return
+	}
+
+	path, err := h.gitService.ReviewStatePath()
+	if err == nil && path != current {
+		err = h.reviewStore.Open(path)
+	}
+	if err != nil {
+		log.Printf("Failed to open review %s: %v", path, err)
+	}
+}
+
+// ArchiveReview sets the finished review aside and starts an empty one
+func (h *Handler) ArchiveReview(w http.ResponseWriter, r *http.Request) {
+	h.reviewMu.RLock()
+	defer h.reviewMu.RUnlock()
+
+	count := len(h.reviewStore.GetAllComments())
+	archived, err := h.reviewStore.Archive()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if h.hub != nil {
+		h.hub.NotifyChange("review_archived")
+	}
+
+	h.writeJSON(w, map[string]interface{}{
+		"archived": archived,
+		"comments": count,
+	})
+}
+
+// EndReviewRound snapshots the reviewed working tree so the next round can
+// show only what changed since
+func (h *Handler) EndReviewRound(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := h.gitService.EndReviewRound()
+	if err != nil {
+		http.Error(w, err.Error(), diffErrorStatus(err))
+		return
+	}
+	// Comments made since the last round are now on the new round's base
+	h.ReanchorComments()
+
+	h.writeJSON(w, snapshot)
+}
+
+func (h *Handler) GetReviewRound(w http.ResponseWriter, r *http.Request) {
+	snapshot := h.gitService.ReviewSnapshot()
+	if snapshot == nil {
+		http.Error(w, git.ErrNoReviewSnapshot.Error(), http.StatusNotFound)
+		return
+	}
+
+	h.writeJSON(w, snapshot)
+}
+
 func (h *Handler) GetFullFileWithDiff(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	filename, err := url.QueryUnescape(vars["file"])
@@ -141,7 +1116,7 @@ func (h *Handler) GetFullFileWithDiff(w http.ResponseWriter, r *http.Request) {
 
 	diff, err := h.gitService.GetFileDiffWithFullContext(filename, diffType)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), diffErrorStatus(err))
 		return
 	}
 
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/malvex/vibediff/internal/git"
+	"github.com/malvex/vibediff/internal/review"
+)
+
+// testHandler serves a repository with files committed and then changed to
+// the contents in changed
+func testHandler(t *testing.T, files, changed map[string]string) *Handler {
+	t.Helper()
+	t.Setenv("GIT_AUTHOR_NAME", "a")
+	t.Setenv("GIT_AUTHOR_EMAIL", "a@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "a")
+	t.Setenv("GIT_COMMITTER_EMAIL", "a@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	dir := t.TempDir()
+	write := func(files map[string]string) {
+		for path, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, path), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	runGit := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+		}
+	}
+	runGit("init", "-q")
+	write(files)
+	runGit("add", "-A")
+	runGit("commit", "-q", "-m", "initial")
+	write(changed)
+
+	service := git.NewService()
+	if err := service.SetWorkDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	return NewHandler(service, review.NewStore())
+}
+
+// serve calls a handler function and decodes its JSON response into out,
+// returning the status code
+func serve(t *testing.T, handle http.HandlerFunc, method, target, body string, vars map[string]string, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if vars != nil {
+		req = mux.SetURLVars(req, vars)
+	}
+	rec := httptest.NewRecorder()
+	handle(rec, req)
+	if rec.Code == http.StatusOK && out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: %v\n%s", method, target, err, rec.Body.String())
+		}
+	}
+	return rec.Code
+}
+
+// numbered returns n lines "line 01\n" to "line n\n"
+func numbered(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line %02d\n", i)
+	}
+	return b.String()
+}
+
+// location is where a comment ended up
+type location struct {
+	File, Side    string
+	Line, LineEnd int
+	Scope         review.Scope
+}
+
+func TestCommentOnDiffIDs(t *testing.T) {
+	original := numbered(40)
+	changed := strings.Replace(strings.Replace(original, "line 10\n", "ten\n", 1), "line 20\n", "twenty\n", 1)
+	h := testHandler(t, map[string]string{"f.txt": original}, map[string]string{"f.txt": changed})
+
+	var short, full git.FileDiff
+	if code := serve(t, h.GetFileDiff, "GET", "/api/diff/f.txt", "", map[string]string{"file": "f.txt"}, &short); code != http.StatusOK {
+		t.Fatalf("diff returned %d", code)
+	}
+	if code := serve(t, h.GetFullFileWithDiff, "GET", "/api/diff/f.txt/full", "", map[string]string{"file": "f.txt"}, &full); code != http.StatusOK {
+		t.Fatalf("full diff returned %d", code)
+	}
+	if len(short.Hunks) != 2 || len(full.Hunks) != 1 {
+		t.Fatalf("got %d and %d hunks, want 2 in the diff and 1 in the full view", len(short.Hunks), len(full.Hunks))
+	}
+
+	// findLine returns the ID of the line of a view with the given content
+	findLine := func(file git.FileDiff, lineType git.LineType, content string) string {
+		for _, hunk := range file.Hunks {
+			for _, line := range hunk.Lines {
+				if line.Type == lineType && line.Content == content {
+					return line.ID
+				}
+			}
+		}
+		t.Fatalf("no %s line %q", lineType, content)
+		return ""
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		want    location
+		wantErr int
+	}{
+		{
+			name: "line from the diff",
+			body: `{"lineId": "` + findLine(short, git.LineTypeAdded, "ten") + `", "content": "c"}`,
+			want: location{File: "f.txt", Side: "new", Line: 10, Scope: review.ScopeLine},
+		},
+		{
+			// Line 1 is only in the full view
+			name: "range from the full view",
+			body: `{"lineId": "` + findLine(full, git.LineTypeContext, "line 01") + `", "lineEndId": "` +
+				findLine(full, git.LineTypeAdded, "twenty") + `", "content": "c"}`,
+			want: location{File: "f.txt", Side: "new", Line: 1, LineEnd: 20, Scope: review.ScopeLine},
+		},
+		{
+			name: "deleted line from the full view",
+			body: `{"lineId": "` + findLine(full, git.LineTypeDeleted, "line 20") + `", "content": "c"}`,
+			want: location{File: "f.txt", Side: "old", Line: 20, Scope: review.ScopeLine},
+		},
+		{
+			name: "hunk from the diff",
+			body: `{"hunkId": "` + short.Hunks[1].ID + `", "content": "c"}`,
+			want: location{File: "f.txt", Side: "new", Line: 17, LineEnd: 23, Scope: review.ScopeHunk},
+		},
+		{
+			// Both changes are one hunk in the full view
+			name: "hunk from the full view",
+			body: `{"hunkId": "` + full.Hunks[0].ID + `", "content": "c"}`,
+			want: location{File: "f.txt", Side: "new", Line: 1, LineEnd: 40, Scope: review.ScopeHunk},
+		},
+		{
+			name:    "unknown line",
+			body:    `{"lineId": "0000000000000000", "content": "c"}`,
+			wantErr: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var comment review.Comment
+			code := serve(t, h.AddComment, "POST", "/api/review/comment", tt.body, nil, &comment)
+			if tt.wantErr != 0 {
+				if code != tt.wantErr {
+					t.Errorf("returned %d, want %d", code, tt.wantErr)
+				}
+				return
+			}
+			if code != http.StatusOK {
+				t.Fatalf("returned %d", code)
+			}
+			got := location{comment.File, comment.Side, comment.Line, comment.LineEnd, comment.Scope}
+			if got != tt.want {
+				t.Errorf("comment at %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditCommentWithLineIDs(t *testing.T) {
+	original := numbered(20)
+	changed := strings.Replace(original, "line 10\n", "ten\n", 1)
+	h := testHandler(t, map[string]string{"f.txt": original}, map[string]string{"f.txt": changed})
+
+	var full git.FileDiff
+	if code := serve(t, h.GetFullFileWithDiff, "GET", "/api/diff/f.txt/full", "", map[string]string{"file": "f.txt"}, &full); code != http.StatusOK {
+		t.Fatalf("full diff returned %d", code)
+	}
+	ids := make(map[string]string)
+	for _, line := range full.Hunks[0].Lines {
+		ids[string(line.Type)+" "+line.Content] = line.ID
+	}
+
+	var comment review.Comment
+	if code := serve(t, h.AddComment, "POST", "/api/review/comment", `{"file": "f.txt", "line": 10, "content": "c"}`, nil, &comment); code != http.StatusOK {
+		t.Fatalf("adding returned %d", code)
+	}
+	vars := map[string]string{"id": comment.ID}
+
+	var edited review.Comment
+	body := `{"lineId": "` + ids["context line 02"] + `", "lineEndId": "` + ids["context line 04"] + `"}`
+	if code := serve(t, h.UpdateComment, "PATCH", "/api/review/comment/"+comment.ID, body, vars, &edited); code != http.StatusOK {
+		t.Fatalf("editing returned %d", code)
+	}
+	if edited.Line != 2 || edited.LineEnd != 4 || commentSide(edited.Side) != git.SideNew {
+		t.Errorf("moved to %q %d-%d, want new 2-4", edited.Side, edited.Line, edited.LineEnd)
+	}
+
+	// A new side comment can't end up on a deleted line by its end alone
+	body = `{"lineEndId": "` + ids["deleted line 10"] + `"}`
+	if code := serve(t, h.UpdateComment, "PATCH", "/api/review/comment/"+comment.ID, body, vars, nil); code != http.StatusBadRequest {
+		t.Errorf("mixing sides returned %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestRejectCrossSite(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{name: "no origin", want: http.StatusOK},
+		{name: "same origin", headers: map[string]string{"Origin": "http://localhost:8888"}, want: http.StatusOK},
+		{name: "same site fetch", headers: map[string]string{"Sec-Fetch-Site": "same-origin"}, want: http.StatusOK},
+		{name: "other origin", headers: map[string]string{"Origin": "http://evil.example"}, want: http.StatusForbidden},
+		{name: "other port", headers: map[string]string{"Origin": "http://localhost:3000"}, want: http.StatusForbidden},
+		{name: "invalid origin", headers: map[string]string{"Origin": "://"}, want: http.StatusForbidden},
+		{name: "cross-site fetch", headers: map[string]string{"Sec-Fetch-Site": "cross-site"}, want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handle := RejectCrossSite(func(w http.ResponseWriter, r *http.Request) { called = true })
+			req := httptest.NewRequest("POST", "http://localhost:8888/api/review/comment/1/apply", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			handle(rec, req)
+			if rec.Code != tt.want || called != (tt.want == http.StatusOK) {
+				t.Errorf("returned %d, handled %v, want %d", rec.Code, called, tt.want)
+			}
+		})
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"github.com/malvex/vibediff/internal/review"
 )
 
 var upgrader = websocket.Upgrader{
@@ -95,11 +97,23 @@ func (h *WSHub) Shutdown() {
 
 // NotifyChange sends a change notification to all connected clients
 func (h *WSHub) NotifyChange(changeType string) {
-	data := map[string]interface{}{
+	h.send(map[string]interface{}{
 		"type":      changeType,
 		"timestamp": time.Now().Unix(),
-	}
+	})
+}
+
+// NotifyComment sends a comment event, such as "comment_reply", along with
+// the comment to all connected clients
+func (h *WSHub) NotifyComment(event string, comment *review.Comment) {
+	h.send(map[string]interface{}{
+		"type":      event,
+		"timestamp": time.Now().Unix(),
+		"comment":   comment,
+	})
+}
 
+func (h *WSHub) send(data map[string]interface{}) {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		if os.Getenv("VIBEDIFF_DEBUG") == "true" {
@@ -0,0 +1,136 @@
+package review
+
+import (
+	"strings"
+)
+
+// anchorContext is how many lines around the commented ones an anchor keeps
+const anchorContext = 3
+
+// minAnchorSimilarity is how alike the commented lines and their new
+// location must be, 1 being identical after trimming whitespace
+const minAnchorSimilarity = 0.75
+
+// Anchor remembers the code a comment was made on so the comment can follow
+// it when lines are added above or the code is edited
+type Anchor struct {
+	Lines  []string `json:"lines"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+// NewAnchor captures lines line to lineEnd (1-based, inclusive) of content.
+// It returns nil when the range is outside the content.
+func NewAnchor(content []string, line, lineEnd int) *Anchor {
+	if lineEnd < line {
+		lineEnd = line
+	}
+	if line < 1 || lineEnd > len(content) {
+		return nil
+	}
+
+	start, end := line-1, lineEnd
+	return &Anchor{
+		Lines:  append([]string(nil), content[start:end]...),
+		Before: append([]string(nil), content[max(0, start-anchorContext):start]...),
+		After:  append([]string(nil), content[end:min(len(content), end+anchorContext)]...),
+	}
+}
+
+// Locate finds where the anchored lines are in content now, preferring the
+// match closest to hint (the line they were last seen at). It returns the
+// new first line, or false when nothing is similar enough.
+func (a *Anchor) Locate(content []string, hint int) (int, bool) {
+	n := len(a.Lines)
+	if n == 0 || n > len(content) {
+		return 0, false
+	}
+
+	best, bestScore := 0, 0.0
+	for start := 0; start+n <= len(content); start++ {
+		similarity := 0.0
+		for i, line := range a.Lines {
+			similarity += lineSimilarity(line, content[start+i])
+		}
+		similarity /= float64(n)
+		if similarity < minAnchorSimilarity {
+			continue
+		}
+
+		// Matching context decides between otherwise equal candidates, and
+		// distance from the hint between equal contexts
+		score := similarity + 0.1*contextMatches(a, content, start)
+		if best == 0 || score > bestScore || (score == bestScore && distance(start+1, hint) < distance(best, hint)) {
+			best, bestScore = start+1, score
+		}
+	}
+
+	return best, best != 0
+}
+
+// contextMatches returns the fraction of context lines found unchanged
+// around a candidate position
+func contextMatches(a *Anchor, content []string, start int) float64 {
+	total := len(a.Before) + len(a.After)
+	if total == 0 {
+		return 0
+	}
+
+	matched := 0
+	for i, line := range a.Before {
+		k := start - len(a.Before) + i
+		if k >= 0 && strings.TrimSpace(content[k]) == strings.TrimSpace(line) {
+			matched++
+		}
+	}
+	for i, line := range a.After {
+		k := start + len(a.Lines) + i
+		if k < len(content) && strings.TrimSpace(content[k]) == strings.TrimSpace(line) {
+			matched++
+		}
+	}
+	return float64(matched) / float64(total)
+}
+
+// lineSimilarity compares two lines ignoring surrounding whitespace, by how
+// much of them is a common prefix or suffix. Small edits in the middle of a
+// line, such as an added argument, keep it similar.
+func lineSimilarity(a, b string) float64 {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if a == b {
+		return 1
+	}
+	if a == "" || b == "" {
+		return 0
+	}
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	return 2 * float64(prefix+suffix) / float64(len(a)+len(b))
+}
+
+func distance(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// SplitLines splits file content into lines for anchoring
+func SplitLines(content []byte) []string {
+	text := strings.TrimSuffix(string(content), "\n")
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
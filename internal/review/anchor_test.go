@@ -0,0 +1,168 @@
+package review
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewAnchor(t *testing.T) {
+	content := []string{"1", "2", "3", "4", "5", "6", "7", "8"}
+
+	tests := []struct {
+		name          string
+		line, lineEnd int
+		want          *Anchor
+	}{
+		{"single line", 5, 0, &Anchor{Lines: []string{"5"}, Before: []string{"2", "3", "4"}, After: []string{"6", "7", "8"}}},
+		{"range at the start", 1, 2, &Anchor{Lines: []string{"1", "2"}, After: []string{"3", "4", "5"}}},
+		{"range at the end", 7, 8, &Anchor{Lines: []string{"7", "8"}, Before: []string{"4", "5", "6"}}},
+		{"before the file", 0, 1, nil},
+		{"past the file", 8, 9, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewAnchor(content, tt.line, tt.lineEnd); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewAnchor(%d, %d) = %+v, want %+v", tt.line, tt.lineEnd, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnchorLocate(t *testing.T) {
+	original := strings.Split("package main\n\nfunc main() {\n\tconfig := load()\n\trun(config)\n}\n\nfunc load() {}", "\n")
+	anchor := NewAnchor(original, 4, 5)
+
+	tests := []struct {
+		name    string
+		content string
+		hint    int
+		want    int
+		found   bool
+	}{
+		{
+			name:    "unchanged",
+			content: "package main\n\nfunc main() {\n\tconfig := load()\n\trun(config)\n}\n\nfunc load() {}",
+			hint:    4, want: 4, found: true,
+		},
+		{
+			name:    "lines added above",
+			content: "package main\n\nimport \"os\"\n\nfunc main() {\n\tconfig := load()\n\trun(config)\n}\n\nfunc load() {}",
+			hint:    4, want: 6, found: true,
+		},
+		{
+			name:    "reindented",
+			content: "package main\n\nfunc main() {\n\tif ok {\n\t\tconfig := load()\n\t\trun(config)\n\t}\n}",
+			hint:    4, want: 5, found: true,
+		},
+		{
+			name:    "slightly edited",
+			content: "package main\n\nfunc main() {\n\tconfig := load(os.Args)\n\trun(config)\n}",
+			hint:    4, want: 4, found: true,
+		},
+		{
+			name:    "context decides between copies",
+			content: "func other() {\n\tconfig := load()\n\trun(config)\n}\n\nfunc main() {\n\tconfig := load()\n\trun(config)\n}\n\nfunc load() {}",
+			hint:    2, want: 7, found: true,
+		},
+		{
+			name:    "removed",
+			content: "package main\n\nfunc main() {\n\tstart()\n}",
+			hint:    4, found: false,
+		},
+		{
+			name:    "file emptied",
+			content: "",
+			hint:    4, found: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := anchor.Locate(SplitLines([]byte(tt.content)), tt.hint)
+			if got != tt.want || found != tt.found {
+				t.Errorf("Locate = %d, %v, want %d, %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestAnchorLocatePrefersHint(t *testing.T) {
+	// Identical copies with identical context, the one nearest the hint wins
+	content := strings.Split("x\ny\nrepeatedLine()\nx\ny\nrepeatedLine()\nx\ny", "\n")
+	anchor := &Anchor{Lines: []string{"repeatedLine()"}}
+	for _, tt := range []struct{ hint, want int }{{1, 3}, {6, 6}, {8, 6}} {
+		if got, _ := anchor.Locate(content, tt.hint); got != tt.want {
+			t.Errorf("Locate with hint %d = %d, want %d", tt.hint, got, tt.want)
+		}
+	}
+}
+
+func TestReanchor(t *testing.T) {
+	s := NewStore()
+	add := func(c *Comment) *Comment {
+		t.Helper()
+		if err := s.AddComment(c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	before := []string{"a", "b", "target()", "c", "d"}
+	moved := add(&Comment{File: "f.go", Line: 3, Content: "moved", Anchor: NewAnchor(before, 3, 0)})
+	gone := add(&Comment{File: "f.go", Line: 1, Content: "gone", Anchor: &Anchor{Lines: []string{"removedEntirely()"}}})
+	renamed := add(&Comment{File: "old.go", Content: "renamed file"})
+	staged := add(&Comment{File: "f.go", Line: 3, Content: "staged", DiffType: "staged", Anchor: NewAnchor(before, 3, 0)})
+	hunk := add(&Comment{File: "f.go", Line: 3, LineEnd: 3, Content: "hunk", Scope: ScopeHunk, Hunk: "@@ -3 +3 @@", Anchor: NewAnchor(before, 3, 3)})
+	if err := s.AddReply(moved.ID, &Comment{Content: "reply"}); err != nil {
+		t.Fatal(err)
+	}
+
+	held := s.GetAllComments()
+
+	err := s.Reanchor(func(diffType string) (*Source, error) {
+		if diffType == "staged" {
+			// Failing sources leave their comments alone
+			return nil, errors.New("no index")
+		}
+		return &Source{
+			Renames: map[string]string{"old.go": "new.go"},
+			ReadFile: func(path, side string) ([]string, error) {
+				return []string{"new", "a", "b", "target()", "c", "d"}, nil
+			},
+		}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(id string, file string, line int, outdated bool) *Comment {
+		t.Helper()
+		c := s.GetComment(id)
+		if c.File != file || c.Line != line || c.Outdated != outdated {
+			t.Errorf("%s is at %s:%d outdated %v, want %s:%d outdated %v", c.Content, c.File, c.Line, c.Outdated, file, line, outdated)
+		}
+		return c
+	}
+	check(moved.ID, "f.go", 4, false)
+	check(gone.ID, "f.go", 1, true)
+	check(renamed.ID, "new.go", 0, false)
+	check(staged.ID, "f.go", 3, false)
+	if c := check(hunk.ID, "f.go", 4, false); c.Hunk != "" || c.LineEnd != 4 {
+		t.Errorf("moved hunk comment kept header %q and ends at %d", c.Hunk, c.LineEnd)
+	}
+
+	threads := s.GetThread(moved.ID)
+	if len(threads.Replies) != 1 || threads.Replies[0].Line != 4 {
+		t.Errorf("reply didn't follow its comment: %+v", threads.Replies)
+	}
+
+	// Comments handed out before are copies and stay as they were
+	for _, c := range held {
+		if c.ID == moved.ID && c.Line != 3 {
+			t.Errorf("comment returned before re-anchoring changed to line %d", c.Line)
+		}
+	}
+}
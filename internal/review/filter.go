@@ -0,0 +1,74 @@
+package review
+
+import (
+	"slices"
+	"sort"
+)
+
+// Filter selects comments by status, severity and label. Each list that
+// isn't empty must contain the comment's value, or one of its labels.
+type Filter struct {
+	Statuses   []Status
+	Severities []Severity
+	Labels     []string
+}
+
+// ExportFilter selects what exports include unless asked otherwise,
+// everything but resolved threads
+func ExportFilter() Filter {
+	return Filter{Statuses: []Status{StatusOpen, StatusWontFix, StatusAddressed}}
+}
+
+// Match reports whether a comment passes the filter
+func (f Filter) Match(c *Comment) bool {
+	if len(f.Statuses) > 0 && !slices.Contains(f.Statuses, c.Status) {
+		return false
+	}
+	if len(f.Severities) > 0 && !slices.Contains(f.Severities, c.Severity) {
+		return false
+	}
+	if len(f.Labels) > 0 && !slices.ContainsFunc(c.Labels, func(label string) bool {
+		return slices.Contains(f.Labels, label)
+	}) {
+		return false
+	}
+	return true
+}
+
+// FilterComments keeps the comments that pass the filter
+func FilterComments(comments []*Comment, f Filter) []*Comment {
+	filtered := []*Comment{}
+	for _, c := range comments {
+		if f.Match(c) {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
+
+// FilterThreads keeps the threads whose first comment passes the filter
+func FilterThreads(threads []*Thread, f Filter) []*Thread {
+	filtered := []*Thread{}
+	for _, t := range threads {
+		if f.Match(t.Comment) {
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered
+}
+
+// SortBySeverity orders comments blockers first, keeping the existing order
+// among comments of the same severity
+func SortBySeverity(comments []*Comment) {
+	sort.SliceStable(comments, func(i, j int) bool {
+		return comments[i].Severity.rank() < comments[j].Severity.rank()
+	})
+}
+
+// SortThreadsBySeverity orders threads blockers first, keeping the existing
+// order among threads of the same severity
+func SortThreadsBySeverity(threads []*Thread) {
+	sort.SliceStable(threads, func(i, j int) bool {
+		return threads[i].Severity.rank() < threads[j].Severity.rank()
+	})
+}
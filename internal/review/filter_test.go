@@ -0,0 +1,118 @@
+package review
+
+import (
+	"reflect"
+	"testing"
+)
+
+// filterStore holds one thread for each status, with a reply each
+func filterStore(t *testing.T) *Store {
+	t.Helper()
+	s := NewStore()
+	for i, status := range statuses {
+		c := &Comment{File: "a.go", Line: i + 1, Content: string(status)}
+		if err := s.AddComment(c); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddReply(c.ID, &Comment{Content: string(status) + " reply"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.SetStatus(c.ID, status); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestFilter(t *testing.T) {
+	s := filterStore(t)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		// want are the comments that pass, threads those of them that aren't replies
+		want        []string
+		wantThreads []string
+	}{
+		{
+			name:   "everything",
+			filter: Filter{},
+			want: []string{
+				"open", "open reply", "resolved", "resolved reply",
+				"wont-fix", "wont-fix reply", "addressed-by-agent", "addressed-by-agent reply",
+			},
+			wantThreads: []string{"open", "resolved", "wont-fix", "addressed-by-agent"},
+		},
+		{
+			name:        "one status",
+			filter:      Filter{Statuses: []Status{StatusAddressed}},
+			want:        []string{"addressed-by-agent", "addressed-by-agent reply"},
+			wantThreads: []string{"addressed-by-agent"},
+		},
+		{
+			name:        "exports skip resolved threads",
+			filter:      ExportFilter(),
+			want:        []string{"open", "open reply", "wont-fix", "wont-fix reply", "addressed-by-agent", "addressed-by-agent reply"},
+			wantThreads: []string{"open", "wont-fix", "addressed-by-agent"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contents(FilterComments(s.GetAllComments(), tt.filter)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("comments\n%q\nwant\n%q", got, tt.want)
+			}
+			var threads []string
+			for _, thread := range FilterThreads(s.GetThreads(), tt.filter) {
+				threads = append(threads, thread.Content)
+			}
+			if !reflect.DeepEqual(threads, tt.wantThreads) {
+				t.Errorf("threads\n%q\nwant\n%q", threads, tt.wantThreads)
+			}
+		})
+	}
+}
+
+func TestFilterBySeverityAndLabel(t *testing.T) {
+	comments := []*Comment{
+		{Content: "blocker", Status: StatusOpen, Severity: SeverityBlocker, Labels: []string{"security"}},
+		{Content: "bug", Status: StatusOpen, Severity: SeverityBug, Labels: []string{"perf", "security"}},
+		{Content: "resolved bug", Status: StatusResolved, Severity: SeverityBug},
+		{Content: "plain", Status: StatusOpen},
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{
+			name:   "severities",
+			filter: Filter{Severities: []Severity{SeverityBlocker, SeverityBug}},
+			want:   []string{"blocker", "bug", "resolved bug"},
+		},
+		{
+			name:   "any of the labels",
+			filter: Filter{Labels: []string{"perf", "style"}},
+			want:   []string{"bug"},
+		},
+		{
+			name:   "every list must match",
+			filter: Filter{Statuses: []Status{StatusOpen}, Severities: []Severity{SeverityBug}, Labels: []string{"security"}},
+			want:   []string{"bug"},
+		},
+		{
+			name:   "nothing",
+			filter: Filter{Severities: []Severity{SeverityNit}},
+			want:   []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contents(FilterComments(comments, tt.filter)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("comments\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
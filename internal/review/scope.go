@@ -0,0 +1,94 @@
+package review
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Scope is what a comment is about
+type Scope string
+
+const (
+	// ScopeReview comments are about the change as a whole
+	ScopeReview Scope = "review"
+	// ScopeFile comments are about a file
+	ScopeFile Scope = "file"
+	// ScopeHunk comments are about a hunk, which they keep as their line range
+	ScopeHunk Scope = "hunk"
+	// ScopeLine comments are about a line or range of lines
+	ScopeLine Scope = "line"
+)
+
+// ErrInvalidScope is returned for comments whose scope doesn't fit their location
+var ErrInvalidScope = errors.New("invalid comment scope")
+
+// normalizeScope infers the scope of comments that don't name one, so
+// clients that only send a file and line keep working, and checks that the
+// location fits the scope. Deleted lines given as negative numbers are
+// moved to the old side first.
+func (c *Comment) normalizeScope() error {
+	if !c.normalizeSide() {
+		return fmt.Errorf("%w: a line range can't start and end on different sides", ErrInvalidScope)
+	}
+	if c.Scope == "" {
+		switch {
+		case c.HunkID != "":
+			c.Scope = ScopeHunk
+		case c.File == "":
+			c.Scope = ScopeReview
+		case c.Line == 0:
+			c.Scope = ScopeFile
+		default:
+			c.Scope = ScopeLine
+		}
+	}
+
+	switch c.Scope {
+	case ScopeReview:
+		if c.File != "" || c.Line != 0 {
+			return fmt.Errorf("%w: review comments have no file or line", ErrInvalidScope)
+		}
+	case ScopeFile:
+		if c.File == "" || c.Line != 0 {
+			return fmt.Errorf("%w: file comments need a file and no line", ErrInvalidScope)
+		}
+	case ScopeHunk, ScopeLine:
+		if c.File == "" || c.Line <= 0 || (c.LineEnd != 0 && c.LineEnd < c.Line) {
+			return fmt.Errorf("%w: %s comments need a file and line range", ErrInvalidScope, c.Scope)
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidScope, c.Scope)
+	}
+	return nil
+}
+
+// migrateScope makes comments saved by older versions fit their scope,
+// turning ones whose lines can't be represented into outdated comments on
+// their file, so a review never fails to load over a single comment
+func (c *Comment) migrateScope() {
+	if c.normalizeScope() == nil {
+		return
+	}
+	c.Scope, c.Hunk, c.HunkID, c.Line, c.LineEnd, c.Anchor, c.Outdated = "", "", "", 0, 0, nil, true
+	// Without lines this can't fail, it's a file or review comment now
+	_ = c.normalizeScope()
+}
+
+// Location describes where a comment is, the way text mode prints it
+func (c *Comment) Location() string {
+	switch c.Scope {
+	case ScopeReview:
+		return "(review)"
+	case ScopeFile:
+		return c.File
+	case ScopeHunk:
+		if c.Hunk != "" {
+			return fmt.Sprintf("%s %s", c.File, c.Hunk)
+		}
+	}
+
+	if c.LineEnd != 0 && c.LineEnd != c.Line {
+		return fmt.Sprintf("%s:%d-%d", c.File, c.Line, c.LineEnd)
+	}
+	return fmt.Sprintf("%s:%d", c.File, c.Line)
+}
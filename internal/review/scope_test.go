@@ -0,0 +1,114 @@
+package review
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment Comment
+		want    Comment
+		err     error
+	}{
+		{
+			name:    "review",
+			comment: Comment{},
+			want:    Comment{Scope: ScopeReview},
+		},
+		{
+			name:    "file",
+			comment: Comment{File: "f"},
+			want:    Comment{File: "f", Scope: ScopeFile},
+		},
+		{
+			name:    "line",
+			comment: Comment{File: "f", Line: 3, LineEnd: 4},
+			want:    Comment{File: "f", Line: 3, LineEnd: 4, Scope: ScopeLine},
+		},
+		{
+			name:    "hunk",
+			comment: Comment{File: "f", Line: 3, LineEnd: 9, HunkID: "f:0"},
+			want:    Comment{File: "f", Line: 3, LineEnd: 9, HunkID: "f:0", Scope: ScopeHunk},
+		},
+		{
+			name:    "deleted lines",
+			comment: Comment{File: "f", Line: -3, LineEnd: -4},
+			want:    Comment{File: "f", Line: 3, LineEnd: 4, Side: "old", Scope: ScopeLine},
+		},
+		{
+			name:    "range across sides",
+			comment: Comment{File: "f", Line: -3, LineEnd: 4},
+			err:     ErrInvalidScope,
+		},
+		{
+			name:    "file comment with a line",
+			comment: Comment{File: "f", Line: 3, Scope: ScopeFile},
+			err:     ErrInvalidScope,
+		},
+		{
+			name:    "line comment without a file",
+			comment: Comment{Line: 3, Scope: ScopeLine},
+			err:     ErrInvalidScope,
+		},
+		{
+			name:    "range ending before it starts",
+			comment: Comment{File: "f", Line: 4, LineEnd: 3},
+			err:     ErrInvalidScope,
+		},
+		{
+			name:    "unknown scope",
+			comment: Comment{File: "f", Scope: "function"},
+			err:     ErrInvalidScope,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.comment
+			err := c.normalizeScope()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+			if err == nil && !reflect.DeepEqual(c, tt.want) {
+				t.Errorf("normalized to %+v, want %+v", c, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrateScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment Comment
+		want    Comment
+	}{
+		{
+			name:    "valid",
+			comment: Comment{File: "f", Line: -2},
+			want:    Comment{File: "f", Line: 2, Side: "old", Scope: ScopeLine},
+		},
+		{
+			name:    "range across sides",
+			comment: Comment{File: "f", Line: -2, LineEnd: 5, Anchor: &Anchor{Lines: []string{"x"}}},
+			want:    Comment{File: "f", Scope: ScopeFile, Outdated: true},
+		},
+		{
+			name:    "hunk without a file",
+			comment: Comment{Line: 1, LineEnd: 9, Scope: ScopeHunk, Hunk: "@@ -1,9 +1,9 @@"},
+			want:    Comment{Scope: ScopeReview, Outdated: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.comment
+			c.migrateScope()
+			if !reflect.DeepEqual(c, tt.want) {
+				t.Errorf("migrated to %+v, want %+v", c, tt.want)
+			}
+		})
+	}
+}
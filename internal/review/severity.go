@@ -0,0 +1,79 @@
+package review
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity says how much a comment matters
+type Severity string
+
+const (
+	SeverityBlocker    Severity = "blocker"
+	SeverityBug        Severity = "bug"
+	SeveritySuggestion Severity = "suggestion"
+	SeverityNit        Severity = "nit"
+	SeverityQuestion   Severity = "question"
+)
+
+// severities lists every severity, most important first
+var severities = []Severity{SeverityBlocker, SeverityBug, SeveritySuggestion, SeverityNit, SeverityQuestion}
+
+// ParseSeverities parses a comma separated list of severities, "" gives nil
+func ParseSeverities(value string) ([]Severity, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var parsed []Severity
+	for _, name := range strings.Split(value, ",") {
+		severity := Severity(strings.TrimSpace(name))
+		if severity == "" || !severity.valid() {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSeverity, name)
+		}
+		parsed = append(parsed, severity)
+	}
+	return parsed, nil
+}
+
+// valid accepts the known severities and "" for comments without one
+func (sv Severity) valid() bool {
+	return sv == "" || sv.rank() < len(severities)
+}
+
+// rank orders severities from blockers down, comments without one go last
+func (sv Severity) rank() int {
+	for i, s := range severities {
+		if sv == s {
+			return i
+		}
+	}
+	return len(severities)
+}
+
+// ParseLabels parses a comma separated list of labels the way labels are
+// stored, so " security" matches "security". Blank lists give nil.
+func ParseLabels(value string) []string {
+	labels := normalizeLabels(strings.Split(value, ","))
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// normalizeLabels trims labels and drops empty and repeated ones
+func normalizeLabels(labels []string) []string {
+	if labels == nil {
+		return nil
+	}
+	normalized := []string{}
+	seen := make(map[string]bool)
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if label != "" && !seen[label] {
+			seen[label] = true
+			normalized = append(normalized, label)
+		}
+	}
+	return normalized
+}
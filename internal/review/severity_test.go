@@ -0,0 +1,107 @@
+package review
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseSeverities(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []Severity
+		wantErr bool
+	}{
+		{value: "", want: nil},
+		{value: "blocker", want: []Severity{SeverityBlocker}},
+		{value: "bug, nit,question", want: []Severity{SeverityBug, SeverityNit, SeverityQuestion}},
+		{value: "bug,urgent", wantErr: true},
+		{value: "bug,", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseSeverities(tt.value)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSeverity) {
+					t.Errorf("returned %v, want %v", err, ErrInvalidSeverity)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsed %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{value: "", want: nil},
+		{value: " , ", want: nil},
+		{value: "security", want: []string{"security"}},
+		{value: " security,perf,security, ", want: []string{"security", "perf"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := ParseLabels(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsed %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddCommentLabels(t *testing.T) {
+	s := NewStore()
+	c := &Comment{Content: "c", Severity: SeverityNit, Labels: []string{" style", "", "style", "naming"}}
+	if err := s.AddComment(c); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.GetComment(c.ID).Labels, []string{"style", "naming"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("labels %q, want %q", got, want)
+	}
+	if err := s.AddComment(&Comment{Content: "c", Severity: "urgent"}); !errors.Is(err, ErrInvalidSeverity) {
+		t.Errorf("adding an unknown severity returned %v", err)
+	}
+}
+
+func TestSortBySeverity(t *testing.T) {
+	s := NewStore()
+	for _, c := range []*Comment{
+		{File: "a.go", Content: "none"},
+		{File: "b.go", Content: "nit", Severity: SeverityNit},
+		{File: "c.go", Content: "first blocker", Severity: SeverityBlocker},
+		{File: "d.go", Content: "question", Severity: SeverityQuestion},
+		{File: "e.go", Content: "bug", Severity: SeverityBug},
+		{File: "f.go", Content: "second blocker", Severity: SeverityBlocker},
+		{File: "g.go", Content: "suggestion", Severity: SeveritySuggestion},
+	} {
+		if err := s.AddComment(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"first blocker", "second blocker", "bug", "suggestion", "nit", "question", "none"}
+
+	comments := s.GetAllComments()
+	SortBySeverity(comments)
+	if got := contents(comments); !reflect.DeepEqual(got, want) {
+		t.Errorf("comments\n%q\nwant\n%q", got, want)
+	}
+
+	threads := s.GetThreads()
+	SortThreadsBySeverity(threads)
+	var got []string
+	for _, thread := range threads {
+		got = append(got, thread.Content)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("threads\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,83 @@
+package review
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// Status tracks whether a comment still needs attention
+type Status string
+
+const (
+	StatusOpen     Status = "open"
+	StatusResolved Status = "resolved"
+	StatusWontFix  Status = "wont-fix"
+	// StatusAddressed marks comments an agent says it fixed, waiting for
+	// a human to verify and resolve them
+	StatusAddressed Status = "addressed-by-agent"
+)
+
+var statuses = []Status{StatusOpen, StatusResolved, StatusWontFix, StatusAddressed}
+
+// ErrInvalidStatus is returned for statuses other than the ones above
+var ErrInvalidStatus = errors.New("invalid status")
+
+// ParseStatuses parses a comma separated list of statuses, "" gives nil
+func ParseStatuses(value string) ([]Status, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var parsed []Status
+	for _, name := range strings.Split(value, ",") {
+		status := Status(strings.TrimSpace(name))
+		if !status.valid() {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidStatus, name)
+		}
+		parsed = append(parsed, status)
+	}
+	return parsed, nil
+}
+
+func (st Status) valid() bool {
+	for _, s := range statuses {
+		if st == s {
+			return true
+		}
+	}
+	return false
+}
+
+// SetStatus changes the status of a comment's thread. Replies share the
+// status of the comment they answer, so setting it on a reply sets it on
+// the whole thread.
+func (s *Store) SetStatus(id string, status Status) (*Comment, error) {
+	if !status.valid() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidStatus, status)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.comments[id]
+	if !ok {
+		return nil, ErrCommentNotFound
+	}
+	if root, ok := s.comments[c.ParentID]; ok {
+		c = root
+	}
+
+	previous := maps.Clone(s.comments)
+	updated := *c
+	updated.Status = status
+	s.comments[c.ID] = &updated
+	s.syncReplies()
+	if err := s.save(); err != nil {
+		s.comments = previous
+		return nil, err
+	}
+	copied := updated
+	return &copied, nil
+}
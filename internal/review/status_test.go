@@ -0,0 +1,95 @@
+package review
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseStatuses(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []Status
+		wantErr bool
+	}{
+		{value: "", want: nil},
+		{value: "open", want: []Status{StatusOpen}},
+		{value: "resolved, wont-fix,addressed-by-agent", want: []Status{StatusResolved, StatusWontFix, StatusAddressed}},
+		{value: "open,done", wantErr: true},
+		{value: "open,", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseStatuses(tt.value)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidStatus) {
+					t.Errorf("returned %v, want %v", err, ErrInvalidStatus)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsed %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		// onReply sets the status on the reply instead of the comment
+		onReply bool
+		status  Status
+		wantErr error
+	}{
+		{name: "resolved", status: StatusResolved},
+		{name: "through a reply", onReply: true, status: StatusAddressed},
+		{name: "unknown", status: "done", wantErr: ErrInvalidStatus},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStore()
+			root := &Comment{File: "a.go", Line: 3, Content: "c"}
+			if err := s.AddComment(root); err != nil {
+				t.Fatal(err)
+			}
+			reply := &Comment{Content: "r"}
+			if err := s.AddReply(root.ID, reply); err != nil {
+				t.Fatal(err)
+			}
+			id := root.ID
+			if tt.onReply {
+				id = reply.ID
+			}
+
+			updated, err := s.SetStatus(id, tt.status)
+			want := tt.status
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("returned %v, want %v", err, tt.wantErr)
+				}
+				want = StatusOpen
+			} else if err != nil {
+				t.Fatal(err)
+			} else if updated.ID != root.ID || updated.Status != want {
+				t.Errorf("returned %s with status %s, want %s with %s", updated.ID, updated.Status, root.ID, want)
+			}
+
+			// The thread shares the status
+			for _, c := range []*Comment{root, reply} {
+				if got := s.GetComment(c.ID).Status; got != want {
+					t.Errorf("%s has status %s, want %s", c.Content, got, want)
+				}
+			}
+		})
+	}
+
+	if _, err := NewStore().SetStatus("missing", StatusResolved); !errors.Is(err, ErrCommentNotFound) {
+		t.Errorf("setting the status of a missing comment returned %v", err)
+	}
+}
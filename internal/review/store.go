@@ -3,38 +3,468 @@ package review
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
 type Comment struct {
-	ID        string    `json:"id"`
-	File      string    `json:"file"`
-	Line      int       `json:"line,omitempty"`
-	LineEnd   int       `json:"lineEnd,omitempty"`
-	Side      string    `json:"side,omitempty"`
+	ID      string `json:"id"`
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	LineEnd int    `json:"lineEnd,omitempty"`
+	Side    string `json:"side,omitempty"`
+	// LineID and LineEndID can be sent instead of line numbers, they are
+	// resolved against the current diff and kept for reference
+	LineID    string    `json:"lineId,omitempty"`
+	LineEndID string    `json:"lineEndId,omitempty"`
 	Content   string    `json:"content"`
+	Status    Status    `json:"status"`
+	Severity  Severity  `json:"severity,omitempty"`
+	Labels    []string  `json:"labels,omitempty"`
 	CreatedAt time.Time `json:"createdAt"`
+	// UpdatedAt is set once the comment has been edited
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	// History holds earlier versions of an edited comment, oldest first
+	History []Revision `json:"history,omitempty"`
+	// Anchor is the commented code, used to move the comment along with it
+	Anchor *Anchor `json:"anchor,omitempty"`
+	// Outdated is set once the commented code can't be found anymore
+	Outdated bool `json:"outdated,omitempty"`
+	// ParentID is the comment a reply answers, replies share its location
+	ParentID string `json:"parentId,omitempty"`
+	// Suggestion replaces lines Line to LineEnd when applied, "" deletes them
+	Suggestion *string `json:"suggestion,omitempty"`
+	// Scope says whether the comment is about the whole review, File, a
+	// hunk or lines. Review comments have no File.
+	Scope Scope `json:"scope"`
+	// HunkID can be sent instead of a file and lines for hunk comments,
+	// Hunk keeps the header of the hunk it was resolved to
+	HunkID string `json:"hunkId,omitempty"`
+	Hunk   string `json:"hunk,omitempty"`
+	// DiffType is the diff the comment was made in, Side and the line
+	// numbers refer to its sides. "" is the diff of all changes.
+	DiffType string `json:"diffType,omitempty"`
 }
 
+// Revision is a comment as it was before an edit
+type Revision struct {
+	Content  string   `json:"content"`
+	Side     string   `json:"side,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	LineEnd  int      `json:"lineEnd,omitempty"`
+	Severity Severity `json:"severity,omitempty"`
+	Labels   []string `json:"labels,omitempty"`
+	// Suggestion is kept as it was, nil when there was none
+	Suggestion *string `json:"suggestion,omitempty"`
+	// EditedAt is when this version was replaced
+	EditedAt time.Time `json:"editedAt"`
+}
+
+// CommentEdit changes a comment, nil fields are left as they are. A
+// negative Line moves the comment to deleted lines, given by minus their old
+// line number.
+type CommentEdit struct {
+	Content *string `json:"content"`
+	Line    *int    `json:"line"`
+	LineEnd *int    `json:"lineEnd"`
+	// LineID and LineEndID can be sent instead of Line and LineEnd, the
+	// handler resolves them against the diff
+	LineID    string    `json:"lineId"`
+	LineEndID string    `json:"lineEndId"`
+	Severity  *Severity `json:"severity"`
+	Labels    *[]string `json:"labels"`
+	// Suggestion replaces the suggested text, an empty one suggests
+	// deleting the lines
+	Suggestion *string `json:"suggestion"`
+	// ClearSuggestion drops the suggestion, leaving a plain comment
+	ClearSuggestion bool `json:"clearSuggestion"`
+	// Anchor replaces the anchor when the range changes, it isn't sent by clients
+	Anchor *Anchor `json:"-"`
+}
+
+var (
+	// ErrCommentNotFound is returned for edits of comments that don't exist
+	ErrCommentNotFound = errors.New("comment not found")
+	// ErrInvalidEdit is returned for edits that leave a comment without a valid range
+	ErrInvalidEdit = errors.New("invalid comment range")
+	// ErrInvalidSeverity is returned for severities other than the known ones
+	ErrInvalidSeverity = errors.New("invalid severity")
+)
+
 type Store struct {
-	mu       sync.RWMutex
+	mu sync.RWMutex
+	// comments are never changed in place, updates replace them with an
+	// edited copy so comments handed out stay as they were
 	comments map[string]*Comment
+	// path is the file every change is saved to, "" keeps comments in memory only
+	path string
+}
+
+// storeFile is the on-disk format of a saved review
+type storeFile struct {
+	Version  int        `json:"version"`
+	Comments []*Comment `json:"comments"`
 }
 
+const storeVersion = 1
+
 func NewStore() *Store {
 	return &Store{
 		comments: make(map[string]*Comment),
 	}
 }
 
-func (s *Store) AddComment(comment *Comment) {
+// Open replaces the comments with the review saved at path, if any, and
+// saves every later change there. On error the store is left unchanged.
+func (s *Store) Open(path string) error {
+	comments := make(map[string]*Comment)
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		var saved storeFile
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return fmt.Errorf("failed to read review %s: %w", path, err)
+		}
+		for _, c := range saved.Comments {
+			// Reviews saved before statuses and scopes existed only had
+			// open comments on lines or files
+			if c.Status == "" {
+				c.Status = StatusOpen
+			}
+			c.migrateScope()
+			comments[c.ID] = c
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.comments = comments
+	s.path = path
+	return nil
+}
+
+// Archive sets the saved review aside and starts an empty one, for when a
+// review is finished and its comments shouldn't come back. The file is kept
+// next to the live reviews, the returned path is "" when nothing was saved.
+func (s *Store) Archive() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	archived := ""
+	if s.path != "" {
+		archived = strings.TrimSuffix(s.path, ".json") + "." + time.Now().Format("20060102-150405") + ".archived.json"
+		err := os.Rename(s.path, archived)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			archived = ""
+		case err != nil:
+			return "", err
+		}
+	}
+
+	s.comments = make(map[string]*Comment)
+	return archived, nil
+}
+
+// Path returns the file the review is saved to, "" when kept in memory
+func (s *Store) Path() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.path
+}
+
+// save writes all comments to s.path, replacing the file atomically so a
+// crash never leaves a partial review behind. Callers hold s.mu.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	saved := storeFile{
+		Version:  storeVersion,
+		Comments: make([]*Comment, 0, len(s.comments)),
+	}
+	for _, c := range s.comments {
+		saved.Comments = append(saved.Comments, c)
+	}
+	sortByCreation(saved.Comments)
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// AddComment stores a new comment, or nothing if it can't be saved
+func (s *Store) AddComment(comment *Comment) error {
+	if !comment.Severity.valid() {
+		return fmt.Errorf("%w: %q", ErrInvalidSeverity, comment.Severity)
+	}
+	if err := comment.normalizeScope(); err != nil {
+		return err
+	}
+	comment.Labels = normalizeLabels(comment.Labels)
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	comment.ID = generateID()
 	comment.CreatedAt = time.Now()
+	comment.Status = StatusOpen
 	s.comments[comment.ID] = comment
+	if err := s.save(); err != nil {
+		delete(s.comments, comment.ID)
+		return err
+	}
+	return nil
+}
+
+// Source is the code comments of one diff type are anchored in
+type Source struct {
+	// Renames maps old paths of renamed files to new ones
+	Renames map[string]string
+	// ReadFile returns the current lines of a file on the "old" or "new" side
+	ReadFile func(path, side string) ([]string, error)
+}
+
+// Reanchor moves comments to where their code is now. source returns the
+// code of the diff type comments were made in, comments of diff types it
+// fails for stay where they are. Comments whose code is gone are marked
+// outdated. Files are read without holding the lock, comments edited in the
+// meantime keep their edit.
+func (s *Store) Reanchor(source func(diffType string) (*Source, error)) error {
+	s.mu.RLock()
+	var comments []*Comment
+	for _, c := range s.comments {
+		if c.ParentID == "" && (c.Anchor != nil || c.Scope == ScopeFile) {
+			comments = append(comments, c)
+		}
+	}
+	s.mu.RUnlock()
+
+	type fileKey struct{ diffType, path, side string }
+	sources := make(map[string]*Source)
+	files := make(map[fileKey][]string)
+	// moved maps comments as they were read to their new version
+	moved := make(map[*Comment]*Comment)
+
+	for _, c := range comments {
+		src, ok := sources[c.DiffType]
+		if !ok {
+			src, _ = source(c.DiffType)
+			sources[c.DiffType] = src
+		}
+		if src == nil {
+			continue
+		}
+
+		file := c.File
+		if newPath, ok := src.Renames[file]; ok && c.Side != "old" {
+			file = newPath
+		}
+
+		// File comments have nothing to anchor but follow renames
+		if c.Anchor == nil {
+			if file != c.File {
+				updated := *c
+				updated.File = file
+				moved[c] = &updated
+			}
+			continue
+		}
+
+		key := fileKey{c.DiffType, file, c.Side}
+		content, ok := files[key]
+		if !ok {
+			// A file that can't be read was deleted, which outdates its comments
+			content, _ = src.ReadFile(file, c.Side)
+			files[key] = content
+		}
+
+		updated := *c
+		if line, found := c.Anchor.Locate(content, c.Line); !found {
+			updated.Outdated = true
+		} else {
+			updated.File, updated.Line, updated.Outdated = file, line, false
+			if c.LineEnd != 0 {
+				updated.LineEnd = line + len(c.Anchor.Lines) - 1
+			}
+			// The hunk header's line numbers are out of date once it moved
+			if updated.Line != c.Line && c.Scope == ScopeHunk {
+				updated.Hunk = ""
+			}
+		}
+		if updated.File != c.File || updated.Line != c.Line || updated.LineEnd != c.LineEnd || updated.Outdated != c.Outdated {
+			moved[c] = &updated
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := maps.Clone(s.comments)
+	changed := false
+	for c, updated := range moved {
+		// Comments are replaced rather than changed, so one that is still
+		// the one read above hasn't been edited, deleted or reopened since
+		if s.comments[c.ID] == c {
+			s.comments[c.ID] = updated
+			changed = true
+		}
+	}
+	if s.syncReplies() {
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	if err := s.save(); err != nil {
+		s.comments = previous
+		return err
+	}
+	return nil
+}
+
+// GetComment returns a copy of a comment, or nil if it doesn't exist
+func (s *Store) GetComment(id string) *Comment {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.comments[id]
+	if !ok {
+		return nil
+	}
+	copied := *c
+	return &copied
+}
+
+// UpdateComment applies an edit and keeps the previous version in the
+// comment's history. Edits that change nothing are not recorded.
+func (s *Store) UpdateComment(id string, edit CommentEdit) (*Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.comments[id]
+	if !ok {
+		return nil, ErrCommentNotFound
+	}
+
+	updated := *c
+	if edit.Content != nil {
+		updated.Content = *edit.Content
+	}
+	if edit.Line != nil {
+		updated.Line = *edit.Line
+	}
+	if edit.LineEnd != nil {
+		updated.LineEnd = *edit.LineEnd
+	}
+	if edit.Severity != nil {
+		if !edit.Severity.valid() {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSeverity, *edit.Severity)
+		}
+		updated.Severity = *edit.Severity
+	}
+	if edit.Labels != nil {
+		updated.Labels = normalizeLabels(*edit.Labels)
+	}
+	if edit.Suggestion != nil {
+		if c.ParentID != "" {
+			return nil, fmt.Errorf("%w: replies can't carry suggestions", ErrInvalidEdit)
+		}
+		updated.Suggestion = edit.Suggestion
+	}
+	if edit.ClearSuggestion {
+		if edit.Suggestion != nil {
+			return nil, fmt.Errorf("%w: a suggestion can't be set and cleared at once", ErrInvalidEdit)
+		}
+		updated.Suggestion = nil
+	}
+	if !updated.normalizeSide() || (updated.LineEnd != 0 && updated.LineEnd < updated.Line) {
+		return nil, ErrInvalidEdit
+	}
+
+	rangeChanged := updated.Side != c.Side || updated.Line != c.Line || updated.LineEnd != c.LineEnd
+	if rangeChanged && c.ParentID != "" {
+		return nil, fmt.Errorf("%w: replies move with their thread", ErrInvalidEdit)
+	}
+	if rangeChanged && (c.Scope == ScopeReview || c.Scope == ScopeFile) {
+		return nil, fmt.Errorf("%w: %s comments have no lines", ErrInvalidEdit, c.Scope)
+	}
+	if !rangeChanged && updated.Content == c.Content && updated.Severity == c.Severity &&
+		slices.Equal(updated.Labels, c.Labels) && equalSuggestions(updated.Suggestion, c.Suggestion) {
+		copied := *c
+		return &copied, nil
+	}
+
+	now := time.Now()
+	updated.UpdatedAt = &now
+	updated.History = append(slices.Clip(c.History), Revision{
+		Content:    c.Content,
+		Side:       c.Side,
+		Line:       c.Line,
+		LineEnd:    c.LineEnd,
+		Severity:   c.Severity,
+		Labels:     c.Labels,
+		Suggestion: c.Suggestion,
+		EditedAt:   now,
+	})
+	// The comment was moved on purpose, so it's about the code now there
+	// rather than a hunk
+	if rangeChanged {
+		updated.Anchor = edit.Anchor
+		updated.Outdated = false
+		if updated.Scope == ScopeHunk {
+			updated.Scope, updated.Hunk, updated.HunkID = ScopeLine, "", ""
+		}
+	}
+
+	s.comments[id] = &updated
+	s.syncReplies()
+	if err := s.save(); err != nil {
+		s.comments[id] = c
+		s.syncReplies()
+		return nil, err
+	}
+	copied := updated
+	return &copied, nil
 }
 
 func (s *Store) GetComments(file string) []*Comment {
@@ -44,9 +474,11 @@ func (s *Store) GetComments(file string) []*Comment {
 	var comments []*Comment
 	for _, c := range s.comments {
 		if c.File == file {
-			comments = append(comments, c)
+			copied := *c
+			comments = append(comments, &copied)
 		}
 	}
+	sortByCreation(comments)
 	return comments
 }
 
@@ -56,20 +488,64 @@ func (s *Store) GetAllComments() []*Comment {
 
 	comments := make([]*Comment, 0, len(s.comments))
 	for _, c := range s.comments {
-		comments = append(comments, c)
+		copied := *c
+		comments = append(comments, &copied)
 	}
+	sortByCreation(comments)
 	return comments
 }
 
-func (s *Store) DeleteComment(id string) bool {
+// normalizeSide moves ranges given the way the UI numbers them, deleted
+// lines being minus their old line number, to the old side. It reports
+// false for ranges that start and end on different sides.
+func (c *Comment) normalizeSide() bool {
+	switch {
+	case c.Line < 0 && c.LineEnd <= 0:
+		c.Side, c.Line, c.LineEnd = "old", -c.Line, -c.LineEnd
+	case c.Line < 0 || c.LineEnd < 0:
+		return false
+	}
+	return true
+}
+
+func equalSuggestions(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// sortByCreation orders comments oldest first, so replies follow what they answer
+func sortByCreation(comments []*Comment) {
+	sort.Slice(comments, func(i, j int) bool {
+		return comments[i].CreatedAt.Before(comments[j].CreatedAt)
+	})
+}
+
+// DeleteComment removes a comment along with its replies, reporting
+// whether it existed
+func (s *Store) DeleteComment(id string) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, exists := s.comments[id]; exists {
-		delete(s.comments, id)
-		return true
+	if _, exists := s.comments[id]; !exists {
+		return false, nil
+	}
+
+	deleted := make(map[string]*Comment)
+	for _, c := range s.comments {
+		if c.ID == id || c.ParentID == id {
+			deleted[c.ID] = c
+			delete(s.comments, c.ID)
+		}
+	}
+	if err := s.save(); err != nil {
+		for _, c := range deleted {
+			s.comments[c.ID] = c
+		}
+		return false, err
 	}
-	return false
+	return true, nil
 }
 
 func generateID() string {
@@ -0,0 +1,397 @@
+package review
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// openStore opens a store saving to path
+func openStore(t *testing.T, path string) *Store {
+	t.Helper()
+	s := NewStore()
+	if err := s.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// contents returns the content of each comment, oldest first
+func contents(comments []*Comment) []string {
+	got := []string{}
+	for _, c := range comments {
+		got = append(got, c.Content)
+	}
+	return got
+}
+
+func marshal(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vibediff", "main--HEAD.json")
+	s := openStore(t, path)
+
+	suggestion := "fixed\n"
+	comments := []*Comment{
+		{File: "a.go", Line: 3, Content: "first", Severity: SeverityBug, Labels: []string{"perf"}},
+		{File: "a.go", Line: -2, LineEnd: -3, Content: "on deleted lines", Suggestion: &suggestion},
+		{Content: "about the review"},
+		{File: "b.go", Content: "deleted later"},
+	}
+	for _, c := range comments {
+		if err := s.AddComment(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	content := "first, edited"
+	if _, err := s.UpdateComment(comments[0].ID, CommentEdit{Content: &content}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SetStatus(comments[1].ID, StatusResolved); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DeleteComment(comments[3].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Times lose their monotonic reading when saved, so compare the JSON
+	reopened := openStore(t, path)
+	if got, want := marshal(t, reopened.GetAllComments()), marshal(t, s.GetAllComments()); got != want {
+		t.Errorf("reopened\n%s\nwant\n%s", got, want)
+	}
+	if reopened.Path() != path {
+		t.Errorf("path %q, want %q", reopened.Path(), path)
+	}
+
+	// No temporary files are left next to the review
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("%d files saved, want 1", len(entries))
+	}
+}
+
+func TestStoreOpen(t *testing.T) {
+	tests := []struct {
+		name string
+		// saved is the file's content, "" for no file
+		saved   string
+		want    []*Comment
+		wantErr bool
+	}{
+		{
+			name: "no review yet",
+			want: []*Comment{},
+		},
+		{
+			name: "saved before statuses and scopes",
+			saved: `{"version": 1, "comments": [
+				{"id": "1", "file": "a.go", "line": 3, "content": "line"},
+				{"id": "2", "file": "a.go", "content": "file", "createdAt": "2024-01-01T00:00:00Z"}
+			]}`,
+			want: []*Comment{
+				{ID: "1", File: "a.go", Line: 3, Content: "line", Status: StatusOpen, Scope: ScopeLine},
+				{ID: "2", File: "a.go", Content: "file", Status: StatusOpen, Scope: ScopeFile},
+			},
+		},
+		{
+			name:    "invalid",
+			saved:   `{"comments": [`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "review.json")
+			if tt.saved != "" {
+				if err := os.WriteFile(path, []byte(tt.saved), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			s := NewStore()
+			if err := s.AddComment(&Comment{Content: "before"}); err != nil {
+				t.Fatal(err)
+			}
+			err := s.Open(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("opened an invalid review")
+				}
+				if got := contents(s.GetAllComments()); !reflect.DeepEqual(got, []string{"before"}) || s.Path() != "" {
+					t.Errorf("store changed to %q at %q", got, s.Path())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range tt.want {
+				c.CreatedAt = s.GetComment(c.ID).CreatedAt
+			}
+			if got := s.GetAllComments(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("opened\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoreArchive(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main--HEAD.json")
+	s := openStore(t, path)
+
+	// Nothing is archived before the first comment is saved
+	if archived, err := s.Archive(); err != nil || archived != "" {
+		t.Fatalf("archived %q, %v before saving", archived, err)
+	}
+
+	if err := s.AddComment(&Comment{Content: "done"}); err != nil {
+		t.Fatal(err)
+	}
+	archived, err := s.Archive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(archived) != dir || !strings.HasPrefix(filepath.Base(archived), "main--HEAD.") ||
+		!strings.HasSuffix(archived, ".archived.json") {
+		t.Errorf("archived to %s", archived)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("review still saved: %v", err)
+	}
+	if n := len(s.GetAllComments()); n != 0 {
+		t.Errorf("%d comments left after archiving", n)
+	}
+	if got := contents(openStore(t, archived).GetAllComments()); !reflect.DeepEqual(got, []string{"done"}) {
+		t.Errorf("archive has %q", got)
+	}
+
+	// The next review is saved where the archived one was
+	if err := s.AddComment(&Comment{Content: "next"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(openStore(t, path).GetAllComments()); !reflect.DeepEqual(got, []string{"next"}) {
+		t.Errorf("saved %q after archiving", got)
+	}
+}
+
+func TestStoreSaveFailure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "vibediff")
+	s := openStore(t, filepath.Join(dir, "review.json"))
+	comment := &Comment{File: "a.go", Line: 3, Content: "kept"}
+	if err := s.AddComment(comment); err != nil {
+		t.Fatal(err)
+	}
+	// A file where the directory was makes every save fail
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	before := s.GetAllComments()
+
+	content := "edited"
+	tests := []struct {
+		name   string
+		change func() error
+	}{
+		{"add", func() error { return s.AddComment(&Comment{Content: "new"}) }},
+		{"edit", func() error {
+			_, err := s.UpdateComment(comment.ID, CommentEdit{Content: &content})
+			return err
+		}},
+		{"status", func() error {
+			_, err := s.SetStatus(comment.ID, StatusResolved)
+			return err
+		}},
+		{"reply", func() error { return s.AddReply(comment.ID, &Comment{Content: "reply"}) }},
+		{"delete", func() error {
+			_, err := s.DeleteComment(comment.ID)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.change(); err == nil {
+				t.Fatal("saved under a file")
+			}
+			if got := s.GetAllComments(); !reflect.DeepEqual(got, before) {
+				t.Errorf("store changed to\n%+v\nwant\n%+v", got, before)
+			}
+		})
+	}
+}
+
+func TestUpdateComment(t *testing.T) {
+	str := func(s string) *string { return &s }
+	num := func(n int) *int { return &n }
+	severity := func(s Severity) *Severity { return &s }
+
+	tests := []struct {
+		name    string
+		comment Comment
+		edit    CommentEdit
+		// want is the edited comment's location and content, and revisions
+		// the number of earlier versions kept
+		want      Comment
+		revisions int
+		wantErr   error
+	}{
+		{
+			name:      "content",
+			comment:   Comment{File: "a.go", Line: 3, Content: "old"},
+			edit:      CommentEdit{Content: str("new")},
+			want:      Comment{File: "a.go", Line: 3, Content: "new", Scope: ScopeLine},
+			revisions: 1,
+		},
+		{
+			name:      "range",
+			comment:   Comment{File: "a.go", Line: 3, Content: "c"},
+			edit:      CommentEdit{Line: num(5), LineEnd: num(7)},
+			want:      Comment{File: "a.go", Line: 5, LineEnd: 7, Content: "c", Scope: ScopeLine},
+			revisions: 1,
+		},
+		{
+			name:      "to deleted lines",
+			comment:   Comment{File: "a.go", Line: 3, Content: "c"},
+			edit:      CommentEdit{Line: num(-4)},
+			want:      Comment{File: "a.go", Side: "old", Line: 4, Content: "c", Scope: ScopeLine},
+			revisions: 1,
+		},
+		{
+			name:      "moving a hunk comment makes it a line comment",
+			comment:   Comment{File: "a.go", Line: 1, LineEnd: 9, Scope: ScopeHunk, Hunk: "@@ -1,9 +1,9 @@", Content: "c"},
+			edit:      CommentEdit{Line: num(2)},
+			want:      Comment{File: "a.go", Line: 2, LineEnd: 9, Content: "c", Scope: ScopeLine},
+			revisions: 1,
+		},
+		{
+			name:    "nothing changed",
+			comment: Comment{File: "a.go", Line: 3, Content: "c"},
+			edit:    CommentEdit{Content: str("c"), Line: num(3)},
+			want:    Comment{File: "a.go", Line: 3, Content: "c", Scope: ScopeLine},
+		},
+		{
+			name:    "end before start",
+			comment: Comment{File: "a.go", Line: 3, Content: "c"},
+			edit:    CommentEdit{LineEnd: num(2)},
+			wantErr: ErrInvalidEdit,
+		},
+		{
+			name:    "across sides",
+			comment: Comment{File: "a.go", Line: 3, Content: "c"},
+			edit:    CommentEdit{Line: num(-3), LineEnd: num(5)},
+			wantErr: ErrInvalidEdit,
+		},
+		{
+			name:    "lines of a file comment",
+			comment: Comment{File: "a.go", Content: "c"},
+			edit:    CommentEdit{Line: num(3)},
+			wantErr: ErrInvalidEdit,
+		},
+		{
+			name:    "unknown severity",
+			comment: Comment{File: "a.go", Line: 3, Content: "c"},
+			edit:    CommentEdit{Severity: severity("urgent")},
+			wantErr: ErrInvalidSeverity,
+		},
+		{
+			name:    "suggestion set and cleared",
+			comment: Comment{File: "a.go", Line: 3, Content: "c"},
+			edit:    CommentEdit{Suggestion: str("x\n"), ClearSuggestion: true},
+			wantErr: ErrInvalidEdit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStore()
+			original := tt.comment
+			if err := s.AddComment(&original); err != nil {
+				t.Fatal(err)
+			}
+			before := s.GetComment(original.ID)
+
+			updated, err := s.UpdateComment(original.ID, tt.edit)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("returned %v, want %v", err, tt.wantErr)
+				}
+				if got := s.GetComment(original.ID); !reflect.DeepEqual(got, before) {
+					t.Errorf("comment changed to %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := Comment{File: updated.File, Side: updated.Side, Line: updated.Line, LineEnd: updated.LineEnd,
+				Content: updated.Content, Scope: updated.Scope, Hunk: updated.Hunk}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("edited to %+v, want %+v", got, tt.want)
+			}
+			if len(updated.History) != tt.revisions || (updated.UpdatedAt != nil) != (tt.revisions > 0) {
+				t.Fatalf("%d revisions, updated at %v, want %d", len(updated.History), updated.UpdatedAt, tt.revisions)
+			}
+			if tt.revisions > 0 {
+				revision := updated.History[0]
+				if revision.Content != before.Content || revision.Line != before.Line || revision.LineEnd != before.LineEnd ||
+					!revision.EditedAt.Equal(*updated.UpdatedAt) {
+					t.Errorf("revision %+v doesn't keep %+v", revision, before)
+				}
+			}
+			if !reflect.DeepEqual(s.GetComment(original.ID), updated) {
+				t.Errorf("stored %+v, returned %+v", s.GetComment(original.ID), updated)
+			}
+		})
+	}
+}
+
+func TestUpdateCommentKeepsEveryRevision(t *testing.T) {
+	s := NewStore()
+	comment := &Comment{File: "a.go", Line: 3, Content: "v1"}
+	if err := s.AddComment(comment); err != nil {
+		t.Fatal(err)
+	}
+	reported := s.GetComment(comment.ID)
+
+	for _, content := range []string{"v2", "v3"} {
+		if _, err := s.UpdateComment(comment.ID, CommentEdit{Content: &content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	updated := s.GetComment(comment.ID)
+	var history []string
+	for _, revision := range updated.History {
+		history = append(history, revision.Content)
+	}
+	if updated.Content != "v3" || !reflect.DeepEqual(history, []string{"v1", "v2"}) {
+		t.Errorf("content %q with history %q, want v3 with [v1 v2]", updated.Content, history)
+	}
+	// Copies handed out earlier stay as they were
+	if reported.Content != "v1" || reported.History != nil {
+		t.Errorf("earlier copy changed to %+v", reported)
+	}
+
+	if _, err := s.UpdateComment("missing", CommentEdit{}); !errors.Is(err, ErrCommentNotFound) {
+		t.Errorf("editing a missing comment returned %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package review
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SuggestionLines splits the replacement text of a suggestion into lines,
+// an empty suggestion deletes the commented lines
+func SuggestionLines(suggestion string) []string {
+	if suggestion == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(suggestion, "\n"), "\n")
+}
+
+// CanApply reports whether a comment's suggestion can still be applied to
+// the commented lines of the new side
+func (c *Comment) CanApply() bool {
+	return c.Suggestion != nil && c.Anchor != nil && !c.Outdated && c.Side != "old" && c.Line > 0
+}
+
+// SuggestionPatch returns the comment's suggestion as a unified diff that
+// "git apply" accepts, with the commented lines and their context as they
+// were when the comment was made. It returns "" when there is nothing that
+// can be applied or the comment is resolved.
+func (c *Comment) SuggestionPatch() string {
+	if !c.CanApply() || c.Status == StatusResolved {
+		return ""
+	}
+
+	a := c.Anchor
+	replacement := SuggestionLines(*c.Suggestion)
+
+	oldStart := c.Line - len(a.Before)
+	oldCount := len(a.Before) + len(a.Lines) + len(a.After)
+	newStart := oldStart
+	newCount := len(a.Before) + len(replacement) + len(a.After)
+	// A range with no lines starts at the line before it
+	if newCount == 0 {
+		newStart--
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\n", c.File, c.File)
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", c.File, c.File)
+	fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+	for _, line := range a.Before {
+		b.WriteString(" " + line + "\n")
+	}
+	for _, line := range a.Lines {
+		b.WriteString("-" + line + "\n")
+	}
+	for _, line := range replacement {
+		b.WriteString("+" + line + "\n")
+	}
+	for _, line := range a.After {
+		b.WriteString(" " + line + "\n")
+	}
+	return b.String()
+}
+
+// hunkRange formats one side of a hunk header, leaving out a count of one
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,100 @@
+package review
+
+import "testing"
+
+func TestSuggestionPatch(t *testing.T) {
+	content := []string{"1", "2", "3", "4", "5", "6", "7", "8"}
+	suggest := func(s string) *string { return &s }
+
+	tests := []struct {
+		name    string
+		comment Comment
+		want    string
+	}{
+		{
+			name:    "replaced line",
+			comment: Comment{File: "f", Line: 5, Suggestion: suggest("five"), Anchor: NewAnchor(content, 5, 0)},
+			want: `diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+`,
+		},
+		{
+			name:    "range replaced by more lines",
+			comment: Comment{File: "f", Line: 1, LineEnd: 2, Suggestion: suggest("one\none and a half\ntwo\n"), Anchor: NewAnchor(content, 1, 2)},
+			want: `diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -1,5 +1,6 @@
+-1
+-2
++one
++one and a half
++two
+ 3
+ 4
+ 5
+`,
+		},
+		{
+			name:    "lines deleted",
+			comment: Comment{File: "f", Line: 8, Suggestion: suggest(""), Anchor: NewAnchor(content, 8, 0)},
+			want: `diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -5,4 +5,3 @@
+ 5
+ 6
+ 7
+-8
+`,
+		},
+		{
+			name:    "whole file deleted",
+			comment: Comment{File: "f", Line: 1, Suggestion: suggest(""), Anchor: NewAnchor([]string{"only"}, 1, 0)},
+			want: `diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -1 +0,0 @@
+-only
+`,
+		},
+		{
+			name:    "no suggestion",
+			comment: Comment{File: "f", Line: 5, Anchor: NewAnchor(content, 5, 0)},
+		},
+		{
+			name:    "old side",
+			comment: Comment{File: "f", Line: 5, Side: "old", Suggestion: suggest("five"), Anchor: NewAnchor(content, 5, 0)},
+		},
+		{
+			name:    "outdated",
+			comment: Comment{File: "f", Line: 5, Outdated: true, Suggestion: suggest("five"), Anchor: NewAnchor(content, 5, 0)},
+		},
+		{
+			name:    "resolved",
+			comment: Comment{File: "f", Line: 5, Status: StatusResolved, Suggestion: suggest("five"), Anchor: NewAnchor(content, 5, 0)},
+		},
+		{
+			name:    "file comment",
+			comment: Comment{File: "f", Suggestion: suggest("five")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.comment.SuggestionPatch(); got != tt.want {
+				t.Errorf("patch\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package review
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteText writes a comment the way text mode reports it on stdout, tagged
+// with its severity and labels and marked when it isn't a plain open comment
+func WriteText(w io.Writer, c *Comment) {
+	location := c.Location()
+	var tags []string
+	if c.Severity != "" {
+		tags = append(tags, string(c.Severity))
+	}
+	tags = append(tags, c.Labels...)
+	if len(tags) > 0 {
+		location += " [" + strings.Join(tags, ", ") + "]"
+	}
+	if c.ParentID != "" {
+		location += " (reply)"
+	}
+	if c.Status != "" && c.Status != StatusOpen {
+		location += " (" + string(c.Status) + ")"
+	}
+	if c.UpdatedAt != nil {
+		location += " (edited)"
+	}
+
+	fmt.Fprintf(w, "\n%s\n", location)
+	fmt.Fprintf(w, "%s\n", c.Content)
+	if patch := c.SuggestionPatch(); patch != "" {
+		fmt.Fprintf(w, "Suggested change:\n%s", patch)
+	}
+}
+
+// WriteThreadsText writes threads in text mode, each comment followed by its replies
+func WriteThreadsText(w io.Writer, threads []*Thread) {
+	for _, t := range threads {
+		WriteText(w, t.Comment)
+		for _, reply := range t.Replies {
+			WriteText(w, reply)
+		}
+	}
+}
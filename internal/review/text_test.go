@@ -0,0 +1,85 @@
+package review
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteText(t *testing.T) {
+	edited := time.Now()
+
+	tests := []struct {
+		name    string
+		comment Comment
+		want    string
+	}{
+		{
+			name:    "line",
+			comment: Comment{File: "a.go", Line: 3, Content: "c", Status: StatusOpen, Scope: ScopeLine},
+			want:    "\na.go:3\nc\n",
+		},
+		{
+			name:    "range",
+			comment: Comment{File: "a.go", Line: 3, LineEnd: 5, Content: "c", Status: StatusOpen, Scope: ScopeLine},
+			want:    "\na.go:3-5\nc\n",
+		},
+		{
+			name:    "edited",
+			comment: Comment{File: "a.go", Line: 3, Content: "c", Status: StatusOpen, Scope: ScopeLine, UpdatedAt: &edited},
+			want:    "\na.go:3 (edited)\nc\n",
+		},
+		{
+			name:    "reply",
+			comment: Comment{File: "a.go", Line: 3, Content: "c", Status: StatusOpen, Scope: ScopeLine, ParentID: "1"},
+			want:    "\na.go:3 (reply)\nc\n",
+		},
+		{
+			name:    "resolved",
+			comment: Comment{File: "a.go", Line: 3, Content: "c", Status: StatusResolved, Scope: ScopeLine},
+			want:    "\na.go:3 (resolved)\nc\n",
+		},
+		{
+			name:    "severity and labels",
+			comment: Comment{File: "a.go", Line: 3, Content: "c", Status: StatusOpen, Scope: ScopeLine, Severity: SeverityBug, Labels: []string{"perf", "security"}},
+			want:    "\na.go:3 [bug, perf, security]\nc\n",
+		},
+		{
+			name: "everything",
+			comment: Comment{File: "a.go", Line: 3, Content: "c", Status: StatusWontFix, Scope: ScopeLine, Severity: SeverityNit,
+				ParentID: "1", UpdatedAt: &edited},
+			want: "\na.go:3 [nit] (reply) (wont-fix) (edited)\nc\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			WriteText(&b, &tt.comment)
+			if got := b.String(); got != tt.want {
+				t.Errorf("wrote\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteThreadsText(t *testing.T) {
+	s := NewStore()
+	root := &Comment{File: "a.go", Line: 3, Content: "why?"}
+	if err := s.AddComment(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddReply(root.ID, &Comment{Content: "because"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddComment(&Comment{Content: "overall"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	WriteThreadsText(&b, s.GetThreads())
+	want := "\n(review)\noverall\n\na.go:3\nwhy?\n\na.go:3 (reply)\nbecause\n"
+	if got := b.String(); got != want {
+		t.Errorf("wrote\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package review
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Thread is a top-level comment followed by its replies, oldest first
+type Thread struct {
+	*Comment
+	Replies []*Comment `json:"replies"`
+	// Patch is the comment's suggestion as a patch "git apply" accepts
+	Patch string `json:"patch,omitempty"`
+}
+
+// AddReply answers a comment. Replying to a reply answers the comment that
+// started the thread, so threads stay flat.
+func (s *Store) AddReply(parentID string, reply *Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !reply.Severity.valid() {
+		return fmt.Errorf("%w: %q", ErrInvalidSeverity, reply.Severity)
+	}
+	reply.Labels = normalizeLabels(reply.Labels)
+
+	parent, ok := s.comments[parentID]
+	if !ok {
+		return ErrCommentNotFound
+	}
+	if root, ok := s.comments[parent.ParentID]; ok {
+		parent = root
+	}
+
+	reply.ID = generateID()
+	reply.ParentID = parent.ID
+	reply.CreatedAt = time.Now()
+	// Replies follow the thread, they can't be anchored elsewhere or suggest changes
+	reply.LineID, reply.LineEndID, reply.HunkID, reply.Anchor, reply.Suggestion = "", "", "", nil, nil
+	placeReply(reply, parent)
+
+	s.comments[reply.ID] = reply
+	if err := s.save(); err != nil {
+		delete(s.comments, reply.ID)
+		return err
+	}
+	return nil
+}
+
+// GetThread returns the thread a comment belongs to, or nil if the comment
+// doesn't exist
+func (s *Store) GetThread(id string) *Thread {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.comments[id]
+	if !ok {
+		return nil
+	}
+	if root, ok := s.comments[c.ParentID]; ok {
+		c = root
+	}
+	return s.thread(c)
+}
+
+// GetThreads returns every thread ordered by file and line
+func (s *Store) GetThreads() []*Thread {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	threads := []*Thread{}
+	for _, c := range s.comments {
+		if c.ParentID == "" {
+			threads = append(threads, s.thread(c))
+		}
+	}
+	sort.Slice(threads, func(i, j int) bool {
+		a, b := threads[i].Comment, threads[j].Comment
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+	return threads
+}
+
+// thread collects copies of root and its replies. Callers hold s.mu.
+func (s *Store) thread(root *Comment) *Thread {
+	copied := *root
+	t := &Thread{Comment: &copied, Replies: []*Comment{}, Patch: root.SuggestionPatch()}
+	for _, c := range s.comments {
+		if c.ParentID == root.ID {
+			reply := *c
+			t.Replies = append(t.Replies, &reply)
+		}
+	}
+	sortByCreation(t.Replies)
+	return t
+}
+
+// syncReplies moves replies to where the comment they answer is now and
+// gives them its status, reporting whether any changed. Callers hold s.mu.
+func (s *Store) syncReplies() bool {
+	changed := false
+	for id, c := range s.comments {
+		parent, ok := s.comments[c.ParentID]
+		if !ok {
+			continue
+		}
+		if c.Scope != parent.Scope || c.Hunk != parent.Hunk || c.File != parent.File || c.Side != parent.Side ||
+			c.Line != parent.Line || c.LineEnd != parent.LineEnd || c.Outdated != parent.Outdated || c.Status != parent.Status {
+			reply := *c
+			placeReply(&reply, parent)
+			s.comments[id] = &reply
+			changed = true
+		}
+	}
+	return changed
+}
+
+func placeReply(reply, parent *Comment) {
+	reply.Scope = parent.Scope
+	reply.Hunk = parent.Hunk
+	reply.File = parent.File
+	reply.Side = parent.Side
+	reply.Line = parent.Line
+	reply.LineEnd = parent.LineEnd
+	reply.Outdated = parent.Outdated
+	reply.Status = parent.Status
+}
@@ -0,0 +1,151 @@
+package review
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAddReply(t *testing.T) {
+	s := NewStore()
+	root := &Comment{File: "a.go", Line: 3, LineEnd: 4, Content: "root"}
+	if err := s.AddComment(root); err != nil {
+		t.Fatal(err)
+	}
+	first := &Comment{Content: "first"}
+	if err := s.AddReply(root.ID, first); err != nil {
+		t.Fatal(err)
+	}
+	suggestion := "x\n"
+
+	tests := []struct {
+		name     string
+		parentID string
+		reply    Comment
+		wantErr  error
+	}{
+		{
+			name:     "to the comment",
+			parentID: root.ID,
+			reply:    Comment{Content: "c"},
+		},
+		{
+			name:     "to a reply",
+			parentID: first.ID,
+			reply:    Comment{Content: "c"},
+		},
+		{
+			name:     "with a location and suggestion of its own",
+			parentID: root.ID,
+			reply:    Comment{File: "b.go", Line: 9, LineID: "id", Suggestion: &suggestion, Content: "c"},
+		},
+		{
+			name:     "to a missing comment",
+			parentID: "missing",
+			reply:    Comment{Content: "c"},
+			wantErr:  ErrCommentNotFound,
+		},
+		{
+			name:     "unknown severity",
+			parentID: root.ID,
+			reply:    Comment{Content: "c", Severity: "urgent"},
+			wantErr:  ErrInvalidSeverity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(s.GetAllComments())
+			reply := tt.reply
+			err := s.AddReply(tt.parentID, &reply)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("returned %v, want %v", err, tt.wantErr)
+				}
+				if n := len(s.GetAllComments()); n != before {
+					t.Errorf("%d comments after a failed reply, want %d", n, before)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := s.GetComment(reply.ID)
+			if got.ParentID != root.ID {
+				t.Errorf("replies to %s, want the thread's root %s", got.ParentID, root.ID)
+			}
+			if got.File != "a.go" || got.Line != 3 || got.LineEnd != 4 || got.Scope != ScopeLine ||
+				got.LineID != "" || got.Suggestion != nil {
+				t.Errorf("reply %+v isn't placed at its thread", got)
+			}
+			if thread := s.GetThread(reply.ID); thread.ID != root.ID || thread.Replies[len(thread.Replies)-1].ID != reply.ID {
+				t.Errorf("reply isn't last in its thread %+v", thread)
+			}
+		})
+	}
+}
+
+func TestThreads(t *testing.T) {
+	s := NewStore()
+	add := func(c *Comment) *Comment {
+		t.Helper()
+		if err := s.AddComment(c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	reply := func(parent *Comment, content string) *Comment {
+		t.Helper()
+		c := &Comment{Content: content}
+		if err := s.AddReply(parent.ID, c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	later := add(&Comment{File: "b.go", Line: 1, Content: "b"})
+	moved := add(&Comment{File: "a.go", Line: 9, Content: "a9"})
+	add(&Comment{File: "a.go", Line: 2, Content: "a2"})
+	gone := add(&Comment{File: "a.go", Content: "a"})
+	reply(moved, "first")
+	reply(moved, "second")
+	reply(later, "b reply")
+	reply(gone, "gone reply")
+
+	// Replies move with the comment they answer and go with it
+	line := 5
+	if _, err := s.UpdateComment(moved.ID, CommentEdit{Line: &line}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DeleteComment(gone.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	type summary struct {
+		Location string
+		Replies  []string
+	}
+	var got []summary
+	for _, thread := range s.GetThreads() {
+		th := summary{Location: thread.Location() + " " + thread.Content, Replies: []string{}}
+		for _, r := range thread.Replies {
+			th.Replies = append(th.Replies, r.Location()+" "+r.Content)
+		}
+		got = append(got, th)
+	}
+	want := []summary{
+		{Location: "a.go:2 a2", Replies: []string{}},
+		{Location: "a.go:5 a9", Replies: []string{"a.go:5 first", "a.go:5 second"}},
+		{Location: "b.go:1 b", Replies: []string{"b.go:1 b reply"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("threads\n%+v\nwant\n%+v", got, want)
+	}
+	if n := len(s.GetAllComments()); n != 6 {
+		t.Errorf("%d comments left, want 6", n)
+	}
+	if s.GetThread("missing") != nil {
+		t.Error("found a thread for a missing comment")
+	}
+}
@@ -0,0 +1,170 @@
+package stats
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/malvex/vibediff/internal/git"
+)
+
+// DefaultTop is how many of the largest files are reported by default
+const DefaultTop = 10
+
+// Bucket aggregates the files sharing a directory, language, package or status
+type Bucket struct {
+	Name      string `json:"name"`
+	Files     int    `json:"files"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+}
+
+// FileChurn is the size of the change to a single file
+type FileChurn struct {
+	Path      string `json:"path"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Churn     int    `json:"churn"`
+}
+
+type Stats struct {
+	Files       int         `json:"files"`
+	Additions   int         `json:"additions"`
+	Deletions   int         `json:"deletions"`
+	ByDirectory []Bucket    `json:"byDirectory"`
+	ByLanguage  []Bucket    `json:"byLanguage"`
+	ByPackage   []Bucket    `json:"byPackage"`
+	ByStatus    []Bucket    `json:"byStatus"`
+	Largest     []FileChurn `json:"largest"`
+}
+
+// Compute aggregates a diff. Go packages are named by import path when
+// modulePath is known, by directory otherwise. The top largest files by
+// churn are included.
+func Compute(diff *git.DiffResult, modulePath string, top int) *Stats {
+	stats := &Stats{}
+	byDirectory := make(map[string]*Bucket)
+	byLanguage := make(map[string]*Bucket)
+	byPackage := make(map[string]*Bucket)
+	byStatus := make(map[string]*Bucket)
+
+	largest := make([]FileChurn, 0, len(diff.Files))
+	for _, file := range diff.Files {
+		stats.Files++
+		stats.Additions += file.Additions
+		stats.Deletions += file.Deletions
+
+		add(byDirectory, path.Dir(file.Path), file)
+		add(byLanguage, Language(file.Path), file)
+		add(byStatus, string(file.Status), file)
+		if strings.HasSuffix(file.Path, ".go") {
+			pkg := path.Dir(file.Path)
+			if modulePath != "" {
+				pkg = path.Join(modulePath, pkg)
+			}
+			add(byPackage, pkg, file)
+		}
+
+		largest = append(largest, FileChurn{
+			Path:      file.Path,
+			Additions: file.Additions,
+			Deletions: file.Deletions,
+			Churn:     file.Additions + file.Deletions,
+		})
+	}
+
+	stats.ByDirectory = sorted(byDirectory)
+	stats.ByLanguage = sorted(byLanguage)
+	stats.ByPackage = sorted(byPackage)
+	stats.ByStatus = sorted(byStatus)
+
+	sort.SliceStable(largest, func(i, j int) bool {
+		return largest[i].Churn > largest[j].Churn
+	})
+	if top >= 0 && len(largest) > top {
+		largest = largest[:top]
+	}
+	stats.Largest = largest
+
+	return stats
+}
+
+func add(buckets map[string]*Bucket, name string, file git.FileDiff) {
+	bucket, ok := buckets[name]
+	if !ok {
+		bucket = &Bucket{Name: name}
+		buckets[name] = bucket
+	}
+	bucket.Files++
+	bucket.Additions += file.Additions
+	bucket.Deletions += file.Deletions
+}
+
+// sorted returns the buckets ordered by churn, largest first
+func sorted(buckets map[string]*Bucket) []Bucket {
+	result := make([]Bucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		result = append(result, *bucket)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		ci := result[i].Additions + result[i].Deletions
+		cj := result[j].Additions + result[j].Deletions
+		if ci != cj {
+			return ci > cj
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+var languagesByName = map[string]string{
+	"Dockerfile": "Dockerfile",
+	"Makefile":   "Makefile",
+	"go.mod":     "Go Module",
+	"go.sum":     "Go Module",
+}
+
+var languagesByExt = map[string]string{
+	".c":     "C",
+	".cc":    "C++",
+	".cpp":   "C++",
+	".cs":    "C#",
+	".css":   "CSS",
+	".go":    "Go",
+	".h":     "C",
+	".hpp":   "C++",
+	".html":  "HTML",
+	".java":  "Java",
+	".js":    "JavaScript",
+	".json":  "JSON",
+	".jsx":   "JavaScript",
+	".kt":    "Kotlin",
+	".md":    "Markdown",
+	".php":   "PHP",
+	".proto": "Protocol Buffers",
+	".py":    "Python",
+	".rb":    "Ruby",
+	".rs":    "Rust",
+	".scss":  "SCSS",
+	".sh":    "Shell",
+	".sql":   "SQL",
+	".swift": "Swift",
+	".toml":  "TOML",
+	".ts":    "TypeScript",
+	".tsx":   "TypeScript",
+	".xml":   "XML",
+	".yaml":  "YAML",
+	".yml":   "YAML",
+}
+
+// Language guesses the language of a file from its name or extension
+func Language(filePath string) string {
+	name := path.Base(filePath)
+	if language, ok := languagesByName[name]; ok {
+		return language
+	}
+	if language, ok := languagesByExt[strings.ToLower(path.Ext(name))]; ok {
+		return language
+	}
+	return "Other"
+}
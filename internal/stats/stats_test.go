@@ -0,0 +1,164 @@
+package stats
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/malvex/vibediff/internal/git"
+)
+
+func file(path string, status git.FileStatus, additions, deletions int) git.FileDiff {
+	return git.FileDiff{Path: path, Status: status, Additions: additions, Deletions: deletions}
+}
+
+func TestCompute(t *testing.T) {
+	diff := &git.DiffResult{Files: []git.FileDiff{
+		file("main.go", git.FileStatusModified, 3, 1),
+		file("internal/git/service.go", git.FileStatusModified, 10, 5),
+		file("internal/git/new.go", git.FileStatusAdded, 20, 0),
+		file("web/src/App.tsx", git.FileStatusModified, 2, 2),
+		file("README.md", git.FileStatusDeleted, 0, 7),
+	}}
+
+	tests := []struct {
+		name       string
+		modulePath string
+		top        int
+		want       *Stats
+	}{
+		{
+			name:       "with module path",
+			modulePath: "example.com/m",
+			top:        2,
+			want: &Stats{
+				Files:     5,
+				Additions: 35,
+				Deletions: 15,
+				ByDirectory: []Bucket{
+					{"internal/git", 2, 30, 5},
+					{".", 2, 3, 8},
+					{"web/src", 1, 2, 2},
+				},
+				ByLanguage: []Bucket{
+					{"Go", 3, 33, 6},
+					{"Markdown", 1, 0, 7},
+					{"TypeScript", 1, 2, 2},
+				},
+				ByPackage: []Bucket{
+					{"example.com/m/internal/git", 2, 30, 5},
+					{"example.com/m", 1, 3, 1},
+				},
+				ByStatus: []Bucket{
+					{"modified", 3, 15, 8},
+					{"added", 1, 20, 0},
+					{"deleted", 1, 0, 7},
+				},
+				Largest: []FileChurn{
+					{"internal/git/new.go", 20, 0, 20},
+					{"internal/git/service.go", 10, 5, 15},
+				},
+			},
+		},
+		{
+			name: "packages by directory",
+			top:  1,
+			want: &Stats{
+				Files:     5,
+				Additions: 35,
+				Deletions: 15,
+				ByDirectory: []Bucket{
+					{"internal/git", 2, 30, 5},
+					{".", 2, 3, 8},
+					{"web/src", 1, 2, 2},
+				},
+				ByLanguage: []Bucket{
+					{"Go", 3, 33, 6},
+					{"Markdown", 1, 0, 7},
+					{"TypeScript", 1, 2, 2},
+				},
+				ByPackage: []Bucket{
+					{"internal/git", 2, 30, 5},
+					{".", 1, 3, 1},
+				},
+				ByStatus: []Bucket{
+					{"modified", 3, 15, 8},
+					{"added", 1, 20, 0},
+					{"deleted", 1, 0, 7},
+				},
+				Largest: []FileChurn{
+					{"internal/git/new.go", 20, 0, 20},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Compute(diff, tt.modulePath, tt.top)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compute()\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeLargest(t *testing.T) {
+	diff := &git.DiffResult{Files: []git.FileDiff{
+		file("a", git.FileStatusModified, 1, 1),
+		file("b", git.FileStatusModified, 2, 0),
+		file("c", git.FileStatusModified, 5, 0),
+	}}
+
+	tests := []struct {
+		top  int
+		want []string
+	}{
+		// Equal churn keeps the diff order
+		{top: -1, want: []string{"c", "a", "b"}},
+		{top: 10, want: []string{"c", "a", "b"}},
+		{top: 2, want: []string{"c", "a"}},
+		{top: 0, want: []string{}},
+	}
+	for _, tt := range tests {
+		got := []string{}
+		for _, churn := range Compute(diff, "", tt.top).Largest {
+			got = append(got, churn.Path)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("top %d: largest %q, want %q", tt.top, got, tt.want)
+		}
+	}
+}
+
+func TestComputeEmpty(t *testing.T) {
+	got := Compute(&git.DiffResult{}, "", DefaultTop)
+	want := &Stats{
+		ByDirectory: []Bucket{},
+		ByLanguage:  []Bucket{},
+		ByPackage:   []Bucket{},
+		ByStatus:    []Bucket{},
+		Largest:     []FileChurn{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Compute()\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	tests := map[string]string{
+		"main.go":            "Go",
+		"go.mod":             "Go Module",
+		"build/Dockerfile":   "Dockerfile",
+		"web/src/App.tsx":    "TypeScript",
+		"docs/README.MD":     "Markdown",
+		"scripts/run":        "Other",
+		"config.yml":         "YAML",
+		"lib/.hidden.py":     "Python",
+		"src/Makefile.local": "Other",
+	}
+	for path, want := range tests {
+		if got := Language(path); got != want {
+			t.Errorf("Language(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -1,10 +1,15 @@
 package watcher
 
 import (
+	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -14,6 +19,17 @@ type GitWatcher struct {
 	lastStatus   string
 	pollInterval time.Duration
 	done         chan bool
+	// dirs are polled directly instead of git status when set
+	dirs []string
+	// mu guards workDir, the working tree git status runs in
+	mu      sync.Mutex
+	workDir string
+	// lastBranch is the branch HEAD was on at the last check
+	lastBranch string
+	// onHeadChange is called when another branch is checked out
+	onHeadChange func()
+	// onChange is called when the working tree or branch changes
+	onChange func()
 }
 
 // ChangeNotifier interface for notifying changes
@@ -30,6 +46,40 @@ func NewGitWatcher(hub ChangeNotifier) *GitWatcher {
 	}
 }
 
+// WatchDirs makes the watcher poll the given directories instead of git
+// status, for comparisons of directories that aren't in a repository
+func (w *GitWatcher) WatchDirs(dirs ...string) {
+	w.dirs = dirs
+}
+
+// OnHeadChange sets a function to call when another branch is checked out,
+// before clients are told about it
+func (w *GitWatcher) OnHeadChange(fn func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onHeadChange = fn
+}
+
+// OnChange sets a function to call when the working tree or the checked out
+// branch changes, before clients are told about it
+func (w *GitWatcher) OnChange(fn func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onChange = fn
+}
+
+// SetWorkDir points the watcher at another working tree and tells clients
+// to reload
+func (w *GitWatcher) SetWorkDir(dir string) {
+	w.mu.Lock()
+	w.workDir = dir
+	w.lastStatus = ""
+	w.lastBranch = ""
+	w.mu.Unlock()
+
+	w.hub.NotifyChange("worktree_changed")
+}
+
 // Start begins monitoring for changes
 func (w *GitWatcher) Start() {
 	go func() {
@@ -61,22 +111,50 @@ func (w *GitWatcher) Stop() {
 }
 
 func (w *GitWatcher) checkForChanges() {
-	// Get current git status
-	cmd := exec.Command("git", "status", "--porcelain")
+	if len(w.dirs) > 0 {
+		w.checkDirsForChanges()
+		return
+	}
+
+	w.mu.Lock()
+
+	// Get current git status, --branch adds a "## branch...upstream" line
+	// first so checking out another branch is noticed too
+	cmd := exec.Command("git", "status", "--porcelain", "--branch")
+	cmd.Dir = w.workDir
 	output, err := cmd.Output()
 	if err != nil {
+		w.mu.Unlock()
 		if os.Getenv("VIBEDIFF_DEBUG") == "true" {
 			log.Printf("Error checking git status: %v", err)
 		}
 		return
 	}
 
-	currentStatus := string(output)
+	branchLine, currentStatus, _ := strings.Cut(string(output), "\n")
+	branch, _, _ := strings.Cut(strings.TrimPrefix(branchLine, "## "), "...")
+	headChanged := w.lastBranch != "" && branch != w.lastBranch
+	w.lastBranch = branch
 
-	// Check if status changed
-	if currentStatus != w.lastStatus {
-		w.lastStatus = currentStatus
+	// Editing a file that is already modified leaves its status as it was
+	currentStatus += changedFileStamps(w.workDir, currentStatus)
+	statusChanged := currentStatus != w.lastStatus
+	w.lastStatus = currentStatus
+	onHeadChange, onChange := w.onHeadChange, w.onChange
+	w.mu.Unlock()
+
+	if headChanged && onHeadChange != nil {
+		onHeadChange()
+	}
+	if (headChanged || statusChanged) && onChange != nil {
+		onChange()
+	}
+	if headChanged {
+		w.hub.NotifyChange("head_changed")
+	}
 
+	// Check if status changed
+	if statusChanged {
 		// Determine change type
 		changeType := "file_changed"
 		if strings.Contains(currentStatus, "??") {
@@ -89,3 +167,54 @@ func (w *GitWatcher) checkForChanges() {
 		w.hub.NotifyChange(changeType)
 	}
 }
+
+// changedFileStamps returns the size and modification time of the files
+// listed in porcelain git status output
+func changedFileStamps(workDir, status string) string {
+	var stamps strings.Builder
+	for _, line := range strings.Split(status, "\n") {
+		if len(line) < 4 {
+			continue
+		}
+		path := line[3:]
+		if _, renamed, ok := strings.Cut(path, " -> "); ok {
+			path = renamed
+		}
+		if unquoted, err := strconv.Unquote(path); err == nil {
+			path = unquoted
+		}
+		if info, err := os.Stat(filepath.Join(workDir, path)); err == nil {
+			fmt.Fprintf(&stamps, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return stamps.String()
+}
+
+func (w *GitWatcher) checkDirsForChanges() {
+	var snapshot strings.Builder
+	for _, dir := range w.dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&snapshot, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+			return nil
+		})
+		if err != nil {
+			if os.Getenv("VIBEDIFF_DEBUG") == "true" {
+				log.Printf("Error scanning %s: %v", dir, err)
+			}
+			return
+		}
+	}
+
+	currentStatus := snapshot.String()
+	if currentStatus != w.lastStatus {
+		w.lastStatus = currentStatus
+		w.hub.NotifyChange("file_changed")
+	}
+}
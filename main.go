@@ -54,6 +54,21 @@ func openBrowser(url string) error {
 	return exec.Command(cmd, args...).Start()
 }
 
+// loadPatch reads a patch from the given file, or from stdin when path is "-"
+func loadPatch(service *git.Service, path string) error {
+	if path == "-" {
+		return service.LoadPatch(os.Stdin)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return service.LoadPatch(f)
+}
+
 func main() {
 	// Parse command line flags
 	var (
@@ -63,6 +78,10 @@ func main() {
 		version = flag.Bool("version", false, "Show version information")
 		format  = flag.String("format", "text", "Output format for review comments (text or json)")
 		noOpen  = flag.Bool("no-open", false, "Disable automatic browser opening")
+		noIndex = flag.Bool("no-index", false, "Compare two directories outside of a repository (requires <dirA> <dirB>)")
+		pr      = flag.Bool("pr", false, "Compare with the merge-base of the upstream or default branch, like a pull request")
+		autoPR  = flag.Bool("auto-pr", false, "Use -pr automatically on branches that diverged from their base")
+		fresh   = flag.Bool("new-review", false, "Archive the saved review of this branch and start an empty one")
 	)
 	flag.Parse()
 
@@ -86,9 +105,39 @@ func main() {
 		os.Setenv("VIBEDIFF_DEBUG", "true")
 	}
 
-	// Get diff target from positional argument
-	var target string
-	if flag.NArg() > 0 {
+	// Get diff target or patch to review from positional arguments:
+	//   vibediff [target]            diff the working tree against target
+	//   vibediff review <file|->     review a patch file or stdin
+	//   vibediff -                   review a patch from stdin
+	//   vibediff -no-index <a> <b>   compare two directories
+	//   vibediff api [target]        print exported Go API changes and exit
+	//   vibediff stats [target]      print change statistics and exit
+	//   vibediff range-diff <a> <b>  compare two versions of a patch series and exit
+	var target, patchPath, command string
+	switch {
+	case *noIndex:
+		if flag.NArg() != 2 {
+			fmt.Fprintln(os.Stderr, "Usage: vibediff -no-index <dirA> <dirB>")
+			os.Exit(1)
+		}
+	case flag.Arg(0) == "review":
+		if flag.NArg() < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: vibediff review <patch-file|->")
+			os.Exit(1)
+		}
+		patchPath = flag.Arg(1)
+	case flag.Arg(0) == "-":
+		patchPath = "-"
+	case flag.Arg(0) == "range-diff":
+		if flag.NArg() != 3 {
+			fmt.Fprintln(os.Stderr, "Usage: vibediff range-diff <old-base>..<old-head> <new-base>..<new-head>")
+			os.Exit(1)
+		}
+		command = flag.Arg(0)
+	case flag.Arg(0) == "api", flag.Arg(0) == "stats":
+		command = flag.Arg(0)
+		target = flag.Arg(1)
+	case flag.NArg() > 0:
 		target = flag.Arg(0)
 	}
 
@@ -96,16 +145,95 @@ func main() {
 
 	gitService := git.NewService()
 	gitService.SetDiffTarget(target)
+	switch {
+	case *pr:
+		gitService.SetBaseMode(git.BaseModePR)
+	case *autoPR:
+		gitService.SetBaseMode(git.BaseModeAuto)
+	}
+	if *noIndex {
+		if err := gitService.SetCompareDirs(flag.Arg(0), flag.Arg(1)); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to compare directories: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if patchPath != "" {
+		if err := loadPatch(gitService, patchPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load patch: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *pr || *autoPR {
+		base, err := gitService.DiffBase(git.DiffTypeAll)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Failed to find PR base: %v\n", err)
+			os.Exit(1)
+		case base != nil:
+			fmt.Fprintf(os.Stderr, "Comparing with merge-base of %s (%.7s)\n", base.Ref, base.Commit)
+		}
+	}
+	switch command {
+	case "api":
+		os.Exit(runAPICommand(gitService, *format))
+	case "stats":
+		os.Exit(runStatsCommand(gitService, *format))
+	case "range-diff":
+		os.Exit(runRangeDiffCommand(gitService, flag.Arg(1), flag.Arg(2), *format))
+	}
+
+	// Pick up the review of this branch and target where it was left off
+	if path, err := gitService.ReviewStatePath(); err != nil {
+		fmt.Fprintf(os.Stderr, "Comments won't be saved: %v\n", err)
+	} else if path != "" {
+		n := 0
+		err := reviewStore.Open(path)
+		if err == nil {
+			n = len(reviewStore.GetAllComments())
+		}
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Comments won't be saved: %v\n", err)
+		case *fresh:
+			if archived, err := reviewStore.Archive(); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to archive review: %v\n", err)
+				os.Exit(1)
+			} else if archived != "" {
+				fmt.Fprintf(os.Stderr, "Archived %d review comments to %s\n", n, archived)
+			}
+		case n > 0:
+			fmt.Fprintf(os.Stderr, "Restored %d review comments from %s\n", n, path)
+		}
+	}
+
 	handler := handlers.NewHandler(gitService, reviewStore)
 	handler.SetFormat(*format)
+	// Saved comments follow code that changed while VibeDiff wasn't running
+	handler.ReanchorComments()
 
 	// Create WebSocket hub
 	wsHub := handlers.NewWSHub()
 	go wsHub.Run()
 
-	// Start file watcher
+	// Start file watcher, a loaded patch never changes
 	gitWatcher := watcher.NewGitWatcher(wsHub)
-	gitWatcher.Start()
+	if *noIndex {
+		gitWatcher.WatchDirs(gitService.CompareDirs())
+	}
+	if !gitService.ReadOnly() {
+		gitWatcher.Start()
+		// The watcher says when a snapshot of the working tree goes stale
+		gitService.CacheWorkingTree()
+	}
+	// Checking out another branch switches to that branch's review, and
+	// comments follow their code whenever the working tree changes
+	gitWatcher.OnHeadChange(handler.ReloadReview)
+	gitWatcher.OnChange(func() {
+		gitService.WorkingTreeChanged()
+		handler.ReanchorComments()
+	})
+	handler.SetWatcher(gitWatcher)
+	handler.SetHub(wsHub)
 
 	r := mux.NewRouter()
 
@@ -114,7 +242,26 @@ func main() {
 	r.HandleFunc("/api/diff/{file:.+}", handler.GetFileDiff).Methods("GET")
 	r.HandleFunc("/api/review/comment", handler.AddComment).Methods("POST")
 	r.HandleFunc("/api/review/comments", handler.GetComments).Methods("GET")
+	r.HandleFunc("/api/review/threads", handler.GetThreads).Methods("GET")
+	r.HandleFunc("/api/review/comment/{id}/reply", handler.AddReply).Methods("POST")
+	r.HandleFunc("/api/review/comment/{id}/thread", handler.GetThread).Methods("GET")
+	r.HandleFunc("/api/review/comment/{id}/status", handler.SetCommentStatus).Methods("POST")
+	// Applying a suggestion writes to the working tree, which other sites mustn't trigger
+	r.Handle("/api/review/comment/{id}/apply", handlers.RejectCrossSite(handler.ApplySuggestion)).Methods("POST")
+	r.HandleFunc("/api/review/export", handler.ExportComments).Methods("GET")
+	r.HandleFunc("/api/review/comment/{id}", handler.UpdateComment).Methods("PATCH")
 	r.HandleFunc("/api/review/comment/{id}", handler.DeleteComment).Methods("DELETE")
+	r.HandleFunc("/api/review/archive", handler.ArchiveReview).Methods("POST")
+	r.HandleFunc("/api/review/round", handler.GetReviewRound).Methods("GET")
+	r.HandleFunc("/api/review/round", handler.EndReviewRound).Methods("POST")
+	r.HandleFunc("/api/analysis/api", handler.GetAPIChanges).Methods("GET")
+	r.HandleFunc("/api/stats", handler.GetStats).Methods("GET")
+	r.HandleFunc("/api/outline", handler.GetOutlines).Methods("GET")
+	r.HandleFunc("/api/range-diff", handler.GetRangeDiff).Methods("GET")
+	r.HandleFunc("/api/search", handler.Search).Methods("GET")
+	r.HandleFunc("/api/export", handler.ExportPatch).Methods("POST")
+	r.HandleFunc("/api/worktrees", handler.GetWorktrees).Methods("GET")
+	r.HandleFunc("/api/worktrees/select", handler.SelectWorktree).Methods("POST")
 
 	// WebSocket endpoint for live updates
 	r.HandleFunc("/api/ws", handler.HandleWebSocket(wsHub)).Methods("GET")
@@ -167,7 +314,7 @@ func main() {
 
 	go func() {
 		fmt.Fprintf(os.Stderr, "Starting VibeDiff server on http://%s\n", addr)
-		
+
 		// Open browser if enabled
 		if shouldOpen {
 			// Give the server a moment to start
@@ -179,7 +326,7 @@ func main() {
 				fmt.Fprintf(os.Stderr, "Opening browser at %s\n", url)
 			}
 		}
-		
+
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server failed to start: %v", err)
 		}
@@ -207,10 +354,13 @@ func main() {
 		log.Printf("Server shutdown error: %v", err)
 	}
 
-	comments := reviewStore.GetAllComments()
-	if len(comments) > 0 {
+	// Threads list each comment with its replies nested under it. Resolved
+	// threads need no more work, so they're left out, and blockers go first.
+	threads := review.FilterThreads(reviewStore.GetThreads(), review.ExportFilter())
+	review.SortThreadsBySeverity(threads)
+	if len(threads) > 0 {
 		if *format == "json" {
-			output, err := json.MarshalIndent(comments, "", "  ")
+			output, err := json.MarshalIndent(threads, "", "  ")
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error marshaling comments: %v\n", err)
 			} else {
@@ -219,7 +369,11 @@ func main() {
 		}
 		// For text format, comments are already printed when added
 	} else {
-		if *format != "json" {
+		switch {
+		case *format == "json":
+		case len(reviewStore.GetAllComments()) > 0:
+			fmt.Fprintln(os.Stderr, "\nAll review comments were resolved.")
+		default:
 			fmt.Fprintln(os.Stderr, "\nNo review comments were added.")
 		}
 	}
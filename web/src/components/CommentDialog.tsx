@@ -1,16 +1,22 @@
 import { useState, useEffect, useRef } from 'react'
+import type { CommentSeverity } from '../types/diff'
+
+const severities: CommentSeverity[] = ['blocker', 'bug', 'suggestion', 'nit', 'question']
 
 interface CommentDialogProps {
   isOpen: boolean
   file: string
   line: number
   lineEnd: number
-  onSubmit: (content: string) => void
+  onSubmit: (content: string, severity?: CommentSeverity, suggestion?: string) => void
   onClose: () => void
 }
 
 export default function CommentDialog({ isOpen, file, line, lineEnd, onSubmit, onClose }: CommentDialogProps): React.ReactElement | null {
   const [content, setContent] = useState('')
+  const [severity, setSeverity] = useState<CommentSeverity | ''>('')
+  // suggestion is replacement text for the lines, null when not suggesting
+  const [suggestion, setSuggestion] = useState<string | null>(null)
   const textareaRef = useRef<HTMLTextAreaElement>(null)
 
   useEffect(() => {
@@ -22,8 +28,10 @@ export default function CommentDialog({ isOpen, file, line, lineEnd, onSubmit, o
   const handleSubmit = (e: React.FormEvent): void => {
     e.preventDefault()
     if (content.trim()) {
-      onSubmit(content.trim())
+      onSubmit(content.trim(), severity === '' ? undefined : severity, suggestion ?? undefined)
       setContent('')
+      setSeverity('')
+      setSuggestion(null)
     }
   }
 
@@ -38,6 +46,9 @@ export default function CommentDialog({ isOpen, file, line, lineEnd, onSubmit, o
 
   if (!isOpen) return null
 
+  // Suggestions replace lines of the new side, deleted lines have none
+  const canSuggest = line > 0 && lineEnd > 0
+
   return (
     <div className="fixed inset-0 z-[2000] flex items-center justify-center bg-[rgba(0,0,0,0.5)] dark:bg-[rgba(1,4,9,0.8)]" onClick={onClose}>
       <div
@@ -63,7 +74,43 @@ export default function CommentDialog({ isOpen, file, line, lineEnd, onSubmit, o
             style={{ minHeight: '100px', fontFamily: 'inherit' }}
           />
 
+          {canSuggest && (
+            <label className="flex items-center gap-2 text-sm text-[#24292e] dark:text-[#c9d1d9] mb-2">
+              <input
+                type="checkbox"
+                checked={suggestion !== null}
+                onChange={(e) => { setSuggestion(e.target.checked ? '' : null); }}
+              />
+              Suggest a change
+            </label>
+          )}
+          {canSuggest && suggestion !== null && (
+            <textarea
+              value={suggestion}
+              onChange={(e) => { setSuggestion(e.target.value); }}
+              onKeyDown={(e) => { if (e.key === 'Escape') onClose() }}
+              placeholder="Replacement for the selected lines, leave empty to delete them"
+              className="w-full px-2 py-2 border border-[#e1e4e8] dark:border-[#30363d] rounded-md text-sm font-mono
+                bg-white dark:bg-[#0d1117] text-[#24292e] dark:text-[#c9d1d9]
+                placeholder-[#6a737d] dark:placeholder-[#8b949e]
+                focus:outline-none focus:border-[#0366d6] dark:focus:border-[#1f6feb]
+                resize-vertical mb-2"
+              style={{ minHeight: '80px' }}
+            />
+          )}
+
           <div className="flex justify-end gap-2">
+            <select
+              value={severity}
+              onChange={(e) => { setSeverity(e.target.value as CommentSeverity | ''); }}
+              className="mr-auto px-2 py-[5px] text-sm border border-[#e1e4e8] dark:border-[#30363d] rounded-md bg-white dark:bg-[#0d1117] text-[#24292e] dark:text-[#c9d1d9]"
+              title="Severity"
+            >
+              <option value="">No severity</option>
+              {severities.map(s => (
+                <option key={s} value={s}>{s}</option>
+              ))}
+            </select>
             <button
               type="button"
               onClick={onClose}
@@ -1,4 +1,31 @@
-import type { Comment } from '../types/diff'
+import type { Comment, CommentStatus } from '../types/diff'
+
+const statusLabels: Record<CommentStatus, string> = {
+  'open': 'Open',
+  'resolved': 'Resolved',
+  'wont-fix': "Won't fix",
+  'addressed-by-agent': 'Addressed by agent'
+}
+
+// The server resolves the comment and broadcasts it, which refreshes the comments
+async function applySuggestion(id: string): Promise<void> {
+  const response = await fetch(`/api/review/comment/${id}/apply`, { method: 'POST' })
+  if (!response.ok) {
+    window.alert(await response.text())
+  }
+}
+
+// The server broadcasts the change, which refreshes the comments
+async function setStatus(id: string, status: CommentStatus): Promise<void> {
+  const response = await fetch(`/api/review/comment/${id}/status`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ status })
+  })
+  if (!response.ok) {
+    console.error('Failed to set comment status:', await response.text())
+  }
+}
 
 interface CommentDisplayProps {
   comments: Comment[]
@@ -14,14 +41,51 @@ export default function CommentDisplay({ comments, onDelete }: CommentDisplayPro
         <div key={comment.id} data-comment-id={comment.id} className="bg-white dark:bg-[#0d1117] border border-[#d1d5da] dark:border-[#30363d] rounded-md p-2 my-1 relative">
           <div className="flex justify-between items-center pb-1 mb-1 border-b border-[#e1e4e8] dark:border-[#30363d]">
             <div className="text-xs text-[#586069] dark:text-[#8b949e]">
-              {comment.lineEnd !== comment.line
+              {comment.parentId
+                ? 'Reply'
+                : comment.scope === 'review'
+                ? 'Review comment'
+                : comment.scope === 'file'
+                ? 'Comment on file'
+                : comment.scope === 'hunk'
+                ? `Comment on hunk ${comment.hunk ?? ''}`
+                : comment.lineEnd !== comment.line
                 ? `Comment on lines ${Math.abs(comment.line)} to ${Math.abs(comment.lineEnd)}`
                 : `Comment on line ${Math.abs(comment.line)}`}
+              {[comment.severity, ...(comment.labels ?? [])].filter(Boolean).map(tag => (
+                <span key={tag} className="ml-2 px-1.5 py-[1px] rounded-md bg-[#f1f8ff] dark:bg-[#0d1d33] text-[#0366d6] dark:text-[#58a6ff]">
+                  {tag}
+                </span>
+              ))}
+              {comment.status !== 'open' && (
+                <span className="ml-2 px-1.5 py-[1px] rounded-md border border-[#d1d5da] dark:border-[#30363d]">
+                  {statusLabels[comment.status]}
+                </span>
+              )}
+              {comment.outdated && (
+                <span
+                  className="ml-2 px-1.5 py-[1px] rounded-md border border-[#d1d5da] dark:border-[#30363d] text-[#b08800] dark:text-[#d29922]"
+                  title="The code this comment was made on has changed"
+                >
+                  Outdated
+                </span>
+              )}
             </div>
             <div className="flex items-center gap-2">
               <div className="text-xs text-[#586069] dark:text-[#8b949e] whitespace-nowrap">
                 {new Date(comment.createdAt).toLocaleString()}
+                {comment.updatedAt && (
+                  <span title={`Edited ${new Date(comment.updatedAt).toLocaleString()}`}> (edited)</span>
+                )}
               </div>
+              {!comment.parentId && (
+                <button
+                  onClick={() => { void setStatus(comment.id, comment.status === 'resolved' ? 'open' : 'resolved'); }}
+                  className="text-xs text-[#0366d6] dark:text-[#58a6ff] hover:underline cursor-pointer border-none bg-transparent p-0"
+                >
+                  {comment.status === 'resolved' ? 'Reopen' : 'Resolve'}
+                </button>
+              )}
               <button
                 onClick={() => { onDelete(comment.id); }}
                 className="text-[rgba(27,31,35,.3)] dark:text-[rgba(139,148,158,.4)] hover:text-[#d73a49] dark:hover:text-[#f85149] text-xl leading-none p-0 w-5 h-5 flex items-center justify-center cursor-pointer border-none bg-transparent"
@@ -34,6 +98,24 @@ export default function CommentDisplay({ comments, onDelete }: CommentDisplayPro
           <div className="text-sm leading-[1.5] font-[-apple-system,BlinkMacSystemFont,'Segoe_UI',Helvetica,Arial,sans-serif]">
             {comment.content}
           </div>
+          {comment.suggestion !== undefined && (
+            <div className="mt-2 border border-[#d1d5da] dark:border-[#30363d] rounded-md overflow-hidden">
+              <div className="flex justify-between items-center px-2 py-1 text-xs text-[#586069] dark:text-[#8b949e] bg-[#f6f8fa] dark:bg-[#161b22]">
+                <span>Suggested change</span>
+                {comment.status !== 'resolved' && !comment.outdated && (
+                  <button
+                    onClick={() => { void applySuggestion(comment.id); }}
+                    className="text-xs text-[#0366d6] dark:text-[#58a6ff] hover:underline cursor-pointer border-none bg-transparent p-0"
+                  >
+                    Apply
+                  </button>
+                )}
+              </div>
+              <pre className="m-0 px-2 py-1 text-xs font-mono whitespace-pre-wrap bg-[#e6ffec] dark:bg-[rgba(46,160,67,0.15)]">
+                {comment.suggestion === '' ? '(delete these lines)' : comment.suggestion}
+              </pre>
+            </div>
+          )}
         </div>
       ))}
     </div>
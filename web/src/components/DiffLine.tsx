@@ -37,6 +37,16 @@ const AddCommentButton = ({ onDragStart }: { onDragStart?: () => void }): React.
   </button>
 )
 
+// Shown after the last line of a side that doesn't end with a newline
+const NoNewlineMarker = (): React.ReactElement => (
+  <span
+    className="ml-2 px-1 rounded-[3px] text-[10px] text-[#cf222e] dark:text-[#f85149] border border-current select-none"
+    title="No newline at end of file"
+  >
+    ⊘ no newline
+  </span>
+)
+
 const DiffLine = React.memo(({
   line,
   viewMode,
@@ -83,6 +93,7 @@ const DiffLine = React.memo(({
         {/* Code Line */}
         <td className={`line-code px-[10px] py-0 relative w-full ${wrapLines ? 'whitespace-pre-wrap break-all' : 'whitespace-pre'} ${config.codeClass}`} data-prefix={config.prefix}>
           <code className={`language-${getLanguageFromFilename(filename)}`} dangerouslySetInnerHTML={{ __html: highlightedContent }} />
+          {line.noNewline && <NoNewlineMarker />}
 
           <AddCommentButton onDragStart={onDragStart} />
         </td>
@@ -100,6 +111,7 @@ const DiffLine = React.memo(({
           </td>
           <td className={`line-code px-[10px] py-0 relative border-r-2 border-r-[#e1e4e8] dark:border-r-[#30363d] ${wrapLines ? 'whitespace-pre-wrap break-all' : 'whitespace-pre'} ${config.codeClass} ${isInSelection ? 'line-selected' : ''} ${isInCommentRange ? 'line-commented-range' : ''}`} data-prefix={config.prefix}>
             <code className={`language-${getLanguageFromFilename(filename)}`} dangerouslySetInnerHTML={{ __html: highlightedContent }} />
+            {line.noNewline && <NoNewlineMarker />}
             <AddCommentButton onDragStart={onDragStart} />
           </td>
         </>
@@ -110,6 +122,7 @@ const DiffLine = React.memo(({
           </td>
           <td className={`line-code px-[10px] py-0 relative ${wrapLines ? 'whitespace-pre-wrap break-all' : 'whitespace-pre'} ${config.codeClass} ${isInSelection ? 'line-selected' : ''} ${isInCommentRange ? 'line-commented-range' : ''}`} data-prefix={config.prefix}>
             <code className={`language-${getLanguageFromFilename(filename)}`} dangerouslySetInnerHTML={{ __html: highlightedContent }} />
+            {line.noNewline && <NoNewlineMarker />}
             <AddCommentButton onDragStart={onDragStart} />
           </td>
         </>
@@ -7,9 +7,11 @@ import { useWebSocketUpdates } from '../contexts/WebSocketContext'
 import { getButtonClassName } from '../utils/buttonStyles'
 import FileList from './FileList'
 import FileDiff from './FileDiff'
+import CommentDisplay from './CommentDisplay'
 import CommentDialog from './CommentDialog'
 import FullFileModal from './FullFileModal'
 import DarkModeToggle from './DarkModeToggle'
+import WorktreeSelector from './WorktreeSelector'
 
 interface DiffViewerProps {
   className?: string
@@ -29,7 +31,7 @@ export default function DiffViewer({ className = '' }: DiffViewerProps): React.R
   const [isRefreshing, setIsRefreshing] = useState(false)
 
   const { data, loading, error, refetch } = useDiff(diffType)
-  const { addComment, deleteComment, getCommentsForLine, getCommentRangeLines } = useComments()
+  const { addComment, deleteComment, getCommentsForLine, getCommentRangeLines } = useComments(data, diffType)
   const { lastUpdate } = useWebSocketUpdates()
 
   // Refetch when WebSocket triggers an update
@@ -174,6 +176,15 @@ export default function DiffViewer({ className = '' }: DiffViewerProps): React.R
           <div className="flex items-center justify-between flex-nowrap">
             <div className="flex items-center gap-2">
               <h1 className="text-xl font-semibold">VibeDiff</h1>
+              <WorktreeSelector />
+              {data?.base && (
+                <span
+                  className="text-sm text-gray-400"
+                  title={data.base.mergeBase ? `Merge-base of HEAD and ${data.base.ref}` : undefined}
+                >
+                  vs {data.base.ref}{data.base.commit ? ` @ ${data.base.commit.slice(0, 7)}` : ''}
+                </span>
+              )}
               {isRefreshing && (
                 <span className="text-sm text-gray-400 animate-pulse">Updating...</span>
               )}
@@ -273,6 +284,7 @@ export default function DiffViewer({ className = '' }: DiffViewerProps): React.R
           {/* File List */}
           <FileList
             files={data?.files ?? []}
+            diffType={diffType}
             selectedFile={selectedFile}
             onSelectFile={setSelectedFile}
             displayMode={displayMode}
@@ -320,6 +332,10 @@ export default function DiffViewer({ className = '' }: DiffViewerProps): React.R
           if (displayMode === 'all') {
             return (
           <div className="p-6">
+            <CommentDisplay
+              comments={getCommentsForLine('', 0)}
+              onDelete={(id) => { void deleteComment(id); }}
+            />
             {data.files.map((file) => (
               <FileDiff
                 key={file.path}
@@ -341,6 +357,10 @@ export default function DiffViewer({ className = '' }: DiffViewerProps): React.R
           if (selectedFile !== null) {
             return (
           <div className="p-6">
+            <CommentDisplay
+              comments={getCommentsForLine('', 0)}
+              onDelete={(id) => { void deleteComment(id); }}
+            />
             <FileDiff
               file={selectedFile}
               viewMode={viewMode}
@@ -370,9 +390,9 @@ export default function DiffViewer({ className = '' }: DiffViewerProps): React.R
         file={commentDialog?.file ?? ''}
         line={commentDialog?.line ?? 0}
         lineEnd={commentDialog?.lineEnd ?? 0}
-        onSubmit={(content) => {
+        onSubmit={(content, severity, suggestion) => {
           if (commentDialog) {
-            void addComment(commentDialog.file, commentDialog.line, content, commentDialog.lineEnd).then(() => {
+            void addComment(commentDialog.file, commentDialog.line, content, commentDialog.lineEnd, severity, suggestion).then(() => {
               setCommentDialog(null)
             }).catch((err: unknown) => {
               console.error('Failed to add comment:', err)
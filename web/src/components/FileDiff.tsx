@@ -3,6 +3,7 @@ import type { FileDiff as FileDiffType, ViewMode, DiffLine as DiffLineType, Comm
 import DiffLine from './DiffLine'
 import CommentDisplay from './CommentDisplay'
 import { useRangeSelection } from '../hooks/useRangeSelection'
+import { downloadPatch, type PatchSelection } from '../utils/exportPatch'
 
 interface SplitViewLineResult {
   line: React.ReactNode
@@ -52,14 +53,31 @@ export default function FileDiff({
   , [getCommentRangeLines, file.path, lineOrder])
 
   const handleSelect = useCallback((line: number, lineEnd: number) => {
+    // A range is on one side, so one over deleted and added lines keeps
+    // the added ones
+    if ((line < 0) !== (lineEnd < 0)) {
+      const start = lineOrder.indexOf(line)
+      const end = lineOrder.indexOf(lineEnd)
+      const added = lineOrder.slice(Math.min(start, end), Math.max(start, end) + 1).filter(n => n > 0)
+      if (added.length > 0) {
+        line = added[0]
+        lineEnd = added[added.length - 1]
+      }
+    }
     onAddComment(line, lineEnd)
-  }, [onAddComment])
+  }, [onAddComment, lineOrder])
 
   const { handleDragStart, handleDragEnter, selectedLines } = useRangeSelection({
     lineOrder,
     onSelect: handleSelect
   })
 
+  const exportPatch = useCallback((selection: PatchSelection) => {
+    void downloadPatch(selection).catch((err: unknown) => {
+      console.error('Failed to export patch:', err)
+    })
+  }, [])
+
   return (
     <div id={`file-${file.path.replace(/\//g, '-')}`} className="border border-[#d1d5da] dark:border-[#30363d] rounded-md mb-4">
       {/* File Header */}
@@ -76,6 +94,15 @@ export default function FileDiff({
             <span className="text-sm font-semibold text-[#24292e] dark:text-[#c9d1d9] font-[-apple-system,BlinkMacSystemFont,'Segoe_UI',Helvetica,Arial,sans-serif]">
               {file.path}
             </span>
+            {file.oldFormat && file.newFormat && (file.oldFormat.eol !== file.newFormat.eol || file.oldFormat.bom !== file.newFormat.bom || file.oldFormat.encoding !== file.newFormat.encoding) && (
+              <span className="text-xs text-gray-500 dark:text-gray-400 block">
+                {file.eolOnly ? 'only ' : ''}
+                {file.oldFormat.eol !== file.newFormat.eol && `line endings ${file.oldFormat.eol.toUpperCase()} → ${file.newFormat.eol.toUpperCase()} `}
+                {file.oldFormat.bom !== file.newFormat.bom && (file.newFormat.bom ? 'BOM added ' : 'BOM removed ')}
+                {file.oldFormat.encoding !== file.newFormat.encoding && `encoding ${file.oldFormat.encoding} → ${file.newFormat.encoding}`}
+                changed
+              </span>
+            )}
             {file.isRenamed && file.oldPath && (
               <span className="text-xs text-gray-500 dark:text-gray-400 block">
                 renamed from {file.oldPath}
@@ -89,6 +116,14 @@ export default function FileDiff({
             <span className="text-[#d73a49] dark:text-[#f85149]">-{file.deletions}</span>
           </div>
 
+          <button
+            onClick={(e) => { e.stopPropagation(); exportPatch({ files: [file.path] }); }}
+            className="px-3 py-[3px] text-xs font-medium bg-[#fafbfc] dark:bg-[#21262d] text-[#24292e] dark:text-[#c9d1d9] border border-[rgba(27,31,35,.15)] dark:border-[#30363d] rounded-md hover:bg-[#f3f4f6] dark:hover:bg-[#30363d] transition-colors cursor-pointer"
+            title="Download this file's changes as a patch"
+          >
+            Patch
+          </button>
+
           {!hideViewFullFile && (
             <button
               onClick={(e) => { e.stopPropagation(); onViewFullFile(); }}
@@ -101,6 +136,29 @@ export default function FileDiff({
         </div>
       </div>
 
+      {/* File comments aren't on any line */}
+      {!collapsed && (
+        <CommentDisplay
+          comments={getCommentsForLine(file.path, 0)}
+          onDelete={(id) => { void onDeleteComment(id); }}
+        />
+      )}
+
+      {/* Generated, vendored and ignored files are sent without hunks */}
+      {!collapsed && file.hunksOmitted && (
+        <div className="px-4 py-3 text-sm text-[#586069] dark:text-[#8b949e]">
+          {file.generated ? 'Generated file' : file.vendored ? 'Vendored file' : 'Ignored file'} not shown.
+          {!hideViewFullFile && (
+            <button
+              onClick={onViewFullFile}
+              className="ml-2 text-[#0366d6] dark:text-[#58a6ff] hover:underline cursor-pointer"
+            >
+              Load diff
+            </button>
+          )}
+        </div>
+      )}
+
       {/* Diff Content */}
       {!collapsed && (
         <div className="overflow-x-auto">
@@ -113,6 +171,15 @@ export default function FileDiff({
                     <tr>
                       <td colSpan={3} className="px-[10px] py-1 text-xs font-mono text-left" style={{ backgroundColor: 'var(--color-hunk-bg)', color: 'var(--color-hunk-text)' }}>
                         {hunk.header}
+                        {hunk.id && (
+                          <button
+                            onClick={() => { exportPatch({ hunks: [hunk.id ?? ''] }); }}
+                            className="float-right hover:underline cursor-pointer"
+                            title="Download this hunk as a patch"
+                          >
+                            Patch
+                          </button>
+                        )}
                       </td>
                     </tr>
 
@@ -162,6 +229,15 @@ export default function FileDiff({
                     <tr>
                       <td colSpan={4} className="px-[10px] py-1 text-xs font-mono text-left" style={{ backgroundColor: 'var(--color-hunk-bg)', color: 'var(--color-hunk-text)' }}>
                         {hunk.header}
+                        {hunk.id && (
+                          <button
+                            onClick={() => { exportPatch({ hunks: [hunk.id ?? ''] }); }}
+                            className="float-right hover:underline cursor-pointer"
+                            title="Download this hunk as a patch"
+                          >
+                            Patch
+                          </button>
+                        )}
                       </td>
                     </tr>
 
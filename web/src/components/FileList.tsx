@@ -1,7 +1,9 @@
-import type { FileDiff } from '../types/diff'
+import { useEffect, useState } from 'react'
+import type { DiffType, FileDiff, OutlineSymbol } from '../types/diff'
 
 interface FileListProps {
   files: FileDiff[]
+  diffType: DiffType
   selectedFile: FileDiff | null
   onSelectFile: (file: FileDiff) => void
   displayMode: 'single' | 'all'
@@ -10,7 +12,70 @@ interface FileListProps {
   onToggleFolderCollapse: (folder: string) => void
 }
 
-export default function FileList({ files, selectedFile, onSelectFile, displayMode, viewMode, collapsedFolders, onToggleFolderCollapse }: FileListProps): React.ReactElement {
+const kindNames: Record<OutlineSymbol['kind'], [string, string]> = {
+  func: ['function', 'functions'],
+  method: ['method', 'methods'],
+  type: ['type', 'types']
+}
+
+// Summarize the changed symbol outline, e.g. "3 functions changed, 1 added"
+function outlineSummary(outline: OutlineSymbol[]): string {
+  const counts = { modified: 0, added: 0, removed: 0 }
+  for (const symbol of outline) {
+    counts[symbol.change]++
+  }
+  // Name the kind when all symbols share it, "3 functions changed"
+  const kind = outline.every(s => s.kind === outline[0].kind) ? outline[0].kind : undefined
+  const parts: string[] = []
+  for (const [change, label] of [['modified', 'changed'], ['added', 'added'], ['removed', 'removed']] as const) {
+    const n = counts[change]
+    if (!n) continue
+    if (parts.length) {
+      parts.push(`${String(n)} ${label}`)
+    } else {
+      const noun = kind ? kindNames[kind][n === 1 ? 0 : 1] : (n === 1 ? 'symbol' : 'symbols')
+      parts.push(`${String(n)} ${noun} ${label}`)
+    }
+  }
+  return parts.join(', ')
+}
+
+// List every changed symbol, for the summary's tooltip
+function outlineDetails(outline: OutlineSymbol[]): string {
+  return outline.map(s => `${s.change} ${s.kind} ${s.name}`).join('\n')
+}
+
+function OutlineSummary({ outline }: { outline: OutlineSymbol[] | undefined }): React.ReactElement | null {
+  if (!outline?.length) return null
+  return (
+    <span className="block text-xs text-[#586069] dark:text-[#8b949e]" title={outlineDetails(outline)}>
+      {outlineSummary(outline)}
+    </span>
+  )
+}
+
+export default function FileList({ files, diffType, selectedFile, onSelectFile, displayMode, viewMode, collapsedFolders, onToggleFolderCollapse }: FileListProps): React.ReactElement {
+  // Outlines of Go files come from their own endpoint, loaded with the list
+  const [outlines, setOutlines] = useState<Record<string, OutlineSymbol[]>>({})
+
+  useEffect(() => {
+    if (!files.some(file => file.path.endsWith('.go'))) {
+      setOutlines({})
+      return
+    }
+    let cancelled = false
+    void fetch(`/api/outline?type=${diffType}`)
+      .then(async response => {
+        // Patches and directory comparisons have no outline
+        const outlines = response.ok ? await response.json() as Record<string, OutlineSymbol[]> : {}
+        if (!cancelled) setOutlines(outlines)
+      })
+      .catch((err: unknown) => {
+        console.error('Failed to load outlines:', err)
+      })
+    return () => { cancelled = true }
+  }, [files, diffType])
+
   const handleFileClick = (file: FileDiff): void => {
     onSelectFile(file)
 
@@ -107,7 +172,10 @@ export default function FileList({ files, selectedFile, onSelectFile, displayMod
               }`}
             style={{ paddingLeft: `${String(depth * 20 + 8)}px` }}
           >
-            <span className="flex-1 min-w-0">{node.name}</span>
+            <span className="flex-1 min-w-0">
+              {node.name}
+              <OutlineSummary outline={outlines[file.path]} />
+            </span>
             <div className="flex items-center gap-1 text-xs flex-shrink-0">
               <span className="text-[#28a745] dark:text-[#2ea043]">+{node.file.additions}</span>
               <span className="text-[#d73a49] dark:text-[#f85149]">-{node.file.deletions}</span>
@@ -161,7 +229,10 @@ export default function FileList({ files, selectedFile, onSelectFile, displayMod
               : 'hover:bg-[#f0f3f6] dark:hover:bg-[rgba(255,255,255,0.05)]'
             }`}
         >
-          <span className="flex-1 min-w-0">{file.path}</span>
+          <span className="flex-1 min-w-0">
+            {file.path}
+            <OutlineSummary outline={outlines[file.path]} />
+          </span>
           <div className="flex items-center gap-1 text-xs flex-shrink-0">
             <span className="text-[#28a745] dark:text-[#2ea043]">+{file.additions}</span>
             <span className="text-[#d73a49] dark:text-[#f85149]">-{file.deletions}</span>
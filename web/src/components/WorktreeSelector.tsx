@@ -0,0 +1,35 @@
+import { useWorktrees } from '../hooks/useWorktrees'
+
+// Lets the user switch between the worktrees of the repository, hidden when there is only one
+export default function WorktreeSelector(): React.ReactElement | null {
+  const { worktrees, selectWorktree } = useWorktrees()
+
+  const selectable = worktrees.filter(wt => !wt.bare && !wt.prunable)
+  if (selectable.length < 2) {
+    return null
+  }
+
+  const current = selectable.find(wt => wt.current)
+
+  return (
+    <select
+      value={current?.path ?? ''}
+      onChange={(e) => {
+        void selectWorktree(e.target.value).catch((err: unknown) => {
+          console.error('Failed to select worktree:', err)
+        })
+      }}
+      className="text-sm bg-[#2f363d] dark:bg-[#21262d] text-white border border-[#444d56] dark:border-[#30363d] rounded-md px-2 py-1 max-w-[320px]"
+      title={current?.path}
+    >
+      {selectable.map(wt => (
+        <option key={wt.path} value={wt.path}>
+          {wt.branch ?? (wt.detached ? `detached ${wt.head.slice(0, 7)}` : wt.path)}
+          {wt.dirty ? ' •' : ''}
+          {' — '}
+          {wt.path}
+        </option>
+      ))}
+    </select>
+  )
+}
@@ -1,18 +1,21 @@
 import { useState, useCallback, useEffect } from 'react'
-import type { Comment } from '../types/diff'
+import type { Comment, CommentSeverity, DiffType } from '../types/diff'
 
 interface UseCommentsReturn {
   comments: Comment[]
-  addComment: (file: string, line: number, content: string, lineEnd: number) => Promise<Comment>
+  addComment: (file: string, line: number, content: string, lineEnd: number, severity?: CommentSeverity, suggestion?: string) => Promise<Comment>
   deleteComment: (id: string) => Promise<void>
   getCommentsForLine: (file: string, line: number) => Comment[]
   getCommentRangeLines: (file: string, lineOrder: number[]) => Set<number>
 }
 
-export function useComments(): UseCommentsReturn {
+// refreshKey refetches comments whenever it changes, the server moves them
+// along with their code before telling clients that files changed. New
+// comments are on lines of the diffType diff.
+export function useComments(refreshKey?: unknown, diffType: DiffType = 'all'): UseCommentsReturn {
   const [comments, setComments] = useState<Comment[]>([])
 
-  // Fetch existing comments on mount
+  // Fetch existing comments on mount and after every refresh
   useEffect(() => {
     const fetchComments = async (): Promise<void> => {
       try {
@@ -27,14 +30,14 @@ export function useComments(): UseCommentsReturn {
     }
 
     void fetchComments()
-  }, [])
+  }, [refreshKey])
 
-  const addComment = useCallback(async (file: string, line: number, content: string, lineEnd: number) => {
+  const addComment = useCallback(async (file: string, line: number, content: string, lineEnd: number, severity?: CommentSeverity, suggestion?: string) => {
     try {
-      const response = await fetch('/api/review/comment', {
+      const response = await fetch(`/api/review/comment?type=${diffType}`, {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ file, line, content, lineEnd })
+        body: JSON.stringify({ file, line, content, lineEnd, severity, suggestion })
       })
 
       if (!response.ok) {
@@ -48,7 +51,7 @@ export function useComments(): UseCommentsReturn {
       console.error('Failed to add comment:', error)
       throw error
     }
-  }, [])
+  }, [diffType])
 
   const deleteComment = useCallback(async (id: string) => {
     try {
@@ -60,7 +63,8 @@ export function useComments(): UseCommentsReturn {
         throw new Error('Failed to delete comment')
       }
 
-      setComments(prev => prev.filter(c => c.id !== id))
+      // Deleting a comment deletes its replies too
+      setComments(prev => prev.filter(c => c.id !== id && c.parentId !== id))
     } catch (error) {
       console.error('Failed to delete comment:', error)
       throw error
@@ -68,15 +72,15 @@ export function useComments(): UseCommentsReturn {
   }, [])
 
   const getCommentsForLine = useCallback((file: string, line: number) => {
-    return comments.filter(c => c.file === file && c.lineEnd === line)
+    return comments.filter(c => c.file === file && displayLine(c, c.lineEnd) === line)
   }, [comments])
 
   const getCommentRangeLines = useCallback((file: string, lineOrder: number[]): Set<number> => {
     const result = new Set<number>()
     const fileComments = comments.filter(c => c.file === file)
     for (const c of fileComments) {
-      const startIdx = lineOrder.indexOf(c.line)
-      const endIdx = lineOrder.indexOf(c.lineEnd)
+      const startIdx = lineOrder.indexOf(displayLine(c, c.line))
+      const endIdx = lineOrder.indexOf(displayLine(c, c.lineEnd))
       if (startIdx === -1 || endIdx === -1) continue
       const lo = Math.min(startIdx, endIdx)
       const hi = Math.max(startIdx, endIdx)
@@ -95,3 +99,9 @@ export function useComments(): UseCommentsReturn {
     getCommentRangeLines
   }
 }
+
+// displayLine numbers a line of a comment the way diffs are laid out, with
+// deleted lines negative
+function displayLine(comment: Comment, line: number): number {
+  return comment.side === 'old' ? -line : line
+}
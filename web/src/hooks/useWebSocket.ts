@@ -58,6 +58,12 @@ export function useWebSocket(onUpdate: () => void): void {
             setTimeout(() => {
               onUpdateRef.current()
             }, 300)
+          } else if (data.type === 'worktree_changed' || data.type === 'head_changed' || data.type === 'review_archived') {
+            // Another worktree, branch or review is now under review, reload
+            // its diff and comments
+            onUpdateRef.current()
+          } else if (data.type === 'comment_reply' || data.type === 'comment_status') {
+            onUpdateRef.current()
           }
         } catch (error) {
           console.error('Error parsing WebSocket message:', error)
@@ -0,0 +1,44 @@
+import { useState, useEffect, useCallback } from 'react'
+import type { Worktree } from '../types/diff'
+import { useWebSocketUpdates } from '../contexts/WebSocketContext'
+
+interface UseWorktreesReturn {
+  worktrees: Worktree[]
+  selectWorktree: (path: string) => Promise<void>
+}
+
+export function useWorktrees(): UseWorktreesReturn {
+  const [worktrees, setWorktrees] = useState<Worktree[]>([])
+  const { lastUpdate, triggerUpdate } = useWebSocketUpdates()
+
+  // Refresh on every update so the dirty state stays current
+  useEffect(() => {
+    const fetchWorktrees = async (): Promise<void> => {
+      try {
+        const response = await fetch('/api/worktrees')
+        if (response.ok) {
+          setWorktrees(await response.json() as Worktree[])
+        }
+      } catch (error) {
+        console.error('Failed to fetch worktrees:', error)
+      }
+    }
+
+    void fetchWorktrees()
+  }, [lastUpdate])
+
+  const selectWorktree = useCallback(async (path: string) => {
+    const response = await fetch('/api/worktrees/select', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ path })
+    })
+
+    if (!response.ok) {
+      throw new Error('Failed to select worktree')
+    }
+    triggerUpdate()
+  }, [triggerUpdate])
+
+  return { worktrees, selectWorktree }
+}
@@ -1,4 +1,4 @@
-export type DiffType = 'all' | 'staged' | 'unstaged'
+export type DiffType = 'all' | 'staged' | 'unstaged' | 'since-review'
 export type ViewMode = 'unified' | 'split'
 
 export interface FileDiff {
@@ -11,36 +11,165 @@ export interface FileDiff {
   additions: number
   deletions: number
   hunks: Hunk[]
+  outline?: OutlineSymbol[]
+  generated?: boolean
+  vendored?: boolean
+  ignored?: boolean
+  hunksOmitted?: boolean
+  oldFormat?: TextFormat
+  newFormat?: TextFormat
+  eolOnly?: boolean
+}
+
+export interface TextFormat {
+  encoding: string
+  eol: 'lf' | 'crlf' | 'mixed'
+  bom?: boolean
+}
+
+export interface OutlineSymbol {
+  name: string
+  kind: 'func' | 'method' | 'type'
+  change: 'added' | 'removed' | 'modified'
+  line: number
 }
 
 export interface Hunk {
+  id?: string
   oldStart: number
   oldLines: number
   newStart: number
   newLines: number
   header: string
+  section?: string
   lines: DiffLine[]
 }
 
 export interface DiffLine {
+  id?: string
   type: 'normal' | 'add' | 'delete' | 'context' | 'added' | 'deleted'
   oldLineNumber?: number
   newLineNumber?: number
   oldNumber?: number
   newNumber?: number
   content: string
+  move?: LineMove
+  noNewline?: boolean
+}
+
+export interface LineMove {
+  kind: 'moved' | 'moved-edited'
+  block: number
+  path: string
+  line: number
 }
 
 export interface DiffResult {
   files: FileDiff[]
   type: DiffType
+  base?: DiffBase
+  // Set on streamed diffs, which don't detect moved code
+  movesOmitted?: boolean
+}
+
+export interface DiffBase {
+  ref: string
+  commit?: string
+  mergeBase?: boolean
+}
+
+export interface RangeDiffPair {
+  status: 'matched' | 'changed' | 'added' | 'dropped'
+  oldIndex?: number
+  newIndex?: number
+  oldCommit?: string
+  newCommit?: string
+  subject: string
+  hunks: Hunk[]
+}
+
+export interface RangeDiff {
+  old: string
+  new: string
+  pairs: RangeDiffPair[]
+}
+
+export interface SearchMatch {
+  file: string
+  side: 'old' | 'new'
+  line: number
+  hunkId?: string
+  lineId?: string
+  type?: DiffLine['type']
+  content: string
+}
+
+export interface SearchResult {
+  query: string
+  matches: SearchMatch[]
+  treeMatches?: SearchMatch[]
+  truncated: boolean
+}
+
+export interface Worktree {
+  path: string
+  branch?: string
+  head: string
+  detached?: boolean
+  bare?: boolean
+  locked?: boolean
+  prunable?: boolean
+  dirty: boolean
+  current: boolean
 }
 
 export interface Comment {
   id: string
+  parentId?: string
   file: string
+  // side is 'old' for comments on deleted lines, whose numbers are old ones
+  side?: 'old' | 'new'
   line: number
   lineEnd: number
+  lineId?: string
+  lineEndId?: string
   content: string
+  status: CommentStatus
+  severity?: CommentSeverity
+  labels?: string[]
   createdAt: string
+  updatedAt?: string
+  history?: CommentRevision[]
+  anchor?: CommentAnchor
+  outdated?: boolean
+  suggestion?: string
+  scope: CommentScope
+  hunkId?: string
+  hunk?: string
+  diffType?: DiffType
+}
+
+// Review comments have no file, file comments no lines, and hunk comments
+// cover every line of a hunk
+export type CommentScope = 'review' | 'file' | 'hunk' | 'line'
+
+export type CommentSeverity = 'blocker' | 'bug' | 'suggestion' | 'nit' | 'question'
+
+export type CommentStatus = 'open' | 'resolved' | 'wont-fix' | 'addressed-by-agent'
+
+export interface CommentRevision {
+  content: string
+  side?: 'old' | 'new'
+  line?: number
+  lineEnd?: number
+  severity?: CommentSeverity
+  labels?: string[]
+  suggestion?: string
+  editedAt: string
+}
+
+export interface CommentAnchor {
+  lines: string[]
+  before?: string[]
+  after?: string[]
 }
@@ -0,0 +1,27 @@
+import type { DiffType } from '../types/diff'
+
+export interface PatchSelection {
+  files?: string[]
+  hunks?: string[]
+  lines?: string[]
+}
+
+// Downloads the selected parts of the diff as a patch file
+export async function downloadPatch(selection: PatchSelection, type: DiffType = 'all'): Promise<void> {
+  const response = await fetch('/api/export', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ ...selection, type, format: 'patch' })
+  })
+
+  if (!response.ok) {
+    throw new Error('Failed to export patch')
+  }
+
+  const url = URL.createObjectURL(await response.blob())
+  const link = document.createElement('a')
+  link.href = url
+  link.download = 'vibediff.patch'
+  link.click()
+  URL.revokeObjectURL(url)
+}
@@ -11,6 +11,8 @@ export default defineConfig({
       '/api': {
         target: 'http://localhost:8888',
         changeOrigin: true,
+        // The server refuses to apply suggestions for other origins than its own
+        headers: { Origin: 'http://localhost:8888' },
       }
     }
   },